@@ -0,0 +1,101 @@
+package circuitbreaker
+
+// This package fails gRPC client calls fast with the http/common/circuitbreaker breakers, keyed per target and method.
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/Azure/aks-middleware/grpc/common"
+	httpcircuitbreaker "github.com/Azure/aks-middleware/http/common/circuitbreaker"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Key returns the breaker key of a call, e.g. "dns:///dependency:443 /package.Service/Method".
+func Key(target, method string) string {
+	return target + " " + method
+}
+
+// Failed returns whether a call error counts as a failure of the dependency.
+// Errors caused by the request itself, e.g. InvalidArgument or NotFound, don't.
+func Failed(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Internal, codes.Unknown:
+		return true
+	default:
+		return false
+	}
+}
+
+// UnaryClientInterceptor returns a client interceptor failing calls fast with codes.Unavailable while the
+// breaker of their target and method is open. Register it after the retry interceptor so every attempt counts;
+// the fail-fast errors are common.NonRetriableError errors, so the retry interceptor doesn't retry them.
+func UnaryClientInterceptor(cb *httpcircuitbreaker.CircuitBreaker) grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		callOpts ...grpc.CallOption,
+	) error {
+		done, err := cb.Allow(ctx, Key(cc.Target(), method))
+		if err != nil {
+			return common.NonRetriableError(codes.Unavailable, err.Error())
+		}
+		err = invoker(ctx, method, req, reply, cc, callOpts...)
+		done(Failed(err))
+		return err
+	}
+}
+
+// StreamClientInterceptor is the streaming counterpart of UnaryClientInterceptor.
+// The stream is reported to the breaker when it finishes.
+func StreamClientInterceptor(cb *httpcircuitbreaker.CircuitBreaker) grpc.StreamClientInterceptor {
+	return func(
+		ctx context.Context,
+		desc *grpc.StreamDesc,
+		cc *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		callOpts ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		done, err := cb.Allow(ctx, Key(cc.Target(), method))
+		if err != nil {
+			return nil, common.NonRetriableError(codes.Unavailable, err.Error())
+		}
+		clientStream, err := streamer(ctx, desc, cc, method, callOpts...)
+		if err != nil {
+			done(Failed(err))
+			return nil, err
+		}
+		return &breakerClientStream{
+			ClientStream:    clientStream,
+			done:            done,
+			hasServerStream: desc.ServerStreams,
+		}, nil
+	}
+}
+
+// breakerClientStream reports the stream to the breaker when it finishes.
+type breakerClientStream struct {
+	grpc.ClientStream
+
+	done            func(failed bool)
+	hasServerStream bool
+	doneOnce        sync.Once
+}
+
+func (s *breakerClientStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil || !s.hasServerStream {
+		s.doneOnce.Do(func() {
+			s.done(!errors.Is(err, io.EOF) && Failed(err))
+		})
+	}
+	return err
+}
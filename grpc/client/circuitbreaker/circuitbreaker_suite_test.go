@@ -0,0 +1,13 @@
+package circuitbreaker_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "CircuitBreaker Suite")
+}
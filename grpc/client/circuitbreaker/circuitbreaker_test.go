@@ -0,0 +1,103 @@
+package circuitbreaker_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"sync/atomic"
+
+	"github.com/Azure/aks-middleware/grpc/client/circuitbreaker"
+	"github.com/Azure/aks-middleware/grpc/interceptor"
+	httpcircuitbreaker "github.com/Azure/aks-middleware/http/common/circuitbreaker"
+	pb "github.com/Azure/aks-middleware/test/api/v1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// failingServer fails SayHello with code.
+type failingServer struct {
+	pb.UnimplementedMyGreeterServer
+	code  codes.Code
+	calls atomic.Int32
+}
+
+func (s *failingServer) SayHello(ctx context.Context, req *pb.HelloRequest) (*pb.HelloReply, error) {
+	s.calls.Add(1)
+	return nil, status.Error(s.code, "failed")
+}
+
+var _ = Describe("Circuit breaker interceptor", func() {
+	var (
+		srv        *failingServer
+		grpcServer *grpc.Server
+		clientConn *grpc.ClientConn
+		cb         *httpcircuitbreaker.CircuitBreaker
+		client     pb.MyGreeterClient
+		req        *pb.HelloRequest
+	)
+
+	BeforeEach(func() {
+		lis, err := net.Listen("tcp", "localhost:0")
+		Expect(err).ToNot(HaveOccurred())
+		srv = &failingServer{code: codes.Unavailable}
+		grpcServer = grpc.NewServer()
+		pb.RegisterMyGreeterServer(grpcServer, srv)
+		go func() {
+			_ = grpcServer.Serve(lis)
+		}()
+
+		cb = httpcircuitbreaker.New(httpcircuitbreaker.Options{MinRequests: 2})
+		logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+		options := interceptor.GetClientInterceptorLogOptions(logger, nil)
+		options.APIOutput = io.Discard
+		options.CircuitBreaker = cb
+		clientConn, err = grpc.NewClient(lis.Addr().String(),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithChainUnaryInterceptor(interceptor.DefaultClientInterceptors(options)...),
+		)
+		Expect(err).ToNot(HaveOccurred())
+		client = pb.NewMyGreeterClient(clientConn)
+		req = &pb.HelloRequest{Name: "Test", Age: 30, Email: "test@test.com"}
+	})
+
+	AfterEach(func() {
+		clientConn.Close()
+		grpcServer.Stop()
+	})
+
+	It("should fail the retries fast with Unavailable once the breaker opens", func() {
+		_, err := client.SayHello(context.Background(), req)
+		Expect(status.Code(err)).To(Equal(codes.Unavailable))
+		Expect(status.Convert(err).Message()).To(ContainSubstring(httpcircuitbreaker.ErrOpen.Error()))
+		Expect(srv.calls.Load()).To(Equal(int32(2)))
+
+		key := circuitbreaker.Key(clientConn.Target(), pb.MyGreeter_SayHello_FullMethodName)
+		Expect(cb.State(key)).To(Equal(httpcircuitbreaker.StateOpen))
+	})
+
+	It("should not count errors caused by the request", func() {
+		srv.code = codes.InvalidArgument
+		for i := 0; i < 3; i++ {
+			_, err := client.SayHello(context.Background(), req)
+			Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+		}
+		Expect(srv.calls.Load()).To(Equal(int32(3)))
+		Expect(cb.States()).To(HaveEach(httpcircuitbreaker.StateClosed))
+	})
+})
+
+var _ = Describe("Failed", func() {
+	It("should only count dependency failures", func() {
+		Expect(circuitbreaker.Failed(nil)).To(BeFalse())
+		Expect(circuitbreaker.Failed(status.Error(codes.NotFound, ""))).To(BeFalse())
+		Expect(circuitbreaker.Failed(status.Error(codes.Canceled, ""))).To(BeFalse())
+		Expect(circuitbreaker.Failed(status.Error(codes.DeadlineExceeded, ""))).To(BeTrue())
+		Expect(circuitbreaker.Failed(errors.New("connection reset"))).To(BeTrue())
+	})
+})
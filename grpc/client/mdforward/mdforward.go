@@ -20,12 +20,31 @@ func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
 		invoker grpc.UnaryInvoker,
 		callOpts ...grpc.CallOption,
 	) error {
-		md, ok := metadata.FromIncomingContext(ctx)
-		if ok {
-			if _, ok := metadata.FromOutgoingContext(ctx); !ok {
-				ctx = metadata.NewOutgoingContext(ctx, md)
-			}
+		return invoker(forwardMetadata(ctx), method, req, reply, cc, callOpts...)
+	}
+}
+
+// StreamClientInterceptor forwards the MD if there is no outgoing MD.
+// It is the streaming counterpart of UnaryClientInterceptor.
+func StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(
+		ctx context.Context,
+		desc *grpc.StreamDesc,
+		cc *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		callOpts ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		return streamer(forwardMetadata(ctx), desc, cc, method, callOpts...)
+	}
+}
+
+func forwardMetadata(ctx context.Context) context.Context {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if ok {
+		if _, ok := metadata.FromOutgoingContext(ctx); !ok {
+			ctx = metadata.NewOutgoingContext(ctx, md)
 		}
-		return invoker(ctx, method, req, reply, cc, callOpts...)
 	}
+	return ctx
 }
@@ -10,6 +10,7 @@ import (
 	log "log/slog"
 
 	"github.com/Azure/aks-middleware/grpc/common"
+	"github.com/Azure/aks-middleware/http/common/scrub"
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
 )
 
@@ -28,6 +29,7 @@ func InterceptorLogger(logger *log.Logger) logging.Logger {
 // This provides flexibility to customize which headers are extracted from the context.
 // Pass nil as headersFunc to use the default common.GetFields header extraction.
 func InterceptorLoggerWithHeadersFunc(logger *log.Logger, headersFunc HeadersFunc) logging.Logger {
+	logger = scrub.Logger(logger)
 	return logging.LoggerFunc(func(ctx context.Context, lvl logging.Level, msg string, fields ...any) {
 		f := make(map[string]any, len(fields)/2)
 		l := logger
@@ -0,0 +1,97 @@
+package autologger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Azure/aks-middleware/grpc/server/ctxlogger"
+	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
+	"google.golang.org/grpc"
+)
+
+const (
+	responseContentLogKey = "response"
+	// DefaultMaxPayloadSize is the maximum serialized size of a logged response when PayloadLogOptions.MaxSize is 0.
+	DefaultMaxPayloadSize = 4096
+	// truncatedMarker is appended to a response that was cut at the maximum size.
+	truncatedMarker = "...(truncated, %d bytes)"
+)
+
+// PayloadLogOptions configures response payload logging.
+type PayloadLogOptions struct {
+	// Methods is the allowlist of full methods whose responses are logged, e.g. "/pkg.Service/Method".
+	// "/pkg.Service/*" allows every method of a service and "*" allows every method.
+	Methods []string
+	// MaxSize is the maximum size in bytes of the serialized response. Larger responses are logged
+	// as a truncated JSON string ending with a truncation marker. Defaults to DefaultMaxPayloadSize.
+	MaxSize int
+}
+
+// allowed returns whether the response of fullMethod should be logged.
+func (o PayloadLogOptions) allowed(fullMethod string) bool {
+	for _, m := range o.Methods {
+		if m == "*" || m == fullMethod {
+			return true
+		}
+		if service, ok := strings.CutSuffix(m, "*"); ok && strings.HasPrefix(fullMethod, service) {
+			return true
+		}
+	}
+	return false
+}
+
+// ResponsePayloadServerInterceptor adds the response of allowed unary methods to the finished call log,
+// filtered by the (servicehub.fieldoptions.loggable) option like the request in the CtxLog.
+// It must be registered after logging.UnaryServerInterceptor so that the field it adds is
+// in the logging interceptor's context when the call finishes.
+func ResponsePayloadServerInterceptor(opts PayloadLogOptions) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (any, error) {
+		resp, err := handler(ctx, req)
+		if err == nil && opts.allowed(info.FullMethod) {
+			logging.AddFields(ctx, logging.Fields{responseContentLogKey, payloadLogValue(resp, opts.MaxSize)})
+		}
+		return resp, err
+	}
+}
+
+// ResponsePayloadClientInterceptor is the client counterpart of ResponsePayloadServerInterceptor.
+// It must be registered after logging.UnaryClientInterceptor.
+func ResponsePayloadClientInterceptor(opts PayloadLogOptions) grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		callOpts ...grpc.CallOption,
+	) error {
+		err := invoker(ctx, method, req, reply, cc, callOpts...)
+		if err == nil && opts.allowed(method) {
+			logging.AddFields(ctx, logging.Fields{responseContentLogKey, payloadLogValue(reply, opts.MaxSize)})
+		}
+		return err
+	}
+}
+
+// payloadLogValue returns the filtered payload of msg, or a truncated JSON string
+// when its serialized size is over maxSize.
+func payloadLogValue(msg any, maxSize int) any {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxPayloadSize
+	}
+	payload := ctxlogger.FilterLogs(msg)
+	b, err := json.Marshal(payload)
+	if err != nil || len(b) <= maxSize {
+		return payload
+	}
+	// Don't cut a multi-byte character.
+	cut := maxSize
+	for cut > 0 && !utf8.RuneStart(b[cut]) {
+		cut--
+	}
+	return string(b[:cut]) + fmt.Sprintf(truncatedMarker, len(b))
+}
@@ -0,0 +1,105 @@
+package autologger_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	log "log/slog"
+	"strings"
+
+	"github.com/Azure/aks-middleware/grpc/common/autologger"
+	pb "github.com/Azure/aks-middleware/test/api/v1"
+	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+var _ = Describe("Response payload logging", func() {
+	var (
+		buffer *bytes.Buffer
+		logger logging.Logger
+	)
+
+	BeforeEach(func() {
+		buffer = &bytes.Buffer{}
+		logger = autologger.InterceptorLogger(log.New(log.NewJSONHandler(buffer, nil)))
+	})
+
+	// callServer runs handler behind the logging and response payload server interceptors
+	// and returns the finished call log entry.
+	callServer := func(opts autologger.PayloadLogOptions, fullMethod string, resp proto.Message, err error) map[string]any {
+		loggingInterceptor := logging.UnaryServerInterceptor(logger, logging.WithLogOnEvents(logging.FinishCall))
+		payloadInterceptor := autologger.ResponsePayloadServerInterceptor(opts)
+		info := &grpc.UnaryServerInfo{FullMethod: fullMethod}
+		_, _ = loggingInterceptor(context.Background(), &pb.HelloRequest{}, info,
+			func(ctx context.Context, req any) (any, error) {
+				return payloadInterceptor(ctx, req, info, func(ctx context.Context, req any) (any, error) {
+					return resp, err
+				})
+			})
+
+		var logEntry map[string]any
+		Expect(json.Unmarshal(buffer.Bytes(), &logEntry)).To(Succeed())
+		return logEntry
+	}
+
+	It("should log the filtered response of allowed server methods", func() {
+		opts := autologger.PayloadLogOptions{Methods: []string{"/MyGreeter/*"}}
+		resp := &pb.HelloRequest{Name: "Response", Email: "secret@test.com", Address: &pb.Address{City: "Seattle", State: "WA"}}
+		logEntry := callServer(opts, "/MyGreeter/SayHello", resp, nil)
+
+		Expect(logEntry).To(HaveKey("response"))
+		payload := logEntry["response"].(map[string]any)
+		Expect(payload).To(HaveKeyWithValue("name", "Response"))
+		Expect(payload).ToNot(HaveKey("email"))
+		Expect(payload["address"]).To(HaveKeyWithValue("city", "Seattle"))
+		Expect(payload["address"]).ToNot(HaveKey("state"))
+	})
+
+	It("should not log the response of other methods or of failed calls", func() {
+		opts := autologger.PayloadLogOptions{Methods: []string{"/MyGreeter/SayHello"}}
+		Expect(callServer(opts, "/MyGreeter/SayGoodbye", &pb.HelloReply{Message: "Bye"}, nil)).ToNot(HaveKey("response"))
+
+		buffer.Reset()
+		Expect(callServer(opts, "/MyGreeter/SayHello", nil, status.Error(codes.Internal, "failed"))).ToNot(HaveKey("response"))
+	})
+
+	It("should truncate large responses", func() {
+		opts := autologger.PayloadLogOptions{Methods: []string{"*"}, MaxSize: 32}
+		logEntry := callServer(opts, "/MyGreeter/SayHello", &pb.HelloReply{Message: strings.Repeat("a", 100)}, nil)
+
+		response, ok := logEntry["response"].(string)
+		Expect(ok).To(BeTrue())
+		Expect(response).To(HavePrefix(`{"message":"aaaa`))
+		Expect(response).To(HaveSuffix("...(truncated, 114 bytes)"))
+		Expect(len(response)).To(BeNumerically("<", 64))
+	})
+
+	It("should log the response of allowed client methods", func() {
+		loggingInterceptor := logging.UnaryClientInterceptor(logger,
+			logging.WithLogOnEvents(logging.FinishCall),
+			logging.WithLevels(logging.DefaultServerCodeToLevel),
+		)
+		payloadInterceptor := autologger.ResponsePayloadClientInterceptor(autologger.PayloadLogOptions{Methods: []string{"/MyGreeter/SayHello"}})
+		invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			reply.(*pb.HelloRequest).Name = "Dependency"
+			reply.(*pb.HelloRequest).Age = 42
+			return nil
+		}
+
+		err := loggingInterceptor(context.Background(), "/MyGreeter/SayHello", &pb.HelloRequest{}, &pb.HelloRequest{}, nil,
+			func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				return payloadInterceptor(ctx, method, req, reply, cc, invoker, opts...)
+			})
+		Expect(err).ToNot(HaveOccurred())
+
+		var logEntry map[string]any
+		Expect(json.Unmarshal(buffer.Bytes(), &logEntry)).To(Succeed())
+		Expect(logEntry).To(HaveKeyWithValue("response", HaveKeyWithValue("name", "Dependency")))
+		Expect(logEntry["response"]).ToNot(HaveKey("age"))
+	})
+})
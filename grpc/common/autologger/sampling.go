@@ -0,0 +1,54 @@
+package autologger
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Azure/aks-middleware/http/common/sampling"
+	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
+	"google.golang.org/grpc/codes"
+)
+
+const finishedCallMsg = "finished call"
+
+// SampledLogger wraps logger so that the successful "finished call" records are sampled with policy.
+// Calls with a non-OK code or slower than the policy's latency threshold are always logged.
+// Every finished call record gets a sample_rate field. A nil policy returns logger unchanged.
+func SampledLogger(logger logging.Logger, policy *sampling.Policy) logging.Logger {
+	if policy == nil {
+		return logger
+	}
+	return logging.LoggerFunc(func(ctx context.Context, lvl logging.Level, msg string, fields ...any) {
+		if msg != finishedCallMsg {
+			logger.Log(ctx, lvl, msg, fields...)
+			return
+		}
+
+		var service, method, code string
+		var latency time.Duration
+		i := logging.Fields(fields).Iterator()
+		for i.Next() {
+			k, v := i.At()
+			switch k {
+			case logging.ServiceFieldKey:
+				service = fmt.Sprint(v)
+			case logging.MethodFieldKey:
+				method = fmt.Sprint(v)
+			case "grpc.code":
+				code = fmt.Sprint(v)
+			case "grpc.time_ms":
+				if ms, err := strconv.ParseFloat(fmt.Sprint(v), 64); err == nil {
+					latency = time.Duration(ms * float64(time.Millisecond))
+				}
+			}
+		}
+
+		ok, rate := policy.Sample("/"+service+"/"+method, code != codes.OK.String(), latency)
+		if !ok {
+			return
+		}
+		logger.Log(ctx, lvl, msg, append(fields, sampling.SampleRateLogKey, rate)...)
+	})
+}
@@ -0,0 +1,63 @@
+package autologger_test
+
+import (
+	"bytes"
+	"context"
+	log "log/slog"
+	"strings"
+	"time"
+
+	"github.com/Azure/aks-middleware/grpc/common/autologger"
+	"github.com/Azure/aks-middleware/http/common/sampling"
+	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Sampled logger", func() {
+	var (
+		buffer *bytes.Buffer
+		logger logging.Logger
+	)
+
+	BeforeEach(func() {
+		buffer = &bytes.Buffer{}
+		policy := &sampling.Policy{
+			DefaultRate:      1e-12,
+			MethodRates:      map[string]float64{"/MyGreeter/SayHello": 1},
+			LatencyThreshold: time.Second,
+		}
+		logger = autologger.SampledLogger(autologger.InterceptorLogger(log.New(log.NewJSONHandler(buffer, nil))), policy)
+	})
+
+	finishedCall := func(method, code, timeMs string) {
+		logger.Log(context.Background(), logging.LevelInfo, "finished call",
+			logging.ServiceFieldKey, "MyGreeter",
+			logging.MethodFieldKey, method,
+			"grpc.code", code,
+			"grpc.time_ms", timeMs,
+		)
+	}
+
+	It("should drop sampled out successful calls", func() {
+		finishedCall("List", "OK", "1.5")
+		Expect(buffer.String()).To(BeEmpty())
+	})
+
+	It("should log calls with the sample rate", func() {
+		finishedCall("SayHello", "OK", "1.5")
+		Expect(buffer.String()).To(ContainSubstring(`"sample_rate":1`))
+	})
+
+	It("should always log failed and slow calls", func() {
+		finishedCall("List", "Internal", "1.5")
+		finishedCall("List", "OK", "1500")
+		Expect(strings.Count(buffer.String(), "finished call")).To(Equal(2))
+	})
+
+	It("should not sample other records", func() {
+		logger.Log(context.Background(), logging.LevelInfo, "started call", logging.MethodFieldKey, "List")
+		Expect(buffer.String()).To(ContainSubstring("started call"))
+		Expect(buffer.String()).ToNot(ContainSubstring("sample_rate"))
+	})
+})
@@ -0,0 +1,91 @@
+package autologger
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
+	"google.golang.org/grpc"
+)
+
+const (
+	msgSentLogKey          = "grpc.msg_sent"
+	msgReceivedLogKey      = "grpc.msg_received"
+	streamDurationMsLogKey = "grpc.stream_duration_ms"
+)
+
+// StreamClientStatsInterceptor counts the messages sent and received on a client stream and
+// adds the counts and the total stream duration to the fields of the finished call log.
+// It must be registered after logging.StreamClientInterceptor so that the fields it adds are
+// in the logging interceptor's context when the stream finishes.
+func StreamClientStatsInterceptor() grpc.StreamClientInterceptor {
+	return func(
+		ctx context.Context,
+		desc *grpc.StreamDesc,
+		cc *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		callOpts ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		start := time.Now()
+		clientStream, err := streamer(ctx, desc, cc, method, callOpts...)
+		if err != nil {
+			logging.AddFields(ctx, logging.Fields{
+				msgSentLogKey, 0,
+				msgReceivedLogKey, 0,
+				streamDurationMsLogKey, time.Since(start).Milliseconds(),
+			})
+			return nil, err
+		}
+		return &countingClientStream{
+			ClientStream:    clientStream,
+			ctx:             ctx,
+			start:           start,
+			hasServerStream: desc.ServerStreams,
+		}, nil
+	}
+}
+
+// countingClientStream wraps grpc.ClientStream and counts the messages going through it.
+type countingClientStream struct {
+	grpc.ClientStream
+
+	ctx             context.Context
+	start           time.Time
+	hasServerStream bool
+	sent            atomic.Int64
+	received        atomic.Int64
+	finishOnce      sync.Once
+}
+
+func (s *countingClientStream) SendMsg(m any) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		s.sent.Add(1)
+	}
+	return err
+}
+
+func (s *countingClientStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err == nil {
+		s.received.Add(1)
+	}
+	// Mirrors when the logging interceptor reports the end of the call.
+	if err != nil || !s.hasServerStream {
+		s.finish()
+	}
+	return err
+}
+
+func (s *countingClientStream) finish() {
+	s.finishOnce.Do(func() {
+		logging.AddFields(s.ctx, logging.Fields{
+			msgSentLogKey, s.sent.Load(),
+			msgReceivedLogKey, s.received.Load(),
+			streamDurationMsLogKey, time.Since(s.start).Milliseconds(),
+		})
+	})
+}
@@ -0,0 +1,145 @@
+package deadline
+
+// This package applies the http/common/deadline timeouts and budget to gRPC servers and clients.
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	httpdeadline "github.com/Azure/aks-middleware/http/common/deadline"
+	middleware "github.com/grpc-ecosystem/go-grpc-middleware/v2"
+	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor returns a server interceptor applying the timeout of each method.
+// The deadline sent by the client is kept when it is earlier. When the call runs out of time, the
+// finished call log records whether it did locally or in a dependency (httpdeadline.ExceededLogKey).
+// Register it after the logging interceptor so the fields are added to its log.
+func UnaryServerInterceptor(timeouts *httpdeadline.Timeouts) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (any, error) {
+		ctx, cancel := timeouts.WithTimeout(ctx, info.FullMethod)
+		defer cancel()
+
+		resp, err := handler(ctx, req)
+		if isDeadlineExceeded(ctx, err) {
+			logging.AddFields(ctx, httpdeadline.LogAttrs(ctx))
+		}
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor is the streaming counterpart of UnaryServerInterceptor.
+func StreamServerInterceptor(timeouts *httpdeadline.Timeouts) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+		ctx, cancel := timeouts.WithTimeout(ss.Context(), info.FullMethod)
+		defer cancel()
+
+		wrapped := middleware.WrapServerStream(ss)
+		wrapped.WrappedContext = ctx
+		err := handler(srv, wrapped)
+		if isDeadlineExceeded(ctx, err) {
+			logging.AddFields(ctx, httpdeadline.LogAttrs(ctx))
+		}
+		return err
+	}
+}
+
+// UnaryClientInterceptor returns a client interceptor forwarding the remaining budget of the request minus
+// the safety margin. Calls made with less than the margin left fail with codes.DeadlineExceeded without
+// being sent. Calls that run out of time are recorded as the dependency that exhausted the budget.
+func UnaryClientInterceptor(budget *httpdeadline.Budget) grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		callOpts ...grpc.CallOption,
+	) error {
+		outCtx, cancel, err := budget.Outbound(ctx)
+		if err != nil {
+			return status.Error(codes.DeadlineExceeded, err.Error())
+		}
+		defer cancel()
+
+		err = invoker(outCtx, method, req, reply, cc, callOpts...)
+		if status.Code(err) == codes.DeadlineExceeded {
+			httpdeadline.RecordDependencyExceeded(ctx, method)
+		}
+		return err
+	}
+}
+
+// StreamClientInterceptor is the streaming counterpart of UnaryClientInterceptor.
+// The outbound context is released when the stream finishes.
+func StreamClientInterceptor(budget *httpdeadline.Budget) grpc.StreamClientInterceptor {
+	return func(
+		ctx context.Context,
+		desc *grpc.StreamDesc,
+		cc *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		callOpts ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		outCtx, cancel, err := budget.Outbound(ctx)
+		if err != nil {
+			return nil, status.Error(codes.DeadlineExceeded, err.Error())
+		}
+		clientStream, err := streamer(outCtx, desc, cc, method, callOpts...)
+		if err != nil {
+			cancel()
+			if status.Code(err) == codes.DeadlineExceeded {
+				httpdeadline.RecordDependencyExceeded(ctx, method)
+			}
+			return nil, err
+		}
+		return &budgetClientStream{
+			ClientStream:    clientStream,
+			parentCtx:       ctx,
+			method:          method,
+			cancel:          cancel,
+			hasServerStream: desc.ServerStreams,
+		}, nil
+	}
+}
+
+// budgetClientStream releases the outbound context when the stream finishes.
+type budgetClientStream struct {
+	grpc.ClientStream
+
+	parentCtx       context.Context
+	method          string
+	cancel          context.CancelFunc
+	hasServerStream bool
+	endOnce         sync.Once
+}
+
+func (s *budgetClientStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil || !s.hasServerStream {
+		s.endOnce.Do(func() {
+			if status.Code(err) == codes.DeadlineExceeded {
+				httpdeadline.RecordDependencyExceeded(s.parentCtx, s.method)
+			}
+			s.cancel()
+		})
+	}
+	return err
+}
+
+// isDeadlineExceeded returns whether a call failed because it ran out of time.
+func isDeadlineExceeded(ctx context.Context, err error) bool {
+	if err == nil || errors.Is(err, io.EOF) {
+		return false
+	}
+	return status.Code(err) == codes.DeadlineExceeded ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(ctx.Err(), context.DeadlineExceeded)
+}
@@ -0,0 +1,150 @@
+package deadline_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/Azure/aks-middleware/grpc/common/autologger"
+	"github.com/Azure/aks-middleware/grpc/common/deadline"
+	httpdeadline "github.com/Azure/aks-middleware/http/common/deadline"
+	pb "github.com/Azure/aks-middleware/test/api/v1"
+	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// greeter calls sayHello for SayHello.
+type greeter struct {
+	pb.UnimplementedMyGreeterServer
+	sayHello func(ctx context.Context, req *pb.HelloRequest) (*pb.HelloReply, error)
+}
+
+func (g *greeter) SayHello(ctx context.Context, req *pb.HelloRequest) (*pb.HelloReply, error) {
+	return g.sayHello(ctx, req)
+}
+
+// syncBuffer is a bytes.Buffer safe for the concurrent writes of the server logger.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func serve(srv pb.MyGreeterServer, opts ...grpc.ServerOption) (*grpc.Server, net.Listener) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	Expect(err).ToNot(HaveOccurred())
+	grpcServer := grpc.NewServer(opts...)
+	pb.RegisterMyGreeterServer(grpcServer, srv)
+	go func() {
+		_ = grpcServer.Serve(lis)
+	}()
+	return grpcServer, lis
+}
+
+func dial(lis net.Listener, opts ...grpc.DialOption) *grpc.ClientConn {
+	conn, err := grpc.NewClient(lis.Addr().String(),
+		append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))...)
+	Expect(err).ToNot(HaveOccurred())
+	return conn
+}
+
+var _ = Describe("Deadline interceptors", func() {
+	var (
+		logOutput     *syncBuffer
+		backendServer *grpc.Server
+		backendConn   *grpc.ClientConn
+		backendCtx    chan time.Duration
+		frontServer   *grpc.Server
+		frontConn     *grpc.ClientConn
+		client        pb.MyGreeterClient
+		callBackend   bool
+	)
+
+	BeforeEach(func() {
+		logOutput = &syncBuffer{}
+		backendCtx = make(chan time.Duration, 1)
+		callBackend = false
+
+		var backendLis net.Listener
+		backendServer, backendLis = serve(&greeter{sayHello: func(ctx context.Context, req *pb.HelloRequest) (*pb.HelloReply, error) {
+			remaining, _ := httpdeadline.Remaining(ctx)
+			backendCtx <- remaining
+			<-ctx.Done()
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}})
+		backendConn = dial(backendLis,
+			grpc.WithChainUnaryInterceptor(deadline.UnaryClientInterceptor(&httpdeadline.Budget{SafetyMargin: 50 * time.Millisecond})),
+		)
+		backend := pb.NewMyGreeterClient(backendConn)
+
+		logger := slog.New(slog.NewJSONHandler(logOutput, nil))
+		var frontLis net.Listener
+		frontServer, frontLis = serve(&greeter{sayHello: func(ctx context.Context, req *pb.HelloRequest) (*pb.HelloReply, error) {
+			if callBackend {
+				return backend.SayHello(ctx, req)
+			}
+			<-ctx.Done()
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}},
+			grpc.ChainUnaryInterceptor(
+				logging.UnaryServerInterceptor(autologger.InterceptorLogger(logger), logging.WithLogOnEvents(logging.FinishCall)),
+				deadline.UnaryServerInterceptor(&httpdeadline.Timeouts{
+					Default: time.Minute,
+					Methods: map[string]time.Duration{pb.MyGreeter_SayHello_FullMethodName: 200 * time.Millisecond},
+				}),
+			),
+		)
+		frontConn = dial(frontLis)
+		client = pb.NewMyGreeterClient(frontConn)
+	})
+
+	AfterEach(func() {
+		frontConn.Close()
+		frontServer.Stop()
+		backendConn.Close()
+		backendServer.Stop()
+	})
+
+	It("should apply the method timeout and log a local expiry", func() {
+		_, err := client.SayHello(context.Background(), &pb.HelloRequest{Name: "Test"})
+		Expect(status.Code(err)).To(Equal(codes.DeadlineExceeded))
+		Eventually(logOutput.String).Should(ContainSubstring(`"deadline_exceeded":"local"`))
+		Expect(logOutput.String()).ToNot(ContainSubstring(httpdeadline.DependencyLogKey))
+	})
+
+	It("should forward the budget minus the margin and log a dependency expiry", func() {
+		callBackend = true
+		_, err := client.SayHello(context.Background(), &pb.HelloRequest{Name: "Test"})
+		Expect(status.Code(err)).To(Equal(codes.DeadlineExceeded))
+		Expect(<-backendCtx).To(BeNumerically("<=", 150*time.Millisecond))
+		Eventually(logOutput.String).Should(ContainSubstring(`"deadline_exceeded":"dependency"`))
+		Expect(logOutput.String()).To(ContainSubstring(`"deadline_dependency":"` + pb.MyGreeter_SayHello_FullMethodName + `"`))
+	})
+
+	It("should fail calls made with an exhausted budget without sending them", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err := pb.NewMyGreeterClient(backendConn).SayHello(ctx, &pb.HelloRequest{Name: "Test"})
+		Expect(status.Code(err)).To(Equal(codes.DeadlineExceeded))
+		Expect(backendCtx).ToNot(Receive())
+	})
+})
@@ -0,0 +1,107 @@
+package metrics
+
+// This package records RED metrics for gRPC servers and clients with the shared http/common/metrics recorder.
+// The method attribute is the gRPC full method and the code attribute is the gRPC status code.
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	httpmetrics "github.com/Azure/aks-middleware/http/common/metrics"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor returns a server interceptor recording RED metrics.
+func UnaryServerInterceptor(mp metric.MeterProvider) grpc.UnaryServerInterceptor {
+	recorder := httpmetrics.NewRecorder(mp)
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (any, error) {
+		end := recorder.Start(ctx, httpmetrics.ProtocolGRPC, httpmetrics.ComponentServer, info.FullMethod)
+		resp, err := handler(ctx, req)
+		end(status.Code(err).String())
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor is the streaming counterpart of UnaryServerInterceptor.
+func StreamServerInterceptor(mp metric.MeterProvider) grpc.StreamServerInterceptor {
+	recorder := httpmetrics.NewRecorder(mp)
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+		end := recorder.Start(ss.Context(), httpmetrics.ProtocolGRPC, httpmetrics.ComponentServer, info.FullMethod)
+		err := handler(srv, ss)
+		end(status.Code(err).String())
+		return err
+	}
+}
+
+// UnaryClientInterceptor returns a client interceptor recording RED metrics.
+func UnaryClientInterceptor(mp metric.MeterProvider) grpc.UnaryClientInterceptor {
+	recorder := httpmetrics.NewRecorder(mp)
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		callOpts ...grpc.CallOption,
+	) error {
+		end := recorder.Start(ctx, httpmetrics.ProtocolGRPC, httpmetrics.ComponentClient, method)
+		err := invoker(ctx, method, req, reply, cc, callOpts...)
+		end(status.Code(err).String())
+		return err
+	}
+}
+
+// StreamClientInterceptor is the streaming counterpart of UnaryClientInterceptor.
+// The request is recorded as finished when the stream finishes.
+func StreamClientInterceptor(mp metric.MeterProvider) grpc.StreamClientInterceptor {
+	recorder := httpmetrics.NewRecorder(mp)
+	return func(
+		ctx context.Context,
+		desc *grpc.StreamDesc,
+		cc *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		callOpts ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		end := recorder.Start(ctx, httpmetrics.ProtocolGRPC, httpmetrics.ComponentClient, method)
+		clientStream, err := streamer(ctx, desc, cc, method, callOpts...)
+		if err != nil {
+			end(status.Code(err).String())
+			return nil, err
+		}
+		return &measuredClientStream{
+			ClientStream:    clientStream,
+			end:             end,
+			hasServerStream: desc.ServerStreams,
+		}, nil
+	}
+}
+
+// measuredClientStream records the end of the request when the stream finishes.
+type measuredClientStream struct {
+	grpc.ClientStream
+
+	end             func(code string)
+	hasServerStream bool
+	endOnce         sync.Once
+}
+
+func (s *measuredClientStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil || !s.hasServerStream {
+		s.endOnce.Do(func() {
+			finalErr := err
+			if errors.Is(finalErr, io.EOF) {
+				finalErr = nil
+			}
+			s.end(status.Code(finalErr).String())
+		})
+	}
+	return err
+}
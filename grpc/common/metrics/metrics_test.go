@@ -0,0 +1,128 @@
+package metrics_test
+
+import (
+	"context"
+	"io"
+	"net"
+
+	"github.com/Azure/aks-middleware/grpc/common/metrics"
+	httpmetrics "github.com/Azure/aks-middleware/http/common/metrics"
+	pb "github.com/Azure/aks-middleware/test/api/v1"
+	"github.com/Azure/aks-middleware/test/server"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+var _ = Describe("Metrics interceptors", func() {
+	var (
+		reader     *sdkmetric.ManualReader
+		grpcServer *grpc.Server
+		lis        net.Listener
+		clientConn *grpc.ClientConn
+		client     pb.MyGreeterClient
+	)
+
+	BeforeEach(func() {
+		reader = sdkmetric.NewManualReader()
+		mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+		var err error
+		lis, err = net.Listen("tcp", "localhost:0")
+		Expect(err).ToNot(HaveOccurred())
+		grpcServer = grpc.NewServer(
+			grpc.ChainUnaryInterceptor(metrics.UnaryServerInterceptor(mp)),
+			grpc.ChainStreamInterceptor(metrics.StreamServerInterceptor(mp)),
+		)
+		pb.RegisterMyGreeterServer(grpcServer, &server.TestServer{})
+		server.RegisterStreamGreeterServer(grpcServer, &server.TestStreamServer{})
+		go func() {
+			_ = grpcServer.Serve(lis)
+		}()
+
+		clientConn, err = grpc.NewClient(lis.Addr().String(),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithChainUnaryInterceptor(metrics.UnaryClientInterceptor(mp)),
+			grpc.WithChainStreamInterceptor(metrics.StreamClientInterceptor(mp)),
+		)
+		Expect(err).ToNot(HaveOccurred())
+		client = pb.NewMyGreeterClient(clientConn)
+	})
+
+	AfterEach(func() {
+		clientConn.Close()
+		grpcServer.Stop()
+		lis.Close()
+	})
+
+	requestPoints := func() map[string]metricdata.DataPoint[int64] {
+		var rm metricdata.ResourceMetrics
+		Expect(reader.Collect(context.Background(), &rm)).To(Succeed())
+		points := map[string]metricdata.DataPoint[int64]{}
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				if m.Name != httpmetrics.RequestsMetricName {
+					continue
+				}
+				for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+					attrs := attributeMap(dp.Attributes.ToSlice())
+					points[attrs[httpmetrics.ComponentAttributeKey]+" "+attrs[httpmetrics.MethodAttributeKey]+" "+attrs[httpmetrics.CodeAttributeKey]] = dp
+				}
+			}
+		}
+		return points
+	}
+
+	It("should count unary calls on the client and the server", func() {
+		_, err := client.SayHello(context.Background(), &pb.HelloRequest{Name: "Metrics"})
+		Expect(err).ToNot(HaveOccurred())
+		_, err = client.SayHello(context.Background(), &pb.HelloRequest{Name: "Metrics"})
+		Expect(err).ToNot(HaveOccurred())
+
+		points := requestPoints()
+		Expect(points).To(HaveLen(2))
+		Expect(points).To(HaveKey("client /MyGreeter/SayHello OK"))
+		Expect(points["client /MyGreeter/SayHello OK"].Value).To(Equal(int64(2)))
+		Expect(points).To(HaveKey("server /MyGreeter/SayHello OK"))
+		serverPoint := points["server /MyGreeter/SayHello OK"]
+		attrs := attributeMap(serverPoint.Attributes.ToSlice())
+		Expect(attrs).To(HaveKeyWithValue(httpmetrics.ProtocolAttributeKey, httpmetrics.ProtocolGRPC))
+	})
+
+	It("should label failed calls with the status code", func() {
+		err := clientConn.Invoke(context.Background(), "/MyGreeter/Missing", &pb.HelloRequest{}, &pb.HelloReply{})
+		Expect(status.Code(err)).To(Equal(codes.Unimplemented))
+
+		Expect(requestPoints()).To(HaveKey("client /MyGreeter/Missing Unimplemented"))
+	})
+
+	It("should record streams when they finish", func() {
+		stream, err := server.NewSayHelloStream(context.Background(), clientConn)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stream.SendMsg(&pb.HelloRequest{Name: "Stream"})).To(Succeed())
+		reply := &pb.HelloReply{}
+		Expect(stream.RecvMsg(reply)).To(Succeed())
+		Expect(requestPoints()).ToNot(HaveKey("client " + server.SayHelloStreamMethod + " OK"))
+
+		Expect(stream.CloseSend()).To(Succeed())
+		Expect(stream.RecvMsg(reply)).To(MatchError(io.EOF))
+
+		points := requestPoints()
+		Expect(points).To(HaveKey("client " + server.SayHelloStreamMethod + " OK"))
+		Eventually(requestPoints).Should(HaveKey("server " + server.SayHelloStreamMethod + " OK"))
+	})
+})
+
+func attributeMap(kvs []attribute.KeyValue) map[attribute.Key]string {
+	m := make(map[attribute.Key]string, len(kvs))
+	for _, kv := range kvs {
+		m[kv.Key] = kv.Value.Emit()
+	}
+	return m
+}
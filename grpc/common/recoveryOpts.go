@@ -1,84 +1,219 @@
 package common
 
 import (
+	"context"
 	"fmt"
+	log "log/slog"
 	"net/url"
 	"os"
 	"runtime/debug"
+	"strconv"
 	"strings"
 
+	httpcommon "github.com/Azure/aks-middleware/http/common"
+	"github.com/Azure/aks-middleware/http/common/panicreport"
+	"github.com/Azure/aks-middleware/http/common/scrub"
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/recovery"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 )
 
-func ParseStack(stack string) (string, string) {
-	lines := strings.Split(stack, "panic.go") // split the stack into before panic and after panic
-	trace := strings.Split(lines[1], "\n")    // split trace into lines
-	// Example Input
-	// panic({0xac4000, 0xd00610})
-	// /usr/local/go1.19/src/runtime/panic.go:884 +0x212
-	// go.goms.io/aks/rp/mygreeterv3/server/internal/server.(*Server).SayHello (0x0?, {0xd0a960?, 0xc0007fa270?}, 0xc00073d1d0)
-	// /root/aks-rp/mygreeterv3/server/internal/server/api.go:34 +0x299
-	var file = ""
-	var linenum = ""
-	for _, line := range trace {
-		// file name and number of what caused panic
-		if strings.Contains(line, ".go:") {
-			parts := strings.Split(line, " ")
-			fileAndLine := strings.Split(parts[0], ":")
-			file = strings.TrimSpace(fileAndLine[0])
-			linenum = fileAndLine[1]
-			break
-		}
+// SourceLinkFormat selects how the link to the source line of a panic is built.
+type SourceLinkFormat int
+
+const (
+	// SourceLinkNone doesn't generate a source link.
+	SourceLinkNone SourceLinkFormat = iota
+	// SourceLinkADO generates an Azure DevOps link, e.g. <repo>?path=/a/b.go&version=GBmaster&line=34.
+	SourceLinkADO
+	// SourceLinkGitHub generates a GitHub link, e.g. <repo>/blob/main/a/b.go#L34.
+	SourceLinkGitHub
+)
+
+// VersionSource selects where the version used in the source link comes from.
+type VersionSource int
+
+const (
+	// VersionFromEnv reads the version (a branch) from the VersionEnvVar environment variable.
+	VersionFromEnv VersionSource = iota
+	// VersionFromBuildInfo reads the vcs.revision (a commit) stamped by the go toolchain.
+	VersionFromBuildInfo
+	// VersionFromLDFlags uses RecoveryConfig.Version, typically a variable set with -ldflags "-X ...".
+	VersionFromLDFlags
+)
+
+const (
+	defaultADORepoURL        = "https://msazure.visualstudio.com/CloudNativeCompute/_git/aks-rp"
+	defaultRepoRootMarker    = "aks-rp"
+	defaultVersionEnvVar     = "AKS_BIN_VERSION_GITBRANCH"
+	defaultGitHubVersion     = "main"
+	sanitizedPanicMessageFmt = "internal error, request id: %s"
+)
+
+// RecoveryConfig configures the panic recovery handler.
+type RecoveryConfig struct {
+	// SourceLink selects the format of the source link. SourceLinkNone disables it.
+	SourceLink SourceLinkFormat
+	// RepoURL is the base URL of the repository, e.g. https://github.com/Azure/aks-middleware.
+	RepoURL string
+	// RepoRootMarker is the path segment of the repository root in the file paths of the stack.
+	// The part of the path after the marker is used in the source link.
+	// No link is generated when the file is not under the marker.
+	RepoRootMarker string
+	// VersionSource selects where the version in the source link comes from.
+	VersionSource VersionSource
+	// VersionEnvVar is the environment variable read with VersionFromEnv.
+	VersionEnvVar string
+	// Version is the version used with VersionFromLDFlags.
+	Version string
+	// Verbose returns the panic message, file, line and source link to the client.
+	// Otherwise the client only gets the request id and the details are written to the CtxLog.
+	Verbose bool
+	// GetLogger returns the logger the panic details are written to. Defaults to slog.Default().
+	// The interceptor package sets it to ctxlogger.GetLogger.
+	GetLogger func(ctx context.Context) *log.Logger
+}
+
+// DefaultRecoveryConfig returns the configuration used by GetRecoveryOpts: an aks-rp ADO link
+// with the branch from AKS_BIN_VERSION_GITBRANCH, and the panic details returned to the client.
+func DefaultRecoveryConfig() RecoveryConfig {
+	return RecoveryConfig{
+		SourceLink:     SourceLinkADO,
+		RepoURL:        defaultADORepoURL,
+		RepoRootMarker: defaultRepoRootMarker,
+		VersionSource:  VersionFromEnv,
+		VersionEnvVar:  defaultVersionEnvVar,
+		Verbose:        true,
 	}
+}
 
-	return file, linenum
+// ParseStack returns the file and line number of the panic site in stack.
+// Empty strings are returned when stack contains no frame.
+func ParseStack(stack string) (string, string) {
+	site, ok := panicreport.New(nil, stack).Site()
+	if !ok {
+		return "", ""
+	}
+	return site.File, strconv.Itoa(site.Line)
 }
 
+// GetRecoveryOpts returns the recovery options for DefaultRecoveryConfig.
 func GetRecoveryOpts() []recovery.Option {
-	getFileAndLineNum := func(p any) (err error) {
-		// get the file and line number where the panic occurred
+	return GetRecoveryOptsWithConfig(DefaultRecoveryConfig())
+}
+
+// GetRecoveryOptsWithConfig returns the recovery options for config.
+func GetRecoveryOptsWithConfig(config RecoveryConfig) []recovery.Option {
+	handler := func(ctx context.Context, p any) (err error) {
+		// get the panic site and the application frames
 		// panic is terminated by custom recovery function and program continues
-		stack := debug.Stack()
-		file, linenum := ParseStack(string(stack))
-
-		// TODO: allow users to customize or we auto populate this repo path.
-		base := "https://msazure.visualstudio.com/CloudNativeCompute/_git/aks-rp"
-		path := file
-		if strings.Contains(path, "aks-rp") {
-			filepath := strings.SplitN(file, "aks-rp", 2)
-			path = filepath[1]
+		report := panicreport.Capture(p)
+		count := panicreport.Record(report)
+		var file, linenum string
+		if site, ok := report.Site(); ok {
+			file, linenum = site.File, strconv.Itoa(site.Line)
 		}
+		sourceURL := config.SourceURL(file, linenum)
+		requestID := requestIDFromContext(ctx)
 
-		version := "GB" + os.Getenv("AKS_BIN_VERSION_GITBRANCH")
+		logger := log.Default()
+		if config.GetLogger != nil {
+			logger = config.GetLogger(ctx)
+		}
+		logger = scrub.Logger(logger)
+		logger.ErrorContext(ctx, "panic recovered",
+			"panic_message", report.Value,
+			"file", file,
+			"line", linenum,
+			"url", sourceURL,
+			"fingerprint", report.Fingerprint,
+			"panic_count", count,
+			"stack", report.Frames,
+			httpcommon.RequestIDLogKey, requestID,
+		)
 
+		if !config.Verbose {
+			return status.Errorf(codes.Internal, sanitizedPanicMessageFmt, requestID)
+		}
+		// format the error message with the file and line number
+		return status.Errorf(codes.Internal, "panic_message: %v, file: %s, line: %s, url: %s", p, file, linenum, sourceURL)
+	}
+	opts := []recovery.Option{
+		recovery.WithRecoveryHandlerContext(handler),
+	}
+
+	return opts
+}
+
+// SourceURL returns the link to file:line, or an empty string when no link can be generated.
+func (c RecoveryConfig) SourceURL(file, linenum string) string {
+	if c.SourceLink == SourceLinkNone || c.RepoURL == "" || c.RepoRootMarker == "" {
+		return ""
+	}
+	// url is not generated as file is not in the repository
+	_, path, found := strings.Cut(file, c.RepoRootMarker)
+	if !found {
+		return ""
+	}
+	base := strings.TrimSuffix(c.RepoURL, "/")
+	version := c.version()
+
+	switch c.SourceLink {
+	case SourceLinkGitHub:
+		if version == "" {
+			version = defaultGitHubVersion
+		}
+		return fmt.Sprintf("%s/blob/%s/%s#L%s", base, version, strings.TrimPrefix(path, "/"), linenum)
+	case SourceLinkADO:
+		// ADO versions are prefixed with GB for branches and GC for commits.
+		prefix := "GB"
+		if c.VersionSource == VersionFromBuildInfo {
+			prefix = "GC"
+		}
 		params := url.Values{}
 		params.Add("path", path)
-
-		params.Add("version", version)
+		params.Add("version", prefix+version)
 		params.Add("line", linenum)
 
 		query, err := url.QueryUnescape(params.Encode())
 		if err != nil {
-			fmt.Println(err)
 			// skip query if url.QueryUnescape() fails
-			query = ""
+			return base
 		}
+		return base + "?" + query
+	}
+	return ""
+}
 
-		url := ""
-		// url is not generated as file is not in aks-rp
-		if path != file {
-			url = base + "?" + query
+func (c RecoveryConfig) version() string {
+	switch c.VersionSource {
+	case VersionFromEnv:
+		return os.Getenv(c.VersionEnvVar)
+	case VersionFromBuildInfo:
+		info, ok := debug.ReadBuildInfo()
+		if !ok {
+			return ""
+		}
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				return setting.Value
+			}
 		}
+		return ""
+	case VersionFromLDFlags:
+		return c.Version
+	}
+	return ""
+}
 
-		// format the error message with the file and line number
-		return status.Errorf(codes.Internal, "panic_message: %v, file: %s, line: %s, url: %s", p, file, linenum, url)
+func requestIDFromContext(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
 	}
-	opts := []recovery.Option{
-		recovery.WithRecoveryHandler(getFileAndLineNum),
+	if vals := md.Get(httpcommon.RequestIDMetadataHeader); len(vals) > 0 {
+		return vals[0]
 	}
-
-	return opts
-
+	return ""
 }
@@ -1,8 +1,18 @@
 package common
 
 import (
+	"bytes"
+	"context"
+	log "log/slog"
+
+	httpcommon "github.com/Azure/aks-middleware/http/common"
+	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/recovery"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 var _ = Describe("Recovery test", func() {
@@ -60,5 +70,86 @@ var _ = Describe("Recovery test", func() {
 			Expect(file).To(ContainSubstring("api.go"))
 			Expect(line).To(Equal("34"))
 		})
+
+		It("should not fail when the stack has no panic", func() {
+			file, line := ParseStack("goroutine 1 [running]:")
+			Expect(file).To(BeEmpty())
+			Expect(line).To(BeEmpty())
+		})
+	})
+
+	Context("when building the source link", func() {
+		const file = "/root/aks-rp/mygreeterv3/server/internal/server/api.go"
+
+		It("should keep the default ADO link", func() {
+			GinkgoT().Setenv("AKS_BIN_VERSION_GITBRANCH", "master")
+			url := DefaultRecoveryConfig().SourceURL(file, "34")
+			Expect(url).To(Equal("https://msazure.visualstudio.com/CloudNativeCompute/_git/aks-rp?line=34&path=/mygreeterv3/server/internal/server/api.go&version=GBmaster"))
+		})
+
+		It("should generate a GitHub link with the ldflags version", func() {
+			config := RecoveryConfig{
+				SourceLink:     SourceLinkGitHub,
+				RepoURL:        "https://github.com/Azure/aks-rp/",
+				RepoRootMarker: "aks-rp",
+				VersionSource:  VersionFromLDFlags,
+				Version:        "v1.2.3",
+			}
+			Expect(config.SourceURL(file, "34")).To(Equal("https://github.com/Azure/aks-rp/blob/v1.2.3/mygreeterv3/server/internal/server/api.go#L34"))
+		})
+
+		It("should not generate a link outside of the repository or when disabled", func() {
+			config := DefaultRecoveryConfig()
+			Expect(config.SourceURL("/usr/local/go/src/runtime/panic.go", "884")).To(BeEmpty())
+			config.SourceLink = SourceLinkNone
+			Expect(config.SourceURL(file, "34")).To(BeEmpty())
+		})
+	})
+
+	Context("when recovering from a panic", func() {
+		var (
+			ctxOutput *bytes.Buffer
+			config    RecoveryConfig
+			ctx       context.Context
+		)
+
+		BeforeEach(func() {
+			ctxOutput = &bytes.Buffer{}
+			config = DefaultRecoveryConfig()
+			config.GetLogger = func(ctx context.Context) *log.Logger {
+				return log.New(log.NewJSONHandler(ctxOutput, nil))
+			}
+			ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs(httpcommon.RequestIDMetadataHeader, "req-id"))
+		})
+
+		invoke := func() error {
+			intercept := recovery.UnaryServerInterceptor(GetRecoveryOptsWithConfig(config)...)
+			_, err := intercept(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/MyGreeter/SayHello"},
+				func(ctx context.Context, req any) (any, error) {
+					panic("secret panic value")
+				})
+			return err
+		}
+
+		It("should return the panic details in verbose mode", func() {
+			err := invoke()
+			Expect(status.Code(err)).To(Equal(codes.Internal))
+			Expect(err.Error()).To(ContainSubstring("panic_message: secret panic value"))
+			Expect(err.Error()).To(ContainSubstring("recoveryOpts_test.go"))
+		})
+
+		It("should only return the request id otherwise and log the details", func() {
+			config.Verbose = false
+			err := invoke()
+			Expect(status.Code(err)).To(Equal(codes.Internal))
+			Expect(status.Convert(err).Message()).To(Equal("internal error, request id: req-id"))
+
+			Expect(ctxOutput.String()).To(ContainSubstring("panic recovered"))
+			Expect(ctxOutput.String()).To(ContainSubstring("secret panic value"))
+			Expect(ctxOutput.String()).To(ContainSubstring("recoveryOpts_test.go"))
+			Expect(ctxOutput.String()).To(ContainSubstring(`"request-id":"req-id"`))
+			Expect(ctxOutput.String()).To(ContainSubstring(`"fingerprint":`))
+			Expect(ctxOutput.String()).To(ContainSubstring(`"stack":[`))
+		})
 	})
 })
@@ -0,0 +1,266 @@
+package common
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	httpcommon "github.com/Azure/aks-middleware/http/common"
+	"github.com/gofrs/uuid"
+	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
+	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/retry"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// AttemptLogKey is the key of the attempt number, starting at 1, in the finished call log of every attempt.
+const AttemptLogKey = "attempt"
+
+// NonRetriableReason is the ErrorInfo reason of the errors that are never retried whatever their code, e.g. the
+// calls failed fast by an open circuit breaker.
+const NonRetriableReason = "NON_RETRIABLE"
+
+// ErrorInfoDomain is the ErrorInfo domain of the errors of this module.
+const ErrorInfoDomain = "aks-middleware"
+
+// NonRetriableError returns a status error with code and msg that the retry interceptors don't retry.
+func NonRetriableError(code codes.Code, msg string) error {
+	st, err := status.New(code, msg).WithDetails(&errdetails.ErrorInfo{
+		Reason: NonRetriableReason,
+		Domain: ErrorInfoDomain,
+	})
+	if err != nil {
+		return status.Error(code, msg)
+	}
+	return st.Err()
+}
+
+// NonRetriable returns whether err was created by NonRetriableError.
+func NonRetriable(err error) bool {
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.GetReason() == NonRetriableReason && info.GetDomain() == ErrorInfoDomain {
+			return true
+		}
+	}
+	return false
+}
+
+// RetryPolicy configures the retries of a method. Zero values use the defaults of GetRetryOptions.
+type RetryPolicy struct {
+	// Codes are the retried status codes. Defaults to Aborted and Unavailable.
+	Codes []codes.Code
+	// MaxAttempts is the number of attempts, including the first one. Defaults to 3. Set it to 1 to disable retries.
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry. Defaults to 100ms.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the wait between attempts. Defaults to 5s.
+	MaxBackoff time.Duration
+	// Multiplier multiplies the wait after every attempt. Defaults to 2.
+	Multiplier float64
+	// Jitter is the fraction of the wait randomized in both directions, in [0, 1]. Defaults to 0.2.
+	Jitter float64
+}
+
+// RetryPolicies are the retry policies of the methods of a client.
+type RetryPolicies struct {
+	// Default is the policy of the methods missing from Methods.
+	Default RetryPolicy
+	// Methods are the policies per gRPC full method, e.g. "/package.Service/Method".
+	Methods map[string]RetryPolicy
+	// Budget caps the retries to a fraction of the calls when set. Share it between clients for a process-wide budget.
+	Budget *RetryBudget
+}
+
+// For returns the policy of method with the defaults applied.
+func (p RetryPolicies) For(method string) RetryPolicy {
+	policy, ok := p.Methods[method]
+	if !ok {
+		policy = p.Default
+	}
+	if len(policy.Codes) == 0 {
+		policy.Codes = []codes.Code{codes.Aborted, codes.Unavailable}
+	}
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = 3
+	}
+	if policy.InitialBackoff <= 0 {
+		policy.InitialBackoff = 100 * time.Millisecond
+	}
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = 5 * time.Second
+	}
+	if policy.Multiplier < 1 {
+		policy.Multiplier = 2
+	}
+	if policy.Jitter <= 0 || policy.Jitter > 1 {
+		policy.Jitter = 0.2
+	}
+	return policy
+}
+
+// Backoff returns the jittered wait before retrying after attempt, starting at 1.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	backoff := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(attempt-1))
+	backoff = math.Min(backoff, float64(p.MaxBackoff))
+	backoff *= 1 + p.Jitter*(2*rand.Float64()-1)
+	return time.Duration(backoff)
+}
+
+func (p RetryPolicy) retryable(err error) bool {
+	return slices.Contains(p.Codes, status.Code(err)) && !NonRetriable(err)
+}
+
+// RetryBudget caps the retries to a fraction of the calls. Every call deposits Ratio tokens, up to MaxTokens,
+// and every retry withdraws one. Retries are skipped while there is less than one token.
+type RetryBudget struct {
+	ratio     float64
+	maxTokens float64
+
+	mu     sync.Mutex
+	tokens float64
+}
+
+// NewRetryBudget returns a budget allowing retries for ratio of the calls, e.g. 0.1, and bursts of up to
+// maxTokens retries. The budget starts full.
+func NewRetryBudget(ratio float64, maxTokens int) *RetryBudget {
+	return &RetryBudget{
+		ratio:     ratio,
+		maxTokens: float64(maxTokens),
+		tokens:    float64(maxTokens),
+	}
+}
+
+func (b *RetryBudget) deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = math.Min(b.maxTokens, b.tokens+b.ratio)
+}
+
+func (b *RetryBudget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// DefaultRetryPolicies returns the policies equivalent to GetRetryOptions, with jittered backoff.
+func DefaultRetryPolicies() RetryPolicies {
+	return RetryPolicies{}
+}
+
+// UnaryClientRetryInterceptor returns a client interceptor retrying the calls with the policy of their method.
+// A retry-after trailer or RetryInfo status details sent by the server replace the backoff, and the call isn't
+// retried when that wait exceeds its deadline. Every attempt is logged by the logging interceptor with its number
+// in AttemptLogKey and the x-ms-client-request-id metadata, generated when missing, is the same for all attempts.
+// Register it before the logging interceptor.
+func UnaryClientRetryInterceptor(policies RetryPolicies) grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		callOpts ...grpc.CallOption,
+	) error {
+		policy := policies.For(method)
+		ctx = withClientRequestID(ctx)
+		if policies.Budget != nil {
+			policies.Budget.deposit()
+		}
+		for attempt := 1; ; attempt++ {
+			var trailer metadata.MD
+			attemptCtx := logging.InjectFields(ctx, logging.Fields{AttemptLogKey, attempt})
+			err := invoker(attemptCtx, method, req, reply, cc, append(callOpts, grpc.Trailer(&trailer))...)
+			if err == nil || attempt >= policy.MaxAttempts || !policy.retryable(err) {
+				return err
+			}
+			wait, ok := pushback(err, trailer)
+			if !ok {
+				wait = policy.Backoff(attempt)
+			}
+			if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+				return err
+			}
+			if policies.Budget != nil && !policies.Budget.withdraw() {
+				return err
+			}
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return err
+			case <-timer.C:
+			}
+		}
+	}
+}
+
+// StreamClientRetryPolicyInterceptor is the streaming counterpart of UnaryClientRetryInterceptor. It retries
+// server streams with the codes, attempts and backoff of the policy of their method, see StreamClientRetryInterceptor.
+// Server pushback and the budget only apply to unary calls: stream retries wait for the backoff and aren't
+// charged to the Budget.
+func StreamClientRetryPolicyInterceptor(policies RetryPolicies) grpc.StreamClientInterceptor {
+	retryInterceptor := StreamClientRetryInterceptor()
+	return func(
+		ctx context.Context,
+		desc *grpc.StreamDesc,
+		cc *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		callOpts ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		policy := policies.For(method)
+		callOpts = append(callOpts,
+			retry.WithRetriable(policy.retryable),
+			retry.WithMax(uint(policy.MaxAttempts)),
+			retry.WithBackoff(func(_ context.Context, attempt uint) time.Duration {
+				return policy.Backoff(int(attempt))
+			}),
+		)
+		return retryInterceptor(withClientRequestID(ctx), desc, cc, method, streamer, callOpts...)
+	}
+}
+
+// pushback returns the wait the server asked for in the retry-after trailer or the RetryInfo details of err.
+func pushback(err error, trailer metadata.MD) (time.Duration, bool) {
+	if vals := trailer.Get(httpcommon.RetryAfterKey); len(vals) > 0 {
+		if seconds, err := strconv.ParseFloat(vals[0], 64); err == nil && seconds >= 0 {
+			return time.Duration(seconds * float64(time.Second)), true
+		}
+	}
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok && info.GetRetryDelay() != nil {
+			return info.GetRetryDelay().AsDuration(), true
+		}
+	}
+	return 0, false
+}
+
+// withClientRequestID returns ctx with the x-ms-client-request-id outgoing metadata, so that it is the same for
+// every attempt. Like mdforward, the incoming metadata is forwarded when there is no outgoing metadata.
+func withClientRequestID(ctx context.Context) context.Context {
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if !ok {
+		md, ok = metadata.FromIncomingContext(ctx)
+	}
+	if ok && len(md.Get(httpcommon.RequestARMClientRequestIDHeader)) > 0 {
+		return metadata.NewOutgoingContext(ctx, md)
+	}
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.MD{}
+	}
+	md.Set(httpcommon.RequestARMClientRequestIDHeader, uuid.Must(uuid.NewV4()).String())
+	return metadata.NewOutgoingContext(ctx, md)
+}
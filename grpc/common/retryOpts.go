@@ -1,9 +1,11 @@
 package common
 
 import (
+	"context"
 	"time"
 
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/retry"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 )
 
@@ -16,3 +18,23 @@ func GetRetryOptions() []retry.CallOption {
 
 	return retryOpts
 }
+
+// StreamClientRetryInterceptor returns a retry interceptor for streams.
+// Only server streams are retried. The messages of a client stream can't be replayed,
+// so client and bidi streams bypass the retry interceptor instead of failing with Unimplemented.
+func StreamClientRetryInterceptor(opts ...retry.CallOption) grpc.StreamClientInterceptor {
+	retryInterceptor := retry.StreamClientInterceptor(opts...)
+	return func(
+		ctx context.Context,
+		desc *grpc.StreamDesc,
+		cc *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		callOpts ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		if desc.ClientStreams {
+			return streamer(ctx, desc, cc, method, callOpts...)
+		}
+		return retryInterceptor(ctx, desc, cc, method, streamer, callOpts...)
+	}
+}
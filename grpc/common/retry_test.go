@@ -0,0 +1,183 @@
+package common_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/aks-middleware/grpc/common"
+	"github.com/Azure/aks-middleware/grpc/interceptor"
+	httpcommon "github.com/Azure/aks-middleware/http/common"
+	pb "github.com/Azure/aks-middleware/test/api/v1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// retriedServer fails SayHello with err and records the client request id of every call.
+type retriedServer struct {
+	pb.UnimplementedMyGreeterServer
+	err        error
+	retryAfter string
+
+	mu               sync.Mutex
+	clientRequestIDs []string
+}
+
+func (s *retriedServer) SayHello(ctx context.Context, req *pb.HelloRequest) (*pb.HelloReply, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.mu.Lock()
+	s.clientRequestIDs = append(s.clientRequestIDs, strings.Join(md.Get(httpcommon.RequestARMClientRequestIDHeader), ","))
+	s.mu.Unlock()
+	if s.retryAfter != "" {
+		_ = grpc.SetTrailer(ctx, metadata.Pairs(httpcommon.RetryAfterKey, s.retryAfter))
+	}
+	return nil, s.err
+}
+
+func (s *retriedServer) calls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.clientRequestIDs)
+}
+
+var _ = Describe("Retry policies", func() {
+	var (
+		srv        *retriedServer
+		grpcServer *grpc.Server
+		lis        net.Listener
+		apiOutput  *bytes.Buffer
+		req        *pb.HelloRequest
+	)
+
+	BeforeEach(func() {
+		var err error
+		lis, err = net.Listen("tcp", "localhost:0")
+		Expect(err).ToNot(HaveOccurred())
+		srv = &retriedServer{err: status.Error(codes.Unavailable, "unavailable")}
+		grpcServer = grpc.NewServer()
+		pb.RegisterMyGreeterServer(grpcServer, srv)
+		go func() {
+			_ = grpcServer.Serve(lis)
+		}()
+		apiOutput = &bytes.Buffer{}
+		req = &pb.HelloRequest{Name: "Test", Age: 30, Email: "test@test.com"}
+	})
+
+	AfterEach(func() {
+		grpcServer.Stop()
+	})
+
+	newClient := func(policies *common.RetryPolicies) pb.MyGreeterClient {
+		options := interceptor.GetClientInterceptorLogOptions(slog.New(slog.NewJSONHandler(apiOutput, nil)), nil)
+		options.APIOutput = apiOutput
+		options.Retry = policies
+		clientConn, err := grpc.NewClient(lis.Addr().String(),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithChainUnaryInterceptor(interceptor.DefaultClientInterceptors(options)...),
+		)
+		Expect(err).ToNot(HaveOccurred())
+		DeferCleanup(clientConn.Close)
+		return pb.NewMyGreeterClient(clientConn)
+	}
+
+	It("should log every attempt with the same client request id", func() {
+		client := newClient(&common.RetryPolicies{
+			Default: common.RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Millisecond},
+			Methods: map[string]common.RetryPolicy{
+				pb.MyGreeter_SayHello_FullMethodName: {MaxAttempts: 2, InitialBackoff: time.Millisecond},
+			},
+		})
+		_, err := client.SayHello(context.Background(), req)
+		Expect(status.Code(err)).To(Equal(codes.Unavailable))
+		Expect(srv.calls()).To(Equal(2))
+		Expect(srv.clientRequestIDs[0]).ToNot(BeEmpty())
+		Expect(srv.clientRequestIDs[1]).To(Equal(srv.clientRequestIDs[0]))
+
+		lines := strings.Split(strings.TrimSpace(apiOutput.String()), "\n")
+		Expect(lines).To(HaveLen(2))
+		Expect(lines[0]).To(ContainSubstring(`"attempt":1`))
+		Expect(lines[1]).To(ContainSubstring(`"attempt":2`))
+	})
+
+	It("should keep the client request id of the caller", func() {
+		client := newClient(&common.RetryPolicies{Default: common.RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}})
+		ctx := metadata.AppendToOutgoingContext(context.Background(), httpcommon.RequestARMClientRequestIDHeader, "caller-id")
+		_, err := client.SayHello(ctx, req)
+		Expect(err).To(HaveOccurred())
+		Expect(srv.clientRequestIDs).To(Equal([]string{"caller-id", "caller-id"}))
+	})
+
+	It("should not retry the codes missing from the policy", func() {
+		srv.err = status.Error(codes.InvalidArgument, "invalid")
+		client := newClient(nil)
+		_, err := client.SayHello(context.Background(), req)
+		Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+		Expect(srv.calls()).To(Equal(1))
+	})
+
+	It("should wait for the RetryInfo delay instead of the backoff", func() {
+		st, err := status.New(codes.ResourceExhausted, "throttled").WithDetails(&errdetails.RetryInfo{
+			RetryDelay: durationpb.New(10 * time.Millisecond),
+		})
+		Expect(err).ToNot(HaveOccurred())
+		srv.err = st.Err()
+		client := newClient(&common.RetryPolicies{Default: common.RetryPolicy{
+			Codes:          []codes.Code{codes.ResourceExhausted},
+			MaxAttempts:    2,
+			InitialBackoff: time.Minute,
+		}})
+		start := time.Now()
+		_, err = client.SayHello(context.Background(), req)
+		Expect(status.Code(err)).To(Equal(codes.ResourceExhausted))
+		Expect(srv.calls()).To(Equal(2))
+		Expect(time.Since(start)).To(BeNumerically(">=", 10*time.Millisecond))
+		Expect(time.Since(start)).To(BeNumerically("<", time.Minute))
+	})
+
+	It("should not retry when the retry-after trailer exceeds the deadline", func() {
+		srv.retryAfter = "10"
+		client := newClient(&common.RetryPolicies{Default: common.RetryPolicy{InitialBackoff: time.Millisecond}})
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_, err := client.SayHello(ctx, req)
+		Expect(status.Code(err)).To(Equal(codes.Unavailable))
+		Expect(srv.calls()).To(Equal(1))
+	})
+
+	It("should stop retrying when the budget is spent", func() {
+		client := newClient(&common.RetryPolicies{
+			Default: common.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond},
+			Budget:  common.NewRetryBudget(0, 1),
+		})
+		_, err := client.SayHello(context.Background(), req)
+		Expect(err).To(HaveOccurred())
+		Expect(srv.calls()).To(Equal(2))
+		_, err = client.SayHello(context.Background(), req)
+		Expect(err).To(HaveOccurred())
+		Expect(srv.calls()).To(Equal(3))
+	})
+})
+
+var _ = Describe("RetryPolicy", func() {
+	It("should back off exponentially with jitter up to MaxBackoff", func() {
+		policy := common.RetryPolicies{Default: common.RetryPolicy{
+			InitialBackoff: 100 * time.Millisecond,
+			MaxBackoff:     300 * time.Millisecond,
+			Jitter:         0.1,
+		}}.For("/any")
+		Expect(policy.Backoff(1)).To(BeNumerically("~", 100*time.Millisecond, 10*time.Millisecond))
+		Expect(policy.Backoff(2)).To(BeNumerically("~", 200*time.Millisecond, 20*time.Millisecond))
+		Expect(policy.Backoff(5)).To(BeNumerically("~", 300*time.Millisecond, 30*time.Millisecond))
+	})
+})
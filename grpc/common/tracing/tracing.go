@@ -0,0 +1,202 @@
+package tracing
+
+// This package creates OpenTelemetry spans for gRPC servers and clients.
+// Spans are named after the gRPC full method and carry the ids the other interceptors log.
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+
+	httptracing "github.com/Azure/aks-middleware/http/common/tracing"
+	middleware "github.com/grpc-ecosystem/go-grpc-middleware/v2"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor returns a server interceptor that extracts the trace context from the
+// incoming metadata and starts a server span for each call.
+// Register it after the requestid interceptor so the span carries the request id.
+func UnaryServerInterceptor(tp trace.TracerProvider) grpc.UnaryServerInterceptor {
+	tracer := httptracing.Tracer(tp)
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (any, error) {
+		ctx, span := startServerSpan(ctx, tracer, info.FullMethod)
+		defer span.End()
+
+		resp, err := handler(ctx, req)
+		endSpan(span, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor is the streaming counterpart of UnaryServerInterceptor.
+func StreamServerInterceptor(tp trace.TracerProvider) grpc.StreamServerInterceptor {
+	tracer := httptracing.Tracer(tp)
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+		ctx, span := startServerSpan(ss.Context(), tracer, info.FullMethod)
+		defer span.End()
+
+		wrapped := middleware.WrapServerStream(ss)
+		wrapped.WrappedContext = ctx
+		err := handler(srv, wrapped)
+		endSpan(span, err)
+		return err
+	}
+}
+
+// UnaryClientInterceptor returns a client interceptor that starts a client span for each call
+// and injects the trace context into the outgoing metadata.
+// Register it after mdforward, which only forwards the incoming metadata when there is no outgoing metadata.
+func UnaryClientInterceptor(tp trace.TracerProvider) grpc.UnaryClientInterceptor {
+	tracer := httptracing.Tracer(tp)
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		callOpts ...grpc.CallOption,
+	) error {
+		ctx, span := startClientSpan(ctx, tracer, method)
+		defer span.End()
+
+		err := invoker(ctx, method, req, reply, cc, callOpts...)
+		endSpan(span, err)
+		return err
+	}
+}
+
+// StreamClientInterceptor is the streaming counterpart of UnaryClientInterceptor.
+// The span ends when the stream finishes.
+func StreamClientInterceptor(tp trace.TracerProvider) grpc.StreamClientInterceptor {
+	tracer := httptracing.Tracer(tp)
+	return func(
+		ctx context.Context,
+		desc *grpc.StreamDesc,
+		cc *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		callOpts ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		ctx, span := startClientSpan(ctx, tracer, method)
+		clientStream, err := streamer(ctx, desc, cc, method, callOpts...)
+		if err != nil {
+			endSpan(span, err)
+			span.End()
+			return nil, err
+		}
+		return &tracedClientStream{
+			ClientStream:    clientStream,
+			span:            span,
+			hasServerStream: desc.ServerStreams,
+		}, nil
+	}
+}
+
+// tracedClientStream ends the client span when the stream finishes.
+type tracedClientStream struct {
+	grpc.ClientStream
+
+	span            trace.Span
+	hasServerStream bool
+	endOnce         sync.Once
+}
+
+func (s *tracedClientStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil || !s.hasServerStream {
+		s.endOnce.Do(func() {
+			if errors.Is(err, io.EOF) {
+				err = nil
+			}
+			endSpan(s.span, err)
+			s.span.End()
+		})
+	}
+	return err
+}
+
+func startServerSpan(ctx context.Context, tracer trace.Tracer, fullMethod string) (context.Context, trace.Span) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx = httptracing.Propagator.Extract(ctx, metadataCarrier(md))
+
+	attrs := append(methodAttributes(fullMethod), httptracing.MetadataAttributes(md)...)
+	return tracer.Start(ctx, spanName(fullMethod),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+func startClientSpan(ctx context.Context, tracer trace.Tracer, fullMethod string) (context.Context, trace.Span) {
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.MD{}
+	}
+
+	attrs := append(methodAttributes(fullMethod), httptracing.MetadataAttributes(md)...)
+	ctx, span := tracer.Start(ctx, spanName(fullMethod),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+	httptracing.Propagator.Inject(ctx, metadataCarrier(md))
+	return metadata.NewOutgoingContext(ctx, md), span
+}
+
+func endSpan(span trace.Span, err error) {
+	code := status.Code(err)
+	span.SetAttributes(attribute.Int("rpc.grpc.status_code", int(code)))
+	if code != codes.OK {
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, status.Convert(err).Message())
+	}
+}
+
+// spanName returns the full method without its leading slash, e.g. "package.Service/Method".
+func spanName(fullMethod string) string {
+	return strings.TrimPrefix(fullMethod, "/")
+}
+
+func methodAttributes(fullMethod string) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{attribute.String("rpc.system", "grpc")}
+	service, method, found := strings.Cut(spanName(fullMethod), "/")
+	if found {
+		attrs = append(attrs,
+			attribute.String("rpc.service", service),
+			attribute.String("rpc.method", method),
+		)
+	}
+	return attrs
+}
+
+// metadataCarrier adapts metadata.MD to propagation.TextMapCarrier.
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if vals := metadata.MD(c).Get(key); len(vals) > 0 {
+		return vals[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
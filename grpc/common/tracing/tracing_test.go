@@ -0,0 +1,112 @@
+package tracing_test
+
+import (
+	"context"
+	"net"
+
+	"github.com/Azure/aks-middleware/grpc/common/tracing"
+	"github.com/Azure/aks-middleware/grpc/server/requestid"
+	httpcommon "github.com/Azure/aks-middleware/http/common"
+	httptracing "github.com/Azure/aks-middleware/http/common/tracing"
+	pb "github.com/Azure/aks-middleware/test/api/v1"
+	"github.com/Azure/aks-middleware/test/server"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var _ = Describe("Tracing interceptors", func() {
+	var (
+		exporter   *tracetest.InMemoryExporter
+		tp         *sdktrace.TracerProvider
+		grpcServer *grpc.Server
+		lis        net.Listener
+		clientConn *grpc.ClientConn
+		client     pb.MyGreeterClient
+	)
+
+	BeforeEach(func() {
+		exporter = tracetest.NewInMemoryExporter()
+		tp = sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+		var err error
+		lis, err = net.Listen("tcp", "localhost:0")
+		Expect(err).ToNot(HaveOccurred())
+		grpcServer = grpc.NewServer(grpc.ChainUnaryInterceptor(
+			requestid.UnaryServerInterceptor(),
+			tracing.UnaryServerInterceptor(tp),
+		))
+		pb.RegisterMyGreeterServer(grpcServer, &server.TestServer{})
+		go func() {
+			_ = grpcServer.Serve(lis)
+		}()
+
+		clientConn, err = grpc.NewClient(lis.Addr().String(),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithChainUnaryInterceptor(tracing.UnaryClientInterceptor(tp)),
+		)
+		Expect(err).ToNot(HaveOccurred())
+		client = pb.NewMyGreeterClient(clientConn)
+	})
+
+	AfterEach(func() {
+		clientConn.Close()
+		grpcServer.Stop()
+		lis.Close()
+	})
+
+	It("should create client and server spans in the same trace", func() {
+		ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs(
+			httpcommon.CorrelationIDKey, "corr-id",
+			httpcommon.OperationIDKey, "op-id",
+		))
+		_, err := client.SayHello(ctx, &pb.HelloRequest{Name: "Tracing"})
+		Expect(err).ToNot(HaveOccurred())
+
+		spans := exporter.GetSpans()
+		Expect(spans).To(HaveLen(2))
+		serverSpan, clientSpan := spans[0], spans[1]
+		if serverSpan.SpanKind != trace.SpanKindServer {
+			serverSpan, clientSpan = clientSpan, serverSpan
+		}
+
+		Expect(clientSpan.Name).To(Equal("MyGreeter/SayHello"))
+		Expect(clientSpan.SpanKind).To(Equal(trace.SpanKindClient))
+		Expect(serverSpan.Name).To(Equal("MyGreeter/SayHello"))
+		Expect(serverSpan.SpanContext.TraceID()).To(Equal(clientSpan.SpanContext.TraceID()))
+		Expect(serverSpan.Parent.SpanID()).To(Equal(clientSpan.SpanContext.SpanID()))
+
+		attrs := attributeMap(serverSpan.Attributes)
+		Expect(attrs).To(HaveKeyWithValue(httptracing.CorrelationIDAttributeKey, "corr-id"))
+		Expect(attrs).To(HaveKeyWithValue(httptracing.OperationIDAttributeKey, "op-id"))
+		Expect(attrs).To(HaveKey(httptracing.RequestIDAttributeKey))
+		Expect(attrs).To(HaveKeyWithValue(attribute.Key("rpc.method"), "SayHello"))
+	})
+
+	It("should record an error status for failed calls", func() {
+		err := clientConn.Invoke(context.Background(), "/MyGreeter/Missing", &pb.HelloRequest{}, &pb.HelloReply{})
+		Expect(status.Code(err)).To(Equal(codes.Unimplemented))
+
+		spans := exporter.GetSpans()
+		Expect(spans).To(HaveLen(1))
+		Expect(spans[0].SpanKind).To(Equal(trace.SpanKindClient))
+		Expect(spans[0].Status.Code).To(Equal(otelcodes.Error))
+	})
+})
+
+func attributeMap(kvs []attribute.KeyValue) map[attribute.Key]string {
+	m := make(map[attribute.Key]string, len(kvs))
+	for _, kv := range kvs {
+		m[kv.Key] = kv.Value.Emit()
+	}
+	return m
+}
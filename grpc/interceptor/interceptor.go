@@ -4,22 +4,39 @@ import (
 	"io"
 	"os"
 
+	"github.com/Azure/aks-middleware/grpc/client/circuitbreaker"
 	"github.com/Azure/aks-middleware/grpc/client/mdforward"
 	"github.com/Azure/aks-middleware/grpc/common"
 	"github.com/Azure/aks-middleware/grpc/common/autologger"
+	"github.com/Azure/aks-middleware/grpc/common/deadline"
+	"github.com/Azure/aks-middleware/grpc/common/metrics"
+	"github.com/Azure/aks-middleware/grpc/common/tracing"
+	"github.com/Azure/aks-middleware/grpc/server/auth"
+	"github.com/Azure/aks-middleware/grpc/server/authz"
+	"github.com/Azure/aks-middleware/grpc/server/bulkhead"
 	"github.com/Azure/aks-middleware/grpc/server/ctxlogger"
+	"github.com/Azure/aks-middleware/grpc/server/idempotency"
+	"github.com/Azure/aks-middleware/grpc/server/loadshed"
+	"github.com/Azure/aks-middleware/grpc/server/ratelimit"
 	"github.com/Azure/aks-middleware/grpc/server/requestid"
 	"github.com/Azure/aks-middleware/grpc/server/responseheader"
+	"github.com/Azure/aks-middleware/grpc/server/validation"
 	httpcommon "github.com/Azure/aks-middleware/http/common"
+	httpauthz "github.com/Azure/aks-middleware/http/common/authz"
+	httpcircuitbreaker "github.com/Azure/aks-middleware/http/common/circuitbreaker"
+	httpdeadline "github.com/Azure/aks-middleware/http/common/deadline"
+	httploadshed "github.com/Azure/aks-middleware/http/common/loadshed"
+	"github.com/Azure/aks-middleware/http/common/loglevel"
+	"github.com/Azure/aks-middleware/http/common/sampling"
 
 	log "log/slog"
 	"strings"
 
 	"buf.build/go/protovalidate"
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
-	protovalidate_middleware "github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/protovalidate"
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/recovery"
-	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/retry"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
 )
 
@@ -27,6 +44,22 @@ type ClientInterceptorLogOptions struct {
 	Logger     *log.Logger
 	APIOutput  io.Writer
 	Attributes []log.Attr
+	// TracerProvider enables OpenTelemetry client spans when set.
+	TracerProvider trace.TracerProvider
+	// MeterProvider enables request count, latency and in-flight metrics when set.
+	MeterProvider metric.MeterProvider
+	// ResponsePayloadLog adds the responses of the allowed unary methods to the ApiRequestLog when set.
+	ResponsePayloadLog *autologger.PayloadLogOptions
+	// Sampling samples the successful finished call records of the ApiRequestLog when set.
+	Sampling *sampling.Policy
+	// LogLevels makes the ApiRequestLog level adjustable at runtime when set.
+	LogLevels *loglevel.Controller
+	// DeadlineBudget forwards the remaining deadline minus a safety margin to the calls when set.
+	DeadlineBudget *httpdeadline.Budget
+	// CircuitBreaker fails the calls fast while the breaker of their target and method is open when set.
+	CircuitBreaker *httpcircuitbreaker.CircuitBreaker
+	// Retry sets the retry policies of the calls. Defaults to common.DefaultRetryPolicies().
+	Retry *common.RetryPolicies
 }
 
 type ServerInterceptorLogOptions struct {
@@ -35,6 +68,37 @@ type ServerInterceptorLogOptions struct {
 	CtxOutput     io.Writer
 	APIAttributes []log.Attr
 	CtxAttributes []log.Attr
+	// TracerProvider enables OpenTelemetry server spans when set.
+	TracerProvider trace.TracerProvider
+	// MeterProvider enables request count, latency and in-flight metrics when set.
+	MeterProvider metric.MeterProvider
+	// ResponsePayloadLog adds the responses of the allowed unary methods to the ApiRequestLog when set.
+	ResponsePayloadLog *autologger.PayloadLogOptions
+	// Sampling samples the successful finished call records of the ApiRequestLog when set.
+	Sampling *sampling.Policy
+	// Recovery configures the panic recovery handler. Defaults to common.DefaultRecoveryConfig().
+	Recovery *common.RecoveryConfig
+	// LogLevels makes the ApiRequestLog and CtxLog levels adjustable at runtime when set.
+	LogLevels *loglevel.Controller
+	// Deadlines applies per-method timeouts to the calls when set.
+	Deadlines *httpdeadline.Timeouts
+	// RateLimit rate limits the calls per key when set.
+	RateLimit *ratelimit.Options
+	// LoadShedding rejects calls over its adaptive concurrency limit when set.
+	LoadShedding *httploadshed.Limiter
+	// Bulkhead runs the calls in separate concurrency pools per class when set.
+	Bulkhead *bulkhead.Options
+	// Auth rejects the calls without a valid bearer token when set.
+	Auth *auth.Options
+	// Authz rejects the calls denied by its rules when set. It needs Auth to identify the callers,
+	// and the SkipMethods of Auth aren't authorized.
+	Authz *httpauthz.Policy
+	// Validator validates the requests. Defaults to protovalidate.New().
+	Validator protovalidate.Validator
+	// ValidateResponses also validates the responses when set. Meant for non-production environments.
+	ValidateResponses bool
+	// Idempotency replays the responses of the retried write calls when set. Unary calls only.
+	Idempotency *idempotency.Options
 }
 
 func GetClientInterceptorLogOptions(logger *log.Logger, attrs []log.Attr) ClientInterceptorLogOptions {
@@ -56,9 +120,87 @@ func GetServerInterceptorLogOptions(logger *log.Logger, attrs []log.Attr) Server
 }
 
 func DefaultClientInterceptors(options ClientInterceptorLogOptions) []grpc.UnaryClientInterceptor {
+	apiRequestLogger := newClientLogger(options)
+	interceptors := []grpc.UnaryClientInterceptor{
+		common.UnaryClientRetryInterceptor(retryPolicies(options)),
+		mdforward.UnaryClientInterceptor(),
+	}
+	if options.TracerProvider != nil {
+		// Registered after mdforward so the trace context is added to the forwarded MD.
+		interceptors = append(interceptors, tracing.UnaryClientInterceptor(options.TracerProvider))
+	}
+	if options.MeterProvider != nil {
+		interceptors = append(interceptors, metrics.UnaryClientInterceptor(options.MeterProvider))
+	}
+	interceptors = append(interceptors,
+		logging.UnaryClientInterceptor(
+			autologger.SampledLogger(autologger.InterceptorLogger(apiRequestLogger), options.Sampling),
+			logging.WithLogOnEvents(logging.FinishCall),
+			logging.WithLevels(logging.DefaultServerCodeToLevel),
+		),
+	)
+	if options.DeadlineBudget != nil {
+		// Registered after the logging interceptor so that calls failing on an exhausted budget are logged.
+		interceptors = append(interceptors, deadline.UnaryClientInterceptor(options.DeadlineBudget))
+	}
+	if options.CircuitBreaker != nil {
+		// Registered after the retry, logging and deadline interceptors so that every attempt is counted and logged,
+		// and calls failing on an exhausted budget don't count against the dependency.
+		interceptors = append(interceptors, circuitbreaker.UnaryClientInterceptor(options.CircuitBreaker))
+	}
+	if options.ResponsePayloadLog != nil {
+		// Needs to be registered after the logging interceptor to add the response to its finished call log.
+		interceptors = append(interceptors, autologger.ResponsePayloadClientInterceptor(*options.ResponsePayloadLog))
+	}
+	return interceptors
+}
+
+// DefaultClientStreamInterceptors returns the streaming counterparts of DefaultClientInterceptors.
+// The finished call log also includes the number of messages sent and received and the stream duration.
+func DefaultClientStreamInterceptors(options ClientInterceptorLogOptions) []grpc.StreamClientInterceptor {
+	apiRequestLogger := newClientLogger(options)
+	interceptors := []grpc.StreamClientInterceptor{
+		common.StreamClientRetryPolicyInterceptor(retryPolicies(options)),
+		mdforward.StreamClientInterceptor(),
+	}
+	if options.TracerProvider != nil {
+		interceptors = append(interceptors, tracing.StreamClientInterceptor(options.TracerProvider))
+	}
+	if options.MeterProvider != nil {
+		interceptors = append(interceptors, metrics.StreamClientInterceptor(options.MeterProvider))
+	}
+	interceptors = append(interceptors,
+		logging.StreamClientInterceptor(
+			autologger.SampledLogger(autologger.InterceptorLogger(apiRequestLogger), options.Sampling),
+			logging.WithLogOnEvents(logging.FinishCall),
+			logging.WithLevels(logging.DefaultServerCodeToLevel),
+		),
+		// Needs to be registered after the logging interceptor to add the stream stats to its finished call log.
+		autologger.StreamClientStatsInterceptor(),
+	)
+	if options.DeadlineBudget != nil {
+		interceptors = append(interceptors, deadline.StreamClientInterceptor(options.DeadlineBudget))
+	}
+	if options.CircuitBreaker != nil {
+		interceptors = append(interceptors, circuitbreaker.StreamClientInterceptor(options.CircuitBreaker))
+	}
+	return interceptors
+}
+
+// retryPolicies returns options.Retry, or the default policies when it is not set.
+func retryPolicies(options ClientInterceptorLogOptions) common.RetryPolicies {
+	if options.Retry != nil {
+		return *options.Retry
+	}
+	return common.DefaultRetryPolicies()
+}
+
+// newClientLogger builds the ApiRequestLog logger shared by the unary and stream client interceptors.
+func newClientLogger(options ClientInterceptorLogOptions) *log.Logger {
 	var apiHandler log.Handler
 
 	apiHandlerOptions := &log.HandlerOptions{
+		Level: levelVar(options.LogLevels, loglevel.SourceAPIRequestLog),
 		ReplaceAttr: func(groups []string, a log.Attr) log.Attr {
 			a.Key = strings.TrimPrefix(a.Key, "grpc.")
 			a.Key = strings.ReplaceAll(a.Key, ".", "_")
@@ -72,28 +214,181 @@ func DefaultClientInterceptors(options ClientInterceptorLogOptions) []grpc.Unary
 		apiHandler = log.NewTextHandler(options.APIOutput, apiHandlerOptions)
 	}
 
+	apiHandler = withLogLevels(options.LogLevels, loglevel.SourceAPIRequestLog, apiHandler)
 	apiHandler = apiHandler.WithAttrs(options.Attributes)
 
-	apiRequestLogger := log.New(apiHandler).With("source", "ApiRequestLog")
-	return []grpc.UnaryClientInterceptor{
-		retry.UnaryClientInterceptor(common.GetRetryOptions()...),
-		mdforward.UnaryClientInterceptor(),
-		logging.UnaryClientInterceptor(
-			autologger.InterceptorLogger(apiRequestLogger),
-			logging.WithLogOnEvents(logging.FinishCall),
-			logging.WithLevels(logging.DefaultServerCodeToLevel),
-		),
-	}
+	return log.New(apiHandler).With("source", "ApiRequestLog")
 }
 
+// DefaultServerInterceptors is like NewServerInterceptors, but panics when the validator can't be created.
 func DefaultServerInterceptors(options ServerInterceptorLogOptions) []grpc.UnaryServerInterceptor {
+	interceptors, err := NewServerInterceptors(options)
+	if err != nil {
+		panic(err)
+	}
+	return interceptors
+}
+
+// NewServerInterceptors returns the default unary server interceptors configured by options.
+// It fails when options.Validator is not set and protovalidate.New fails.
+func NewServerInterceptors(options ServerInterceptorLogOptions) ([]grpc.UnaryServerInterceptor, error) {
 	// The first registerred interceptor will be called first.
 	// Need to register requestid first to add request-id.
 	// Then the logger can get the request-id.
+	apiRequestLogger, appCtxlogger := newServerLoggers(options)
+	validationOptions, err := newValidationOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	var interceptors []grpc.UnaryServerInterceptor
+	if options.MeterProvider != nil {
+		// Registered first so that every request, including rejected ones, is measured.
+		interceptors = append(interceptors, metrics.UnaryServerInterceptor(options.MeterProvider))
+	}
+	interceptors = append(interceptors, requestid.UnaryServerInterceptor())
+	if options.TracerProvider != nil {
+		// Registered after requestid so the span carries the request-id.
+		interceptors = append(interceptors, tracing.UnaryServerInterceptor(options.TracerProvider))
+	}
+	interceptors = append(interceptors,
+		ctxlogger.UnaryServerInterceptor(appCtxlogger, nil),
+		logging.UnaryServerInterceptor(
+			autologger.SampledLogger(autologger.InterceptorLogger(apiRequestLogger), options.Sampling),
+			logging.WithLogOnEvents(logging.FinishCall),
+			logging.WithFieldsFromContext(common.GetFields),
+		),
+	)
+	if options.LoadShedding != nil {
+		// Registered right after the logging interceptor so that shed calls are logged, and before any other work
+		// such as authentication and validation.
+		interceptors = append(interceptors, loadshed.UnaryServerInterceptor(options.LoadShedding))
+	}
+	if options.Auth != nil {
+		// Needs to be registered after the ctxlogger and logging interceptors to add the principal to their logs.
+		interceptors = append(interceptors, auth.UnaryServerInterceptor(*options.Auth))
+	}
+	if options.Authz != nil {
+		interceptors = append(interceptors, authz.UnaryServerInterceptor(authzOptions(options)))
+	}
+	// Needs to be registered after the logging interceptor to add the violations to its finished call log.
+	interceptors = append(interceptors, validation.UnaryServerInterceptor(validationOptions))
+	if options.Idempotency != nil {
+		// Registered after the validation interceptor so that invalid calls don't hold their key.
+		interceptors = append(interceptors, idempotency.UnaryServerInterceptor(*options.Idempotency))
+	}
+	if options.RateLimit != nil {
+		// Registered after the logging interceptor so that rejected calls are logged.
+		interceptors = append(interceptors, ratelimit.UnaryServerInterceptor(*options.RateLimit))
+	}
+	if options.Deadlines != nil {
+		// Needs to be registered after the logging interceptor to add where the deadline ran out to its finished call log.
+		interceptors = append(interceptors, deadline.UnaryServerInterceptor(options.Deadlines))
+	}
+	if options.Bulkhead != nil {
+		// Registered after the deadline interceptor so that the time spent in the queue counts against the deadline.
+		interceptors = append(interceptors, bulkhead.UnaryServerInterceptor(*options.Bulkhead))
+	}
+	if options.ResponsePayloadLog != nil {
+		// Needs to be registered after the logging interceptor to add the response to its finished call log.
+		interceptors = append(interceptors, autologger.ResponsePayloadServerInterceptor(*options.ResponsePayloadLog))
+	}
+	return append(interceptors,
+		responseheader.UnaryServerInterceptor(httpcommon.MetadataToHeader),
+		recovery.UnaryServerInterceptor(recoveryOpts(options)...),
+	), nil
+}
+
+// authzOptions returns the authz options of options, skipping the methods that aren't authenticated.
+func authzOptions(options ServerInterceptorLogOptions) authz.Options {
+	opts := authz.Options{Policy: options.Authz}
+	if options.Auth != nil {
+		opts.SkipMethods = options.Auth.SkipMethods
+	}
+	return opts
+}
+
+// DefaultServerStreamInterceptors is like NewServerStreamInterceptors, but panics when the validator can't be created.
+func DefaultServerStreamInterceptors(options ServerInterceptorLogOptions) []grpc.StreamServerInterceptor {
+	interceptors, err := NewServerStreamInterceptors(options)
+	if err != nil {
+		panic(err)
+	}
+	return interceptors
+}
+
+// NewServerStreamInterceptors returns the streaming counterparts of NewServerInterceptors,
+// registered in the same order.
+func NewServerStreamInterceptors(options ServerInterceptorLogOptions) ([]grpc.StreamServerInterceptor, error) {
+	apiRequestLogger, appCtxlogger := newServerLoggers(options)
+	validationOptions, err := newValidationOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	var interceptors []grpc.StreamServerInterceptor
+	if options.MeterProvider != nil {
+		interceptors = append(interceptors, metrics.StreamServerInterceptor(options.MeterProvider))
+	}
+	interceptors = append(interceptors, requestid.StreamServerInterceptor())
+	if options.TracerProvider != nil {
+		interceptors = append(interceptors, tracing.StreamServerInterceptor(options.TracerProvider))
+	}
+	interceptors = append(interceptors,
+		ctxlogger.StreamServerInterceptor(appCtxlogger, nil),
+		logging.StreamServerInterceptor(
+			autologger.SampledLogger(autologger.InterceptorLogger(apiRequestLogger), options.Sampling),
+			logging.WithLogOnEvents(logging.FinishCall),
+			logging.WithFieldsFromContext(common.GetFields),
+		),
+	)
+	if options.LoadShedding != nil {
+		interceptors = append(interceptors, loadshed.StreamServerInterceptor(options.LoadShedding))
+	}
+	if options.Auth != nil {
+		interceptors = append(interceptors, auth.StreamServerInterceptor(*options.Auth))
+	}
+	if options.Authz != nil {
+		interceptors = append(interceptors, authz.StreamServerInterceptor(authzOptions(options)))
+	}
+	interceptors = append(interceptors, validation.StreamServerInterceptor(validationOptions))
+	if options.RateLimit != nil {
+		interceptors = append(interceptors, ratelimit.StreamServerInterceptor(*options.RateLimit))
+	}
+	if options.Deadlines != nil {
+		interceptors = append(interceptors, deadline.StreamServerInterceptor(options.Deadlines))
+	}
+	if options.Bulkhead != nil {
+		interceptors = append(interceptors, bulkhead.StreamServerInterceptor(*options.Bulkhead))
+	}
+	return append(interceptors,
+		responseheader.StreamServerInterceptor(httpcommon.MetadataToHeader),
+		recovery.StreamServerInterceptor(recoveryOpts(options)...),
+	), nil
+}
+
+// newValidationOptions returns the validation options of options, creating the default validator when
+// options.Validator is not set.
+func newValidationOptions(options ServerInterceptorLogOptions) (validation.Options, error) {
+	validator := options.Validator
+	if validator == nil {
+		var err error
+		validator, err = protovalidate.New()
+		if err != nil {
+			return validation.Options{}, err
+		}
+	}
+	return validation.Options{
+		Validator:         validator,
+		ValidateResponses: options.ValidateResponses,
+	}, nil
+}
+
+// newServerLoggers builds the ApiRequestLog and CtxLog loggers shared by the unary and stream server interceptors.
+func newServerLoggers(options ServerInterceptorLogOptions) (*log.Logger, *log.Logger) {
 	var apiHandler log.Handler
 	var ctxHandler log.Handler
 
 	apiHandlerOptions := &log.HandlerOptions{
+		Level: levelVar(options.LogLevels, loglevel.SourceAPIRequestLog),
 		ReplaceAttr: func(groups []string, a log.Attr) log.Attr {
 			a.Key = strings.TrimPrefix(a.Key, "grpc.")
 			a.Key = strings.ReplaceAll(a.Key, ".", "_")
@@ -102,6 +397,7 @@ func DefaultServerInterceptors(options ServerInterceptorLogOptions) []grpc.Unary
 	}
 	ctxHandlerOptions := &log.HandlerOptions{
 		AddSource: true,
+		Level:     levelVar(options.LogLevels, loglevel.SourceCtxLog),
 		ReplaceAttr: func(groups []string, a log.Attr) log.Attr {
 			if a.Key == log.SourceKey {
 				// Needed to add to prevent "CtxLog" key from being changed as well
@@ -126,25 +422,41 @@ func DefaultServerInterceptors(options ServerInterceptorLogOptions) []grpc.Unary
 		ctxHandler = log.NewTextHandler(options.CtxOutput, ctxHandlerOptions)
 	}
 
+	apiHandler = withLogLevels(options.LogLevels, loglevel.SourceAPIRequestLog, apiHandler)
+	ctxHandler = withLogLevels(options.LogLevels, loglevel.SourceCtxLog, ctxHandler)
 	apiHandler = apiHandler.WithAttrs(options.APIAttributes)
 	ctxHandler = ctxHandler.WithAttrs(options.CtxAttributes)
 
 	apiRequestLogger := log.New(apiHandler).With("source", "ApiRequestLog")
 	appCtxlogger := log.New(ctxHandler).With("source", "CtxLog")
-	validator, err := protovalidate.New()
-	if err != nil {
-		panic(err)
+	return apiRequestLogger, appCtxlogger
+}
+
+// levelVar returns the base level of source, or nil for the handler default when c is nil.
+func levelVar(c *loglevel.Controller, source loglevel.Source) log.Leveler {
+	if c == nil {
+		return nil
 	}
-	return []grpc.UnaryServerInterceptor{
-		protovalidate_middleware.UnaryServerInterceptor(validator),
-		requestid.UnaryServerInterceptor(),
-		ctxlogger.UnaryServerInterceptor(appCtxlogger, nil),
-		logging.UnaryServerInterceptor(
-			autologger.InterceptorLogger(apiRequestLogger),
-			logging.WithLogOnEvents(logging.FinishCall),
-			logging.WithFieldsFromContext(common.GetFields),
-		),
-		responseheader.UnaryServerInterceptor(httpcommon.MetadataToHeader),
-		recovery.UnaryServerInterceptor(common.GetRecoveryOpts()...),
+	return c.LevelVar(source)
+}
+
+// withLogLevels wraps h with the per-method levels of source when c is set.
+func withLogLevels(c *loglevel.Controller, source loglevel.Source, h log.Handler) log.Handler {
+	if c == nil {
+		return h
+	}
+	return c.Handler(source, h)
+}
+
+// recoveryOpts returns the recovery options for options.Recovery,
+// writing the panic details to the ctxlogger logger unless the config provides its own.
+func recoveryOpts(options ServerInterceptorLogOptions) []recovery.Option {
+	config := common.DefaultRecoveryConfig()
+	if options.Recovery != nil {
+		config = *options.Recovery
+	}
+	if config.GetLogger == nil {
+		config.GetLogger = ctxlogger.GetLogger
 	}
+	return common.GetRecoveryOptsWithConfig(config)
 }
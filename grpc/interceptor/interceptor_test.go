@@ -0,0 +1,208 @@
+package interceptor_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	log "log/slog"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/Azure/aks-middleware/grpc/common"
+	"github.com/Azure/aks-middleware/grpc/interceptor"
+	httpcommon "github.com/Azure/aks-middleware/http/common"
+	httpcircuitbreaker "github.com/Azure/aks-middleware/http/common/circuitbreaker"
+	pb "github.com/Azure/aks-middleware/test/api/v1"
+	"github.com/Azure/aks-middleware/test/server"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var _ = Describe("Default stream interceptors", func() {
+	var (
+		grpcServer *grpc.Server
+		lis        net.Listener
+		clientConn *grpc.ClientConn
+		testSrv    *server.TestStreamServer
+		apiOutput  *bytes.Buffer
+		ctxOutput  *bytes.Buffer
+	)
+
+	BeforeEach(func() {
+		var err error
+		lis, err = net.Listen("tcp", "localhost:0")
+		Expect(err).ToNot(HaveOccurred())
+
+		apiOutput = &bytes.Buffer{}
+		ctxOutput = &bytes.Buffer{}
+		options := interceptor.ServerInterceptorLogOptions{
+			Logger:    log.New(log.NewJSONHandler(io.Discard, nil)),
+			APIOutput: apiOutput,
+			CtxOutput: ctxOutput,
+		}
+		grpcServer = grpc.NewServer(
+			grpc.ChainStreamInterceptor(interceptor.DefaultServerStreamInterceptors(options)...),
+		)
+		testSrv = &server.TestStreamServer{}
+		server.RegisterStreamGreeterServer(grpcServer, testSrv)
+
+		go func() {
+			_ = grpcServer.Serve(lis)
+		}()
+
+		clientConn, err = grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		clientConn.Close()
+		grpcServer.Stop()
+		lis.Close()
+	})
+
+	It("should add request id, ctx logger, response headers and an ApiRequestLog entry", func() {
+		md := metadata.Pairs(httpcommon.OperationIDKey, "op-id")
+		ctx := metadata.NewOutgoingContext(context.Background(), md)
+
+		stream, err := server.NewSayHelloStream(ctx, clientConn)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stream.SendMsg(&pb.HelloRequest{Name: "Stream", Age: 30, Email: "test@test.com"})).To(Succeed())
+		reply := &pb.HelloReply{}
+		Expect(stream.RecvMsg(reply)).To(Succeed())
+		Expect(reply.Message).To(Equal("Hello Stream"))
+		Expect(stream.CloseSend()).To(Succeed())
+		Expect(stream.RecvMsg(&pb.HelloReply{})).To(MatchError(io.EOF))
+
+		header, err := stream.Header()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(header.Get(httpcommon.OperationIDKey)).To(ConsistOf("op-id"))
+
+		Expect(testSrv.ReceivedMetadata.Get(httpcommon.RequestIDMetadataHeader)).To(HaveLen(1))
+
+		Expect(ctxOutput.String()).To(ContainSubstring("stream started"))
+		Expect(ctxOutput.String()).To(ContainSubstring(server.SayHelloStreamMethod))
+
+		Eventually(apiOutput.String).Should(ContainSubstring("finished call"))
+		Expect(apiOutput.String()).To(ContainSubstring(`"method_type":"bidi_stream"`))
+		Expect(apiOutput.String()).To(ContainSubstring(httpcommon.RequestIDMetadataHeader))
+	})
+
+	It("should recover from a panic in the stream handler", func() {
+		stream, err := server.NewSayHelloStream(context.Background(), clientConn)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stream.SendMsg(&pb.HelloRequest{Name: server.PanicName, Age: 30, Email: "test@test.com"})).To(Succeed())
+
+		err = stream.RecvMsg(&pb.HelloReply{})
+		Expect(status.Code(err)).To(Equal(codes.Internal))
+		Expect(err.Error()).To(ContainSubstring("stream panic"))
+	})
+})
+
+var _ = Describe("Default client stream interceptors", func() {
+	var (
+		grpcServer *grpc.Server
+		lis        net.Listener
+		clientConn *grpc.ClientConn
+		testSrv    *server.TestStreamServer
+		apiOutput  *bytes.Buffer
+	)
+
+	BeforeEach(func() {
+		var err error
+		lis, err = net.Listen("tcp", "localhost:0")
+		Expect(err).ToNot(HaveOccurred())
+
+		grpcServer = grpc.NewServer()
+		testSrv = &server.TestStreamServer{}
+		server.RegisterStreamGreeterServer(grpcServer, testSrv)
+
+		go func() {
+			_ = grpcServer.Serve(lis)
+		}()
+
+		apiOutput = &bytes.Buffer{}
+		options := interceptor.ClientInterceptorLogOptions{
+			Logger:    log.New(log.NewJSONHandler(io.Discard, nil)),
+			APIOutput: apiOutput,
+		}
+		clientConn, err = grpc.NewClient(lis.Addr().String(),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithChainStreamInterceptor(interceptor.DefaultClientStreamInterceptors(options)...),
+		)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		clientConn.Close()
+		grpcServer.Stop()
+		lis.Close()
+	})
+
+	It("should forward metadata and log message counts and stream duration", func() {
+		md := metadata.Pairs(httpcommon.CorrelationIDKey, "corr-id")
+		ctx := metadata.NewIncomingContext(context.Background(), md)
+
+		stream, err := server.NewSayHelloStream(ctx, clientConn)
+		Expect(err).ToNot(HaveOccurred())
+		for _, name := range []string{"first", "second"} {
+			Expect(stream.SendMsg(&pb.HelloRequest{Name: name})).To(Succeed())
+			Expect(stream.RecvMsg(&pb.HelloReply{})).To(Succeed())
+		}
+		Expect(stream.CloseSend()).To(Succeed())
+		Expect(stream.RecvMsg(&pb.HelloReply{})).To(MatchError(io.EOF))
+
+		Expect(testSrv.ReceivedMetadata.Get(httpcommon.CorrelationIDKey)).To(ConsistOf("corr-id"))
+
+		logs := apiOutput.String()
+		Expect(logs).To(ContainSubstring("finished call"))
+		Expect(logs).To(ContainSubstring(`"component":"client"`))
+		Expect(logs).To(ContainSubstring(`"msg_sent":2`))
+		Expect(logs).To(ContainSubstring(`"msg_received":2`))
+		Expect(logs).To(ContainSubstring(`"stream_duration_ms":`))
+	})
+})
+
+var _ = Describe("Default client interceptors", func() {
+	// chain calls invoker through interceptors, the first one being the outermost.
+	chain := func(interceptors []grpc.UnaryClientInterceptor, invoker grpc.UnaryInvoker) grpc.UnaryInvoker {
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			next, current := invoker, interceptors[i]
+			invoker = func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				return current(ctx, method, req, reply, cc, next, opts...)
+			}
+		}
+		return invoker
+	}
+
+	It("should not retry the calls failed fast by an open circuit breaker", func() {
+		apiOutput := &bytes.Buffer{}
+		options := interceptor.ClientInterceptorLogOptions{
+			Logger:         log.New(log.NewJSONHandler(io.Discard, nil)),
+			APIOutput:      apiOutput,
+			CircuitBreaker: httpcircuitbreaker.New(httpcircuitbreaker.Options{MinRequests: 1, OpenTimeout: time.Hour}),
+			Retry:          &common.RetryPolicies{Default: common.RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Millisecond}},
+		}
+		cc, err := grpc.NewClient("passthrough:///dependency", grpc.WithTransportCredentials(insecure.NewCredentials()))
+		Expect(err).ToNot(HaveOccurred())
+		defer cc.Close()
+
+		calls := 0
+		invoke := chain(interceptor.DefaultClientInterceptors(options), func(context.Context, string, any, any, *grpc.ClientConn, ...grpc.CallOption) error {
+			calls++
+			return status.Error(codes.Unavailable, "dependency down")
+		})
+
+		// The first attempt fails and opens the breaker, the retry is failed fast and not retried.
+		err = invoke(context.Background(), "/package.Service/Method", &pb.HelloRequest{}, &pb.HelloReply{}, cc)
+		Expect(status.Code(err)).To(Equal(codes.Unavailable))
+		Expect(common.NonRetriable(err)).To(BeTrue())
+		Expect(calls).To(Equal(1))
+		Expect(strings.Count(apiOutput.String(), "finished call")).To(Equal(2))
+	})
+})
@@ -0,0 +1,83 @@
+package auth
+
+// This package authenticates gRPC calls with the bearer token of their authorization metadata,
+// validated by the http/common/auth Validator.
+
+import (
+	"context"
+	"slices"
+
+	"github.com/Azure/aks-middleware/grpc/server/ctxlogger"
+	httpauth "github.com/Azure/aks-middleware/http/common/auth"
+	middleware "github.com/grpc-ecosystem/go-grpc-middleware/v2"
+	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// AuthorizationKey is the metadata holding the bearer token.
+const AuthorizationKey = "authorization"
+
+// Options configure the authentication interceptors.
+type Options struct {
+	// Validator validates the tokens. Required.
+	Validator *httpauth.Validator
+	// SkipMethods are the full methods that don't require a token, e.g. health checks.
+	SkipMethods []string
+}
+
+// UnaryServerInterceptor returns a server interceptor rejecting calls without a valid bearer token with
+// codes.Unauthenticated. The principal of the token is put in the context, see httpauth.PrincipalFromContext,
+// and added to the ctxlogger logger and to the finished call log. Register it after the ctxlogger and
+// logging interceptors.
+func UnaryServerInterceptor(opts Options) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (any, error) {
+		if slices.Contains(opts.SkipMethods, info.FullMethod) {
+			return handler(ctx, req)
+		}
+		ctx, err := authenticate(ctx, opts.Validator)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor is the streaming counterpart of UnaryServerInterceptor.
+func StreamServerInterceptor(opts Options) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+		if slices.Contains(opts.SkipMethods, info.FullMethod) {
+			return handler(srv, ss)
+		}
+		ctx, err := authenticate(ss.Context(), opts.Validator)
+		if err != nil {
+			return err
+		}
+		wrapped := middleware.WrapServerStream(ss)
+		wrapped.WrappedContext = ctx
+		return handler(srv, wrapped)
+	}
+}
+
+func authenticate(ctx context.Context, validator *httpauth.Validator) (context.Context, error) {
+	var authorization string
+	if vals := metadata.ValueFromIncomingContext(ctx, AuthorizationKey); len(vals) > 0 {
+		authorization = vals[0]
+	}
+	token, err := httpauth.BearerToken(authorization)
+	if err != nil {
+		return ctx, status.Error(codes.Unauthenticated, err.Error())
+	}
+	principal, err := validator.Validate(ctx, token)
+	if err != nil {
+		return ctx, status.Error(codes.Unauthenticated, err.Error())
+	}
+	attrs := principal.LogAttrs()
+	logging.AddFields(ctx, logging.Fields(attrs))
+	ctx = ctxlogger.WithLogger(ctx, ctxlogger.GetLogger(ctx).With(attrs...))
+	return httpauth.WithPrincipal(ctx, principal), nil
+}
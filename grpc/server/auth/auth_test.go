@@ -0,0 +1,165 @@
+package auth_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/Azure/aks-middleware/grpc/interceptor"
+	"github.com/Azure/aks-middleware/grpc/server/auth"
+	"github.com/Azure/aks-middleware/grpc/server/ctxlogger"
+	httpauth "github.com/Azure/aks-middleware/http/common/auth"
+	pb "github.com/Azure/aks-middleware/test/api/v1"
+	testauth "github.com/Azure/aks-middleware/test/auth"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// principalServer records the principal of the calls and logs through the context logger.
+type principalServer struct {
+	pb.UnimplementedMyGreeterServer
+	principal *httpauth.Principal
+}
+
+func (s *principalServer) SayHello(ctx context.Context, req *pb.HelloRequest) (*pb.HelloReply, error) {
+	s.principal, _ = httpauth.PrincipalFromContext(ctx)
+	ctxlogger.GetLogger(ctx).Info("greeting")
+	return &pb.HelloReply{Message: "Hello " + req.Name}, nil
+}
+
+var _ = Describe("Auth interceptor", func() {
+	var (
+		key       *testauth.Key
+		apiOutput *bytes.Buffer
+		ctxOutput *bytes.Buffer
+		greeter   *principalServer
+		client    pb.MyGreeterClient
+		request   *pb.HelloRequest
+	)
+
+	BeforeEach(func() {
+		key = testauth.NewRSAKey("rsa-key")
+		jwksPath := filepath.Join(GinkgoT().TempDir(), "jwks.json")
+		Expect(os.WriteFile(jwksPath, testauth.JWKS(key), 0o600)).To(Succeed())
+		validator, err := httpauth.NewValidator(httpauth.Options{
+			Issuers:   []string{testauth.Issuer},
+			Audiences: []string{testauth.Audience},
+			Keys:      httpauth.NewJWKSFromFile(jwksPath, httpauth.JWKSOptions{}),
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		apiOutput = &bytes.Buffer{}
+		ctxOutput = &bytes.Buffer{}
+		interceptors, err := interceptor.NewServerInterceptors(interceptor.ServerInterceptorLogOptions{
+			Logger:    slog.New(slog.NewJSONHandler(io.Discard, nil)),
+			APIOutput: apiOutput,
+			CtxOutput: ctxOutput,
+			Auth: &auth.Options{
+				Validator:   validator,
+				SkipMethods: []string{"/MyGreeter/Skipped"},
+			},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		grpcServer := grpc.NewServer(grpc.ChainUnaryInterceptor(interceptors...))
+		greeter = &principalServer{}
+		pb.RegisterMyGreeterServer(grpcServer, greeter)
+		lis, err := net.Listen("tcp", "localhost:0")
+		Expect(err).ToNot(HaveOccurred())
+		go func() {
+			_ = grpcServer.Serve(lis)
+		}()
+		DeferCleanup(grpcServer.Stop)
+		clientConn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		Expect(err).ToNot(HaveOccurred())
+		DeferCleanup(clientConn.Close)
+		client = pb.NewMyGreeterClient(clientConn)
+		request = &pb.HelloRequest{Name: "Test", Age: 30, Email: "test@test.com"}
+	})
+
+	withToken := func(token string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), auth.AuthorizationKey, "Bearer "+token)
+	}
+
+	It("should reject calls without a token", func() {
+		_, err := client.SayHello(context.Background(), request)
+		Expect(status.Code(err)).To(Equal(codes.Unauthenticated))
+		Expect(status.Convert(err).Message()).To(Equal(httpauth.ErrMissingToken.Error()))
+		Expect(greeter.principal).To(BeNil())
+		Expect(apiOutput.String()).To(ContainSubstring(`"code":"Unauthenticated"`))
+	})
+
+	It("should reject calls with an invalid token", func() {
+		claims := testauth.Claims()
+		claims["aud"] = "https://other.com/"
+		_, err := client.SayHello(withToken(key.Sign(claims)), request)
+		Expect(status.Code(err)).To(Equal(codes.Unauthenticated))
+
+		_, err = client.SayHello(withToken(testauth.NewRSAKey("other-key").Sign(testauth.Claims())), request)
+		Expect(status.Code(err)).To(Equal(codes.Unauthenticated))
+		Expect(greeter.principal).To(BeNil())
+	})
+
+	It("should put the principal in the context and the logs", func() {
+		_, err := client.SayHello(withToken(key.Sign(testauth.Claims())), request)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(greeter.principal).ToNot(BeNil())
+		Expect(greeter.principal.ObjectID).To(Equal("test-oid"))
+		Expect(greeter.principal.TenantID).To(Equal("test-tid"))
+
+		for _, output := range []string{apiOutput.String(), ctxOutput.String()} {
+			Expect(output).To(ContainSubstring(`"oid":"test-oid"`))
+			Expect(output).To(ContainSubstring(`"tid":"test-tid"`))
+			Expect(output).To(ContainSubstring(`"appid":"test-appid"`))
+			// The logs scrub the e-mail address of the UPN.
+			Expect(output).To(ContainSubstring(`"upn":"[REDACTED]"`))
+		}
+		Expect(ctxOutput.String()).To(ContainSubstring(`"msg":"greeting"`))
+	})
+
+	It("should not require a token for the skipped methods", func() {
+		unary := auth.UnaryServerInterceptor(auth.Options{SkipMethods: []string{"/MyGreeter/SayHello"}})
+		resp, err := unary(context.Background(), request, &grpc.UnaryServerInfo{FullMethod: "/MyGreeter/SayHello"},
+			func(ctx context.Context, req any) (any, error) {
+				return "ok", nil
+			})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp).To(Equal("ok"))
+	})
+
+	It("should authenticate streams", func() {
+		stream := auth.StreamServerInterceptor(auth.Options{SkipMethods: []string{"/MyGreeter/Stream"}})
+		called := false
+		err := stream(nil, &fakeServerStream{ctx: context.Background()}, &grpc.StreamServerInfo{FullMethod: "/MyGreeter/Stream"},
+			func(srv any, ss grpc.ServerStream) error {
+				called = true
+				return nil
+			})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(called).To(BeTrue())
+
+		err = stream(nil, &fakeServerStream{ctx: context.Background()}, &grpc.StreamServerInfo{FullMethod: "/MyGreeter/Other"},
+			func(srv any, ss grpc.ServerStream) error {
+				Fail("the handler shouldn't be called")
+				return nil
+			})
+		Expect(status.Code(err)).To(Equal(codes.Unauthenticated))
+	})
+})
+
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeServerStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,63 @@
+package authz
+
+// This package authorizes gRPC calls with the http/common/authz policy of their full method.
+
+import (
+	"context"
+	"slices"
+
+	"github.com/Azure/aks-middleware/grpc/server/ctxlogger"
+	"github.com/Azure/aks-middleware/http/common/authz"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Options configure the authorization interceptors.
+type Options struct {
+	// Policy decides which callers can call which method. Required.
+	Policy *authz.Policy
+	// SkipMethods are the full methods that aren't authorized, e.g. health checks. They are usually
+	// the SkipMethods of the auth interceptor, whose calls have no principal and would be denied.
+	SkipMethods []string
+}
+
+// UnaryServerInterceptor returns a server interceptor rejecting the calls denied by the policy with
+// codes.PermissionDenied. The deny decisions are logged to the CtxLog with their rule. There is no gRPC
+// counterpart of the otelaudit middleware, so unlike the HTTP middleware they aren't audited. Register it
+// after the auth interceptor, which puts the principal in the context.
+func UnaryServerInterceptor(opts Options) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (any, error) {
+		if slices.Contains(opts.SkipMethods, info.FullMethod) {
+			return handler(ctx, req)
+		}
+		if err := authorize(ctx, opts.Policy, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor is the streaming counterpart of UnaryServerInterceptor.
+func StreamServerInterceptor(opts Options) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+		if slices.Contains(opts.SkipMethods, info.FullMethod) {
+			return handler(srv, ss)
+		}
+		if err := authorize(ss.Context(), opts.Policy, info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func authorize(ctx context.Context, policy *authz.Policy, method string) error {
+	decision := policy.Authorize(ctx, method)
+	if decision.Allowed {
+		return nil
+	}
+	ctxlogger.GetLogger(ctx).Warn(authz.DeniedLogMessage, decision.LogAttrs()...)
+	return status.Errorf(codes.PermissionDenied, "the caller is not authorized to call %s", method)
+}
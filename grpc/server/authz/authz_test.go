@@ -0,0 +1,109 @@
+package authz_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/Azure/aks-middleware/grpc/interceptor"
+	"github.com/Azure/aks-middleware/grpc/server/auth"
+	httpauth "github.com/Azure/aks-middleware/http/common/auth"
+	"github.com/Azure/aks-middleware/http/common/authz"
+	pb "github.com/Azure/aks-middleware/test/api/v1"
+	testauth "github.com/Azure/aks-middleware/test/auth"
+	"github.com/Azure/aks-middleware/test/server"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var _ = Describe("Authz interceptor", func() {
+	var (
+		key       *testauth.Key
+		authOpts  *auth.Options
+		policy    *authz.Policy
+		ctxOutput *bytes.Buffer
+		client    pb.MyGreeterClient
+		request   *pb.HelloRequest
+	)
+
+	BeforeEach(func() {
+		key = testauth.NewRSAKey("rsa-key")
+		jwksPath := filepath.Join(GinkgoT().TempDir(), "jwks.json")
+		Expect(os.WriteFile(jwksPath, testauth.JWKS(key), 0o600)).To(Succeed())
+		validator, err := httpauth.NewValidator(httpauth.Options{
+			Issuers:   []string{testauth.Issuer},
+			Audiences: []string{testauth.Audience},
+			Keys:      httpauth.NewJWKSFromFile(jwksPath, httpauth.JWKSOptions{}),
+		})
+		Expect(err).ToNot(HaveOccurred())
+		authOpts = &auth.Options{Validator: validator}
+		policy, err = authz.ParsePolicy([]byte(`{"methods": {"/MyGreeter/SayHello": [{"name": "readers", "roles": ["Reader"]}]}}`))
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	JustBeforeEach(func() {
+		ctxOutput = &bytes.Buffer{}
+		interceptors, err := interceptor.NewServerInterceptors(interceptor.ServerInterceptorLogOptions{
+			Logger:    slog.New(slog.NewJSONHandler(io.Discard, nil)),
+			APIOutput: io.Discard,
+			CtxOutput: ctxOutput,
+			Auth:      authOpts,
+			Authz:     policy,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		grpcServer := grpc.NewServer(grpc.ChainUnaryInterceptor(interceptors...))
+		pb.RegisterMyGreeterServer(grpcServer, &server.TestServer{})
+		lis, err := net.Listen("tcp", "localhost:0")
+		Expect(err).ToNot(HaveOccurred())
+		go func() {
+			_ = grpcServer.Serve(lis)
+		}()
+		DeferCleanup(grpcServer.Stop)
+		clientConn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		Expect(err).ToNot(HaveOccurred())
+		DeferCleanup(clientConn.Close)
+		client = pb.NewMyGreeterClient(clientConn)
+		request = &pb.HelloRequest{Name: "Test", Age: 30, Email: "test@test.com"}
+	})
+
+	withRoles := func(roles ...string) context.Context {
+		claims := testauth.Claims()
+		claims["roles"] = roles
+		return metadata.AppendToOutgoingContext(context.Background(), auth.AuthorizationKey, "Bearer "+key.Sign(claims))
+	}
+
+	It("should allow the calls matching a rule", func() {
+		_, err := client.SayHello(withRoles("Reader"), request)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ctxOutput.String()).ToNot(ContainSubstring(authz.DeniedLogMessage))
+	})
+
+	It("should reject the other calls and log the decision", func() {
+		_, err := client.SayHello(withRoles("Writer"), request)
+		Expect(status.Code(err)).To(Equal(codes.PermissionDenied))
+		Expect(ctxOutput.String()).To(ContainSubstring(`"msg":"` + authz.DeniedLogMessage + `"`))
+		Expect(ctxOutput.String()).To(ContainSubstring(`"authz_rule":"` + authz.ImplicitDenyRule + `"`))
+		Expect(ctxOutput.String()).To(ContainSubstring(`"oid":"test-oid"`))
+	})
+
+	When("the method isn't authenticated", func() {
+		BeforeEach(func() {
+			authOpts.SkipMethods = []string{"/MyGreeter/SayHello"}
+		})
+
+		It("should not authorize the calls", func() {
+			_, err := client.SayHello(context.Background(), request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ctxOutput.String()).ToNot(ContainSubstring(authz.DeniedLogMessage))
+		})
+	})
+})
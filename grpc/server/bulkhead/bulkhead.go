@@ -0,0 +1,114 @@
+package bulkhead
+
+// This package runs gRPC calls in the http/common/bulkhead pools.
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Azure/aks-middleware/http/common/bulkhead"
+	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ClassFunc returns the bulkhead class of a call.
+type ClassFunc func(ctx context.Context, fullMethod string) string
+
+// OperationClass classifies calls by the verb of their method name, like the HTTP OperationClass: methods
+// starting with Get are bulkhead.ClassRead, List bulkhead.ClassList, Delete bulkhead.ClassDelete, and the
+// others, e.g. Create or Update, bulkhead.ClassWrite.
+func OperationClass(ctx context.Context, fullMethod string) string {
+	method := fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+	switch {
+	case strings.HasPrefix(method, "Get"):
+		return bulkhead.ClassRead
+	case strings.HasPrefix(method, "List"):
+		return bulkhead.ClassList
+	case strings.HasPrefix(method, "Delete"):
+		return bulkhead.ClassDelete
+	default:
+		return bulkhead.ClassWrite
+	}
+}
+
+// MethodClass classifies calls by their full method, giving each method its own pool.
+func MethodClass(ctx context.Context, fullMethod string) string {
+	return fullMethod
+}
+
+// MethodClasses classifies calls with classes, e.g. {"/pkg.Service/Get": bulkhead.ClassRead},
+// and calls to other methods as defaultClass.
+func MethodClasses(classes map[string]string, defaultClass string) ClassFunc {
+	return func(ctx context.Context, fullMethod string) string {
+		if class, ok := classes[fullMethod]; ok {
+			return class
+		}
+		return defaultClass
+	}
+}
+
+// Options configure the bulkhead interceptors.
+type Options struct {
+	// Bulkhead is required.
+	Bulkhead *bulkhead.Bulkhead
+	// Class defaults to OperationClass.
+	Class ClassFunc
+}
+
+// UnaryServerInterceptor returns a server interceptor running calls in the pool of their class.
+// Calls rejected because the pool and its queue are full, or that waited too long, fail with
+// codes.Unavailable. The class and the time spent in the queue are added to the finished call log.
+// Register it after the logging interceptor. It panics when opts has no Bulkhead.
+func UnaryServerInterceptor(opts Options) grpc.UnaryServerInterceptor {
+	classify := classFunc(opts)
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (any, error) {
+		release, err := acquire(ctx, opts.Bulkhead, classify(ctx, info.FullMethod))
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor is the streaming counterpart of UnaryServerInterceptor.
+// Streams hold their slot until they finish.
+func StreamServerInterceptor(opts Options) grpc.StreamServerInterceptor {
+	classify := classFunc(opts)
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+		release, err := acquire(ss.Context(), opts.Bulkhead, classify(ss.Context(), info.FullMethod))
+		if err != nil {
+			return err
+		}
+		defer release()
+		return handler(srv, ss)
+	}
+}
+
+// classFunc returns the classifier of opts. Without a Bulkhead every call would panic, so it panics when
+// the interceptor is created instead.
+func classFunc(opts Options) ClassFunc {
+	if opts.Bulkhead == nil {
+		panic("bulkhead: Options.Bulkhead is required")
+	}
+	if opts.Class == nil {
+		return OperationClass
+	}
+	return opts.Class
+}
+
+func acquire(ctx context.Context, b *bulkhead.Bulkhead, class string) (func(), error) {
+	release, queued, err := b.Acquire(ctx, class)
+	logging.AddFields(ctx, logging.Fields{bulkhead.ClassLogKey, class, bulkhead.QueuedLogKey, queued.Milliseconds()})
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, status.FromContextError(ctxErr).Err()
+		}
+		return nil, status.Errorf(codes.Unavailable, "too many concurrent %s calls: %v", class, err)
+	}
+	return release, nil
+}
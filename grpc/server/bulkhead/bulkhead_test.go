@@ -0,0 +1,92 @@
+package bulkhead_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net"
+
+	"github.com/Azure/aks-middleware/grpc/common/autologger"
+	"github.com/Azure/aks-middleware/grpc/server/bulkhead"
+	httpbulkhead "github.com/Azure/aks-middleware/http/common/bulkhead"
+	pb "github.com/Azure/aks-middleware/test/api/v1"
+	"github.com/Azure/aks-middleware/test/server"
+	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+var _ = Describe("Bulkhead interceptor", func() {
+	var (
+		grpcServer *grpc.Server
+		lis        net.Listener
+		clientConn *grpc.ClientConn
+		client     pb.MyGreeterClient
+		logBuf     *bytes.Buffer
+		b          *httpbulkhead.Bulkhead
+	)
+
+	BeforeEach(func() {
+		var err error
+		lis, err = net.Listen("tcp", "localhost:0")
+		Expect(err).ToNot(HaveOccurred())
+		logBuf = &bytes.Buffer{}
+		b = httpbulkhead.New(nil, httpbulkhead.PoolConfig{MaxConcurrent: 1})
+		grpcServer = grpc.NewServer(grpc.ChainUnaryInterceptor(
+			logging.UnaryServerInterceptor(autologger.InterceptorLogger(slog.New(slog.NewJSONHandler(logBuf, nil))),
+				logging.WithLogOnEvents(logging.FinishCall)),
+			bulkhead.UnaryServerInterceptor(bulkhead.Options{
+				Bulkhead: b,
+				Class:    bulkhead.MethodClasses(map[string]string{pb.MyGreeter_SayHello_FullMethodName: httpbulkhead.ClassRead}, httpbulkhead.ClassWrite),
+			}),
+		))
+		pb.RegisterMyGreeterServer(grpcServer, &server.TestServer{})
+		go func() {
+			_ = grpcServer.Serve(lis)
+		}()
+		clientConn, err = grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		Expect(err).ToNot(HaveOccurred())
+		client = pb.NewMyGreeterClient(clientConn)
+	})
+
+	AfterEach(func() {
+		clientConn.Close()
+		grpcServer.Stop()
+		lis.Close()
+	})
+
+	It("should log the class and queued time", func() {
+		_, err := client.SayHello(context.Background(), &pb.HelloRequest{Name: "Test"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(logBuf.String()).To(ContainSubstring(`"bulkhead":"read"`))
+		Expect(logBuf.String()).To(ContainSubstring(`"queued_ms":0`))
+	})
+
+	It("should reject calls when the pool of their class is full", func() {
+		release, _, err := b.Acquire(context.Background(), httpbulkhead.ClassRead)
+		Expect(err).ToNot(HaveOccurred())
+		defer release()
+		_, err = client.SayHello(context.Background(), &pb.HelloRequest{Name: "Test"})
+		Expect(status.Code(err)).To(Equal(codes.Unavailable))
+	})
+
+	It("should require a Bulkhead", func() {
+		Expect(func() { bulkhead.UnaryServerInterceptor(bulkhead.Options{}) }).To(PanicWith(ContainSubstring("Bulkhead")))
+		Expect(func() { bulkhead.StreamServerInterceptor(bulkhead.Options{}) }).To(PanicWith(ContainSubstring("Bulkhead")))
+	})
+
+	DescribeTable("should classify calls by operation by default",
+		func(fullMethod, class string) {
+			Expect(bulkhead.OperationClass(context.Background(), fullMethod)).To(Equal(class))
+		},
+		Entry("get", "/pkg.Service/GetCluster", httpbulkhead.ClassRead),
+		Entry("list", "/pkg.Service/ListClusters", httpbulkhead.ClassList),
+		Entry("delete", "/pkg.Service/DeleteCluster", httpbulkhead.ClassDelete),
+		Entry("create", "/pkg.Service/CreateCluster", httpbulkhead.ClassWrite),
+		Entry("other", pb.MyGreeter_SayHello_FullMethodName, httpbulkhead.ClassWrite),
+	)
+})
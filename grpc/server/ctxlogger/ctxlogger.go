@@ -9,6 +9,8 @@ import (
 
 	loggable "buf.build/gen/go/service-hub/loggable/protocolbuffers/go/proto"
 	"github.com/Azure/aks-middleware/grpc/common"
+	"github.com/Azure/aks-middleware/http/common/scrub"
+	middleware "github.com/grpc-ecosystem/go-grpc-middleware/v2"
 	"google.golang.org/grpc"
 	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
@@ -51,6 +53,7 @@ func GetLogger(ctx context.Context) *log.Logger {
 // Need to register requestid first to add request-id.
 // Then the logger can get the request-id.
 func UnaryServerInterceptor(logger *log.Logger, extractFunction ExtractFunction) grpc.UnaryServerInterceptor {
+	logger = scrub.Logger(logger)
 	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo,
 		handler grpc.UnaryHandler) (resp any, err error) {
 		l := logger
@@ -66,6 +69,29 @@ func UnaryServerInterceptor(logger *log.Logger, extractFunction ExtractFunction)
 	}
 }
 
+// StreamServerInterceptor returns a StreamServerInterceptor.
+// extractFunction is shared with the unary interceptor. For streaming calls it is
+// called with a nil request and a UnaryServerInfo carrying the stream's FullMethod,
+// since no message has been received when the stream starts.
+// The logger is added to the context of a wrapped grpc.ServerStream.
+func StreamServerInterceptor(logger *log.Logger, extractFunction ExtractFunction) grpc.StreamServerInterceptor {
+	logger = scrub.Logger(logger)
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+		ctx := ss.Context()
+		unaryInfo := &grpc.UnaryServerInfo{Server: srv, FullMethod: info.FullMethod}
+		l := logger
+		if extractFunction != nil {
+			l = extractFunction(ctx, nil, unaryInfo, l)
+		} else {
+			l = defaultExtractFunction(ctx, nil, unaryInfo, l)
+		}
+		wrapped := middleware.WrapServerStream(ss)
+		wrapped.WrappedContext = WithLogger(ctx, l)
+		return handler(srv, wrapped)
+	}
+}
+
 const (
 	methodLogKey         = "method"
 	requestContentLogKey = "request"
@@ -138,6 +164,8 @@ func FilterLogs(req any) map[string]interface{} {
 		}
 		// Filter out the fields that are not loggable using the helper function
 		reqPayload = filterLoggableFields(reqPayload, message)
+		// Free-form strings can still carry secrets the loggable option doesn't cover.
+		reqPayload = scrub.Default().Map(reqPayload)
 
 	}
 	return reqPayload
@@ -37,4 +37,13 @@ var _ = Describe("Filtered logging test", func() {
 			})) // check if logs is equivalent to the expected map
 		})
 	})
+
+	Context("when a loggable field contains a secret", func() {
+		It("should scrub the secret", func() {
+			logs := ctxlogger.FilterLogs(&pb.HelloRequest{Name: "contact test@test.com", Address: &pb.Address{City: "Seattle"}})
+
+			Expect(logs).To(HaveKeyWithValue("name", "contact [REDACTED]"))
+			Expect(logs["address"]).To(HaveKeyWithValue("city", "Seattle"))
+		})
+	})
 })
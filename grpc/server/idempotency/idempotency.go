@@ -0,0 +1,195 @@
+package idempotency
+
+// This package replays the responses of retried gRPC calls with the http/common/idempotency store.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/Azure/aks-middleware/http/common"
+	"github.com/Azure/aks-middleware/http/common/idempotency"
+	"github.com/Azure/aks-middleware/http/common/scrub"
+	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// KeyFunc returns the idempotency key of a call. Calls with an empty key are executed every time.
+type KeyFunc func(ctx context.Context, fullMethod string) string
+
+// DefaultKey keys calls by the x-ms-client-request-id (or the armclientrequestid forwarded by the gateway)
+// and the subscriptionid of the incoming metadata, forwarded by the gateway with the http/server/metadata
+// NewMetadataMiddleware options, and by full method.
+func DefaultKey(ctx context.Context, fullMethod string) string {
+	clientRequestID := firstValue(ctx, common.RequestARMClientRequestIDHeader)
+	if clientRequestID == "" {
+		clientRequestID = firstValue(ctx, common.ARMClientRequestIDKey)
+	}
+	return idempotency.Key(clientRequestID, firstValue(ctx, common.SubscriptionIDKey), fullMethod)
+}
+
+func firstValue(ctx context.Context, key string) string {
+	if vals := metadata.ValueFromIncomingContext(ctx, key); len(vals) > 0 {
+		return vals[0]
+	}
+	return ""
+}
+
+// Options configure the idempotency interceptor.
+type Options struct {
+	// Idempotency defaults to idempotency.New().
+	Idempotency *idempotency.Idempotency
+	// Methods are the full methods of the write calls made idempotent. Required.
+	Methods []string
+	// Key defaults to DefaultKey.
+	Key KeyFunc
+	// Logger logs store errors. Calls are executed when the store fails. Defaults to slog.Default().
+	Logger *slog.Logger
+}
+
+// UnaryServerInterceptor returns a server interceptor replaying the responses of the Methods calls retried
+// with the same key. Duplicates of a completed call get its stored response or error, and duplicates of a call
+// in flight fail with codes.Aborted, a 409 through the gateway, and calls reusing the key of a completed call
+// with a different request message fail with codes.FailedPrecondition and the ClientRequestIdReused reason,
+// a 400 through the gateway like the HTTP middleware. The retriable errors, those the gateway maps
+// to 429 and 5xx, aren't stored so that the retries are executed. The response header metadata isn't replayed.
+func UnaryServerInterceptor(opts Options) grpc.UnaryServerInterceptor {
+	if opts.Idempotency == nil {
+		opts.Idempotency = idempotency.New()
+	}
+	if opts.Key == nil {
+		opts.Key = DefaultKey
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	opts.Logger = scrub.Logger(opts.Logger)
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (resp any, err error) {
+		if !slices.Contains(opts.Methods, info.FullMethod) {
+			return handler(ctx, req)
+		}
+		key := opts.Key(ctx, info.FullMethod)
+		if key == "" {
+			return handler(ctx, req)
+		}
+		fp, fpErr := fingerprint(req)
+		if fpErr != nil {
+			opts.Logger.WarnContext(ctx, "request can't be fingerprinted, executing call", "error", fpErr)
+			return handler(ctx, req)
+		}
+		stored, beginErr := opts.Idempotency.Begin(ctx, key, fp)
+		switch {
+		case errors.Is(beginErr, idempotency.ErrInFlight):
+			return nil, status.Error(codes.Aborted, "a call with the same client request id is in progress, retry after it completes")
+		case errors.Is(beginErr, idempotency.ErrFingerprintMismatch):
+			return nil, reusedError()
+		case beginErr != nil:
+			opts.Logger.WarnContext(ctx, "idempotency store failed, executing call", "error", beginErr)
+			return handler(ctx, req)
+		case stored != nil:
+			resp, err, replayErr := replay(stored)
+			if replayErr == nil {
+				logging.AddFields(ctx, logging.Fields{idempotency.ReplayLogKey, true})
+				return resp, err
+			}
+			opts.Logger.WarnContext(ctx, "stored response can't be replayed, executing call", "error", replayErr)
+			return handler(ctx, req)
+		}
+
+		var toStore *idempotency.Response
+		defer func() {
+			// toStore stays nil when the handler panics, which cancels the key.
+			if endErr := opts.Idempotency.End(ctx, key, fp, toStore); endErr != nil {
+				opts.Logger.WarnContext(ctx, "idempotency store failed, response not stored", "error", endErr)
+			}
+		}()
+		resp, err = handler(ctx, req)
+		toStore, storeErr := response(resp, err)
+		if storeErr != nil {
+			opts.Logger.WarnContext(ctx, "response can't be stored", "error", storeErr)
+		}
+		return resp, err
+	}
+}
+
+// reusedError returns the error of the calls reusing the key of a different call, a 400 with the
+// idempotency.CodeClientRequestIDReused ARM code through the gateway like the HTTP middleware.
+func reusedError() error {
+	st := status.New(codes.FailedPrecondition, "a different call with the same client request id was completed, use a new client request id")
+	detailed, err := st.WithDetails(&errdetails.ErrorInfo{Reason: idempotency.CodeClientRequestIDReused})
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
+// fingerprint returns the fingerprint of the request message, see idempotency.Fingerprint.
+func fingerprint(req any) (string, error) {
+	msg, ok := req.(proto.Message)
+	if !ok {
+		return "", fmt.Errorf("request %T isn't a proto message", req)
+	}
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		return "", err
+	}
+	return idempotency.Fingerprint(data), nil
+}
+
+// response returns the Response storing the result of a call, or nil when it isn't stored.
+func response(resp any, err error) (*idempotency.Response, error) {
+	code := status.Code(err)
+	if code == codes.Canceled || idempotency.Retriable(runtime.HTTPStatusFromCode(code)) {
+		return nil, nil
+	}
+	var m proto.Message
+	if err != nil {
+		m = status.Convert(err).Proto()
+	} else {
+		msg, ok := resp.(proto.Message)
+		if !ok {
+			return nil, fmt.Errorf("response %T isn't a proto message", resp)
+		}
+		anyResp, anyErr := anypb.New(msg)
+		if anyErr != nil {
+			return nil, anyErr
+		}
+		m = anyResp
+	}
+	body, marshalErr := proto.Marshal(m)
+	if marshalErr != nil {
+		return nil, marshalErr
+	}
+	return &idempotency.Response{StatusCode: int(code), Body: body}, nil
+}
+
+// replay returns the response and error of a call stored by response, err being set when stored can't be read.
+func replay(stored *idempotency.Response) (resp any, callErr error, err error) {
+	if codes.Code(stored.StatusCode) != codes.OK {
+		st := &spb.Status{}
+		if err := proto.Unmarshal(stored.Body, st); err != nil {
+			return nil, nil, err
+		}
+		return nil, status.FromProto(st).Err(), nil
+	}
+	anyResp := &anypb.Any{}
+	if err := proto.Unmarshal(stored.Body, anyResp); err != nil {
+		return nil, nil, err
+	}
+	resp, err = anyResp.UnmarshalNew()
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, nil, nil
+}
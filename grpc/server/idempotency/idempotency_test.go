@@ -0,0 +1,121 @@
+package idempotency_test
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/Azure/aks-middleware/grpc/server/idempotency"
+	"github.com/Azure/aks-middleware/http/common"
+	httpidempotency "github.com/Azure/aks-middleware/http/common/idempotency"
+	pb "github.com/Azure/aks-middleware/test/api/v1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const method = "/MyGreeter/SayHello"
+
+var _ = Describe("Idempotency interceptor", func() {
+	var (
+		unary   grpc.UnaryServerInterceptor
+		calls   atomic.Int32
+		err     error
+		release chan struct{}
+		name    string
+	)
+
+	BeforeEach(func() {
+		unary = idempotency.UnaryServerInterceptor(idempotency.Options{Methods: []string{method}})
+		calls.Store(0)
+		err = nil
+		release = nil
+		name = "Test"
+	})
+
+	call := func(fullMethod string, md ...string) (any, error) {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(md...))
+		return unary(ctx, &pb.HelloRequest{Name: name}, &grpc.UnaryServerInfo{FullMethod: fullMethod},
+			func(ctx context.Context, req any) (any, error) {
+				calls.Add(1)
+				if release != nil {
+					<-release
+				}
+				if err != nil {
+					return nil, err
+				}
+				return &pb.HelloReply{Message: "Hello Test"}, nil
+			})
+	}
+
+	It("should replay the response of a completed call", func() {
+		first, callErr := call(method, common.RequestARMClientRequestIDHeader, "id-1", "subscriptionid", "sub1")
+		Expect(callErr).ToNot(HaveOccurred())
+		second, callErr := call(method, common.RequestARMClientRequestIDHeader, "id-1", "subscriptionid", "sub1")
+		Expect(callErr).ToNot(HaveOccurred())
+		Expect(proto.Equal(second.(proto.Message), first.(proto.Message))).To(BeTrue())
+		Expect(calls.Load()).To(BeEquivalentTo(1))
+	})
+
+	It("should reject a different call reusing the key of a completed call", func() {
+		_, callErr := call(method, common.RequestARMClientRequestIDHeader, "id-1")
+		Expect(callErr).ToNot(HaveOccurred())
+		name = "Other"
+		_, callErr = call(method, common.RequestARMClientRequestIDHeader, "id-1")
+		Expect(status.Code(callErr)).To(Equal(codes.FailedPrecondition))
+		Expect(status.Convert(callErr).Details()).To(ContainElement(HaveField("Reason", httpidempotency.CodeClientRequestIDReused)))
+		Expect(calls.Load()).To(BeEquivalentTo(1))
+	})
+
+	It("should key the calls by the client request id forwarded by the gateway", func() {
+		_, _ = call(method, common.ARMClientRequestIDKey, "id-1")
+		_, _ = call(method, common.ARMClientRequestIDKey, "id-1")
+		_, _ = call(method, common.ARMClientRequestIDKey, "id-1", "subscriptionid", "sub2")
+		Expect(calls.Load()).To(BeEquivalentTo(2))
+	})
+
+	It("should replay the non retriable errors", func() {
+		err = status.Error(codes.InvalidArgument, "invalid name")
+		_, callErr := call(method, common.RequestARMClientRequestIDHeader, "id-1")
+		Expect(status.Code(callErr)).To(Equal(codes.InvalidArgument))
+		_, callErr = call(method, common.RequestARMClientRequestIDHeader, "id-1")
+		Expect(status.Code(callErr)).To(Equal(codes.InvalidArgument))
+		Expect(status.Convert(callErr).Message()).To(Equal("invalid name"))
+		Expect(calls.Load()).To(BeEquivalentTo(1))
+	})
+
+	It("should execute the retries of retriable errors", func() {
+		err = status.Error(codes.Unavailable, "try again")
+		_, _ = call(method, common.RequestARMClientRequestIDHeader, "id-1")
+		err = nil
+		_, callErr := call(method, common.RequestARMClientRequestIDHeader, "id-1")
+		Expect(callErr).ToNot(HaveOccurred())
+		Expect(calls.Load()).To(BeEquivalentTo(2))
+	})
+
+	It("should execute the calls without key or of other methods", func() {
+		_, _ = call(method)
+		_, _ = call(method)
+		_, _ = call("/MyGreeter/Other", common.RequestARMClientRequestIDHeader, "id-1")
+		_, _ = call("/MyGreeter/Other", common.RequestARMClientRequestIDHeader, "id-1")
+		Expect(calls.Load()).To(BeEquivalentTo(4))
+	})
+
+	It("should abort the duplicates while the first attempt is in flight", func() {
+		release = make(chan struct{})
+		done := make(chan error)
+		go func() {
+			_, callErr := call(method, common.RequestARMClientRequestIDHeader, "id-1")
+			done <- callErr
+		}()
+		Eventually(calls.Load).Should(BeEquivalentTo(1))
+
+		_, callErr := call(method, common.RequestARMClientRequestIDHeader, "id-1")
+		Expect(status.Code(callErr)).To(Equal(codes.Aborted))
+		close(release)
+		Expect(<-done).ToNot(HaveOccurred())
+	})
+})
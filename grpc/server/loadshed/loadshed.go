@@ -0,0 +1,62 @@
+package loadshed
+
+// This package sheds gRPC calls with the http/common/loadshed adaptive concurrency limiter.
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/Azure/aks-middleware/http/common"
+	httploadshed "github.com/Azure/aks-middleware/http/common/loadshed"
+	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// UnaryServerInterceptor returns a server interceptor rejecting calls with codes.Unavailable, RetryInfo
+// details and a retry-after trailer when the concurrency limit of limiter is reached. The finished call log
+// of a rejected call has shed=true. Register it after the logging interceptor and before the handler.
+func UnaryServerInterceptor(limiter *httploadshed.Limiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (any, error) {
+		release := limiter.Acquire()
+		if release == nil {
+			return nil, shed(ctx, limiter, func(md metadata.MD) {
+				_ = grpc.SetTrailer(ctx, md)
+			})
+		}
+		defer release()
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor is the streaming counterpart of UnaryServerInterceptor.
+// Streams count against the limit until they finish, but don't adjust it: watch and log-tail streams
+// outlive any latency threshold, see Limiter.AcquireStream.
+func StreamServerInterceptor(limiter *httploadshed.Limiter) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+		release := limiter.AcquireStream()
+		if release == nil {
+			return shed(ss.Context(), limiter, ss.SetTrailer)
+		}
+		defer release()
+		return handler(srv, ss)
+	}
+}
+
+func shed(ctx context.Context, limiter *httploadshed.Limiter, setTrailer func(metadata.MD)) error {
+	logging.AddFields(ctx, logging.Fields{httploadshed.ShedLogKey, true})
+	seconds := common.RetryAfterSeconds(limiter.RetryAfter())
+	setTrailer(metadata.Pairs(common.RetryAfterKey, strconv.Itoa(seconds)))
+	st := status.New(codes.Unavailable, "server overloaded, retry after "+strconv.Itoa(seconds)+" seconds")
+	detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(limiter.RetryAfter())})
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
@@ -0,0 +1,114 @@
+package loadshed_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/Azure/aks-middleware/grpc/interceptor"
+	httploadshed "github.com/Azure/aks-middleware/http/common/loadshed"
+	pb "github.com/Azure/aks-middleware/test/api/v1"
+	"github.com/Azure/aks-middleware/test/server"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// blockingServer blocks SayHello until release is closed.
+type blockingServer struct {
+	pb.UnimplementedMyGreeterServer
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingServer) SayHello(ctx context.Context, req *pb.HelloRequest) (*pb.HelloReply, error) {
+	s.started <- struct{}{}
+	<-s.release
+	return &pb.HelloReply{Message: "Hello " + req.Name}, nil
+}
+
+var _ = Describe("Load shedding interceptor", func() {
+	It("should shed calls over the limit with Unavailable and log them", func() {
+		lis, err := net.Listen("tcp", "localhost:0")
+		Expect(err).ToNot(HaveOccurred())
+		apiOutput := &bytes.Buffer{}
+		limiter := httploadshed.NewLimiter(httploadshed.Options{InitialLimit: 1, MaxLimit: 1})
+		options := interceptor.ServerInterceptorLogOptions{
+			Logger:       slog.New(slog.NewJSONHandler(io.Discard, nil)),
+			APIOutput:    apiOutput,
+			CtxOutput:    io.Discard,
+			LoadShedding: limiter,
+		}
+		grpcServer := grpc.NewServer(grpc.ChainUnaryInterceptor(interceptor.DefaultServerInterceptors(options)...))
+		srv := &blockingServer{started: make(chan struct{}), release: make(chan struct{})}
+		pb.RegisterMyGreeterServer(grpcServer, srv)
+		go func() {
+			_ = grpcServer.Serve(lis)
+		}()
+		defer grpcServer.Stop()
+		clientConn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		Expect(err).ToNot(HaveOccurred())
+		defer clientConn.Close()
+		client := pb.NewMyGreeterClient(clientConn)
+		req := &pb.HelloRequest{Name: "Test", Age: 30, Email: "test@test.com"}
+
+		done := make(chan error)
+		go func() {
+			_, err := client.SayHello(context.Background(), req)
+			done <- err
+		}()
+		<-srv.started
+		Expect(limiter.InFlight()).To(Equal(1))
+
+		_, err = client.SayHello(context.Background(), req)
+		Expect(status.Code(err)).To(Equal(codes.Unavailable))
+		Expect(status.Convert(err).Details()).To(ContainElement(BeAssignableToTypeOf(&errdetails.RetryInfo{})))
+		Expect(apiOutput.String()).To(ContainSubstring(`"shed":true`))
+
+		close(srv.release)
+		Expect(<-done).To(Succeed())
+		Expect(limiter.InFlight()).To(BeZero())
+	})
+
+	It("should not adjust the limit when long streams finish", func() {
+		lis, err := net.Listen("tcp", "localhost:0")
+		Expect(err).ToNot(HaveOccurred())
+		limiter := httploadshed.NewLimiter(httploadshed.Options{InitialLimit: 10, LatencyThreshold: time.Millisecond})
+		options := interceptor.ServerInterceptorLogOptions{
+			Logger:       slog.New(slog.NewJSONHandler(io.Discard, nil)),
+			APIOutput:    io.Discard,
+			CtxOutput:    io.Discard,
+			LoadShedding: limiter,
+		}
+		grpcServer := grpc.NewServer(grpc.ChainStreamInterceptor(interceptor.DefaultServerStreamInterceptors(options)...))
+		server.RegisterStreamGreeterServer(grpcServer, &server.TestStreamServer{})
+		go func() {
+			_ = grpcServer.Serve(lis)
+		}()
+		defer grpcServer.Stop()
+		clientConn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		Expect(err).ToNot(HaveOccurred())
+		defer clientConn.Close()
+
+		for i := 0; i < 3; i++ {
+			stream, err := server.NewSayHelloStream(context.Background(), clientConn)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stream.SendMsg(&pb.HelloRequest{Name: "Stream", Age: 30, Email: "test@test.com"})).To(Succeed())
+			Expect(stream.RecvMsg(&pb.HelloReply{})).To(Succeed())
+			Expect(limiter.InFlight()).To(Equal(1))
+			// The stream outlives the latency threshold.
+			time.Sleep(5 * time.Millisecond)
+			Expect(stream.CloseSend()).To(Succeed())
+			Expect(stream.RecvMsg(&pb.HelloReply{})).To(MatchError(io.EOF))
+			Eventually(limiter.InFlight).Should(BeZero())
+		}
+		Expect(limiter.Limit()).To(Equal(10))
+	})
+})
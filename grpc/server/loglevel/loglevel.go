@@ -0,0 +1,178 @@
+package loglevel
+
+// This package exposes a loglevel.Controller as a gRPC admin service. The service is registered by hand
+// with google.protobuf.Struct messages so that no generated code is needed:
+//
+//	GetLogLevels(Struct{})                                   returns Struct{"settings": [...]}
+//	SetLogLevel(Struct{"source", "method", "level", "ttl"}) returns Struct{"settings": [...]}
+//	ResetLogLevel(Struct{"source", "method"})               returns Struct{"settings": [...]}
+//
+// The calls go through the server interceptors, so the changes are logged to the CtxLog when the
+// controller uses ctxlogger.GetLogger.
+
+import (
+	"context"
+	"encoding/json"
+
+	httploglevel "github.com/Azure/aks-middleware/http/common/loglevel"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	ServiceName         = "aksmiddleware.admin.v1.LogLevelAdmin"
+	GetLogLevelsMethod  = "/" + ServiceName + "/GetLogLevels"
+	SetLogLevelMethod   = "/" + ServiceName + "/SetLogLevel"
+	ResetLogLevelMethod = "/" + ServiceName + "/ResetLogLevel"
+)
+
+// LogLevelAdminServer is the server API for the LogLevelAdmin service.
+type LogLevelAdminServer interface {
+	GetLogLevels(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	SetLogLevel(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	ResetLogLevel(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+}
+
+// ServiceDesc is the grpc.ServiceDesc for the LogLevelAdmin service.
+var ServiceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*LogLevelAdminServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "GetLogLevels", Handler: unaryHandler(GetLogLevelsMethod, LogLevelAdminServer.GetLogLevels)},
+		{MethodName: "SetLogLevel", Handler: unaryHandler(SetLogLevelMethod, LogLevelAdminServer.SetLogLevel)},
+		{MethodName: "ResetLogLevel", Handler: unaryHandler(ResetLogLevelMethod, LogLevelAdminServer.ResetLogLevel)},
+	},
+}
+
+func unaryHandler(
+	fullMethod string,
+	call func(LogLevelAdminServer, context.Context, *structpb.Struct) (*structpb.Struct, error),
+) func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	return func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+		in := &structpb.Struct{}
+		if err := dec(in); err != nil {
+			return nil, err
+		}
+		if interceptor == nil {
+			return call(srv.(LogLevelAdminServer), ctx, in)
+		}
+		info := &grpc.UnaryServerInfo{Server: srv, FullMethod: fullMethod}
+		handler := func(ctx context.Context, req any) (any, error) {
+			return call(srv.(LogLevelAdminServer), ctx, req.(*structpb.Struct))
+		}
+		return interceptor(ctx, in, info, handler)
+	}
+}
+
+// RegisterLogLevelAdminServer registers the LogLevelAdmin service for c on s.
+func RegisterLogLevelAdminServer(s grpc.ServiceRegistrar, c *httploglevel.Controller) {
+	s.RegisterService(&ServiceDesc, NewServer(c))
+}
+
+// NewServer returns the LogLevelAdminServer for c.
+func NewServer(c *httploglevel.Controller) LogLevelAdminServer {
+	return &server{controller: c}
+}
+
+type server struct {
+	controller *httploglevel.Controller
+}
+
+func (s *server) GetLogLevels(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	return settingsStruct(s.controller.Settings())
+}
+
+func (s *server) SetLogLevel(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	var setReq httploglevel.SetRequest
+	if err := fromStruct(req, &setReq); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := setReq.Apply(ctx, s.controller); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return settingsStruct(s.controller.Settings())
+}
+
+func (s *server) ResetLogLevel(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	fields := req.GetFields()
+	source := httploglevel.Source(fields["source"].GetStringValue())
+	if err := s.controller.Reset(ctx, source, fields["method"].GetStringValue()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return settingsStruct(s.controller.Settings())
+}
+
+// LogLevelAdminClient is the client API for the LogLevelAdmin service.
+type LogLevelAdminClient struct {
+	cc grpc.ClientConnInterface
+}
+
+// NewLogLevelAdminClient returns a LogLevelAdminClient using cc.
+func NewLogLevelAdminClient(cc grpc.ClientConnInterface) *LogLevelAdminClient {
+	return &LogLevelAdminClient{cc: cc}
+}
+
+// GetLogLevels returns the current settings.
+func (c *LogLevelAdminClient) GetLogLevels(ctx context.Context, opts ...grpc.CallOption) ([]httploglevel.Setting, error) {
+	return c.invoke(ctx, GetLogLevelsMethod, map[string]any{}, opts...)
+}
+
+// SetLogLevel applies req and returns the new settings.
+func (c *LogLevelAdminClient) SetLogLevel(ctx context.Context, req httploglevel.SetRequest, opts ...grpc.CallOption) ([]httploglevel.Setting, error) {
+	return c.invoke(ctx, SetLogLevelMethod, req, opts...)
+}
+
+// ResetLogLevel resets the level of method for source and returns the new settings.
+func (c *LogLevelAdminClient) ResetLogLevel(ctx context.Context, source httploglevel.Source, method string, opts ...grpc.CallOption) ([]httploglevel.Setting, error) {
+	return c.invoke(ctx, ResetLogLevelMethod, map[string]any{"source": string(source), "method": method}, opts...)
+}
+
+func (c *LogLevelAdminClient) invoke(ctx context.Context, method string, req any, opts ...grpc.CallOption) ([]httploglevel.Setting, error) {
+	in, err := toStruct(req)
+	if err != nil {
+		return nil, err
+	}
+	out := &structpb.Struct{}
+	if err := c.cc.Invoke(ctx, method, in, out, opts...); err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Settings []httploglevel.Setting `json:"settings"`
+	}
+	if err := fromStruct(out, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Settings, nil
+}
+
+func settingsStruct(settings []httploglevel.Setting) (*structpb.Struct, error) {
+	s, err := toStruct(map[string]any{"settings": settings})
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return s, nil
+}
+
+// toStruct converts v to a Struct through its JSON encoding.
+func toStruct(v any) (*structpb.Struct, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	s := &structpb.Struct{}
+	if err := s.UnmarshalJSON(b); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// fromStruct converts s to v through its JSON encoding.
+func fromStruct(s *structpb.Struct, v any) error {
+	b, err := s.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
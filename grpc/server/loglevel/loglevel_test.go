@@ -0,0 +1,141 @@
+package loglevel_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+
+	"github.com/Azure/aks-middleware/grpc/interceptor"
+	"github.com/Azure/aks-middleware/grpc/server/ctxlogger"
+	"github.com/Azure/aks-middleware/grpc/server/loglevel"
+	httploglevel "github.com/Azure/aks-middleware/http/common/loglevel"
+	pb "github.com/Azure/aks-middleware/test/api/v1"
+	"github.com/Azure/aks-middleware/test/server"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// syncBuffer is a bytes.Buffer safe for the concurrent writes of the server loggers.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+var _ = Describe("LogLevelAdmin service", func() {
+	var (
+		grpcServer  *grpc.Server
+		lis         net.Listener
+		clientConn  *grpc.ClientConn
+		adminClient *loglevel.LogLevelAdminClient
+		client      pb.MyGreeterClient
+		apiOutput   *syncBuffer
+		ctxOutput   *syncBuffer
+		ctx         context.Context
+	)
+
+	BeforeEach(func() {
+		var err error
+		lis, err = net.Listen("tcp", "localhost:0")
+		Expect(err).ToNot(HaveOccurred())
+
+		apiOutput = &syncBuffer{}
+		ctxOutput = &syncBuffer{}
+		controller := httploglevel.NewController(ctxlogger.GetLogger)
+		options := interceptor.ServerInterceptorLogOptions{
+			Logger:    slog.New(slog.NewJSONHandler(io.Discard, nil)),
+			APIOutput: apiOutput,
+			CtxOutput: ctxOutput,
+			LogLevels: controller,
+		}
+		grpcServer = grpc.NewServer(
+			grpc.ChainUnaryInterceptor(interceptor.DefaultServerInterceptors(options)...),
+		)
+		pb.RegisterMyGreeterServer(grpcServer, &server.TestServer{})
+		loglevel.RegisterLogLevelAdminServer(grpcServer, controller)
+		go func() {
+			_ = grpcServer.Serve(lis)
+		}()
+
+		clientConn, err = grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		Expect(err).ToNot(HaveOccurred())
+		adminClient = loglevel.NewLogLevelAdminClient(clientConn)
+		client = pb.NewMyGreeterClient(clientConn)
+		ctx = context.Background()
+	})
+
+	AfterEach(func() {
+		clientConn.Close()
+		grpcServer.Stop()
+		lis.Close()
+	})
+
+	sayHello := func() {
+		_, err := client.SayHello(ctx, &pb.HelloRequest{Name: "Test", Age: 30, Email: "test@test.com"})
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	It("should get the levels", func() {
+		settings, err := adminClient.GetLogLevels(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(settings).To(HaveLen(2))
+		Expect(settings[0].Source).To(Equal(httploglevel.SourceAPIRequestLog))
+		Expect(settings[0].Level).To(Equal(slog.LevelInfo))
+	})
+
+	It("should change the levels of the default interceptors and log the changes to the CtxLog", func() {
+		_, err := adminClient.SetLogLevel(ctx, httploglevel.SetRequest{
+			Source: httploglevel.SourceAPIRequestLog,
+			Level:  "WARN",
+			TTL:    "1h",
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ctxOutput.String()).To(ContainSubstring(`"msg":"log level changed"`))
+		Expect(ctxOutput.String()).To(ContainSubstring(`"method":"` + loglevel.SetLogLevelMethod + `"`))
+
+		sayHello()
+		Expect(apiOutput.String()).ToNot(ContainSubstring(pb.MyGreeter_SayHello_FullMethodName))
+
+		settings, err := adminClient.SetLogLevel(ctx, httploglevel.SetRequest{
+			Source: httploglevel.SourceAPIRequestLog,
+			Method: pb.MyGreeter_SayHello_FullMethodName,
+			Level:  "INFO",
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(settings).To(HaveLen(3))
+		sayHello()
+		Expect(apiOutput.String()).To(ContainSubstring(`"method":"SayHello"`))
+
+		settings, err = adminClient.ResetLogLevel(ctx, httploglevel.SourceAPIRequestLog, pb.MyGreeter_SayHello_FullMethodName)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(settings).To(HaveLen(2))
+		Expect(settings[0].Level).To(Equal(slog.LevelWarn))
+		Expect(ctxOutput.String()).To(ContainSubstring(`"msg":"log level reset"`))
+	})
+
+	It("should reject invalid levels", func() {
+		_, err := adminClient.SetLogLevel(ctx, httploglevel.SetRequest{
+			Source: httploglevel.SourceCtxLog,
+			Level:  "LOUD",
+		})
+		Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+	})
+})
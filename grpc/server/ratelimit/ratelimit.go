@@ -0,0 +1,116 @@
+package ratelimit
+
+// This package rate limits gRPC calls with the http/common/ratelimit token buckets.
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/Azure/aks-middleware/http/common"
+	"github.com/Azure/aks-middleware/http/common/ratelimit"
+	"github.com/Azure/aks-middleware/http/common/scrub"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// KeyFunc returns the rate limiting key of a call. Calls with an empty key are not limited.
+type KeyFunc func(ctx context.Context, fullMethod string) string
+
+// MetadataKey keys calls by the first value of key in the incoming metadata,
+// e.g. "x-ms-client-tenant-id" or the "subscriptionid" forwarded by the gateway.
+func MetadataKey(key string) KeyFunc {
+	return func(ctx context.Context, fullMethod string) string {
+		if vals := metadata.ValueFromIncomingContext(ctx, key); len(vals) > 0 {
+			return vals[0]
+		}
+		return ""
+	}
+}
+
+// Options configure the rate limiting interceptors.
+type Options struct {
+	// Limiter is required.
+	Limiter *ratelimit.Limiter
+	// Key defaults to MetadataKey(common.SubscriptionIDKey), the subscription of the ARM path that the gateway
+	// forwards when it uses the http/server/metadata NewMetadataMiddleware options.
+	Key KeyFunc
+	// Logger logs store errors. Calls are allowed when the store fails. Defaults to slog.Default().
+	Logger *slog.Logger
+}
+
+// UnaryServerInterceptor returns a server interceptor rate limiting calls per key.
+// Rejected calls fail with codes.ResourceExhausted, with RetryInfo and QuotaFailure details
+// and the common.RetryAfterKey trailer. It panics when opts has no Limiter.
+func UnaryServerInterceptor(opts Options) grpc.UnaryServerInterceptor {
+	opts = withDefaults(opts)
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (any, error) {
+		if err := take(ctx, opts, info.FullMethod, func(md metadata.MD) {
+			_ = grpc.SetTrailer(ctx, md)
+		}); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor is the streaming counterpart of UnaryServerInterceptor.
+func StreamServerInterceptor(opts Options) grpc.StreamServerInterceptor {
+	opts = withDefaults(opts)
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+		if err := take(ss.Context(), opts, info.FullMethod, ss.SetTrailer); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+// withDefaults returns opts with the defaults of the unset options. Without a Limiter every call would
+// panic, so it panics when the interceptor is created instead.
+func withDefaults(opts Options) Options {
+	if opts.Limiter == nil {
+		panic("ratelimit: Options.Limiter is required")
+	}
+	if opts.Key == nil {
+		opts.Key = MetadataKey(common.SubscriptionIDKey)
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	opts.Logger = scrub.Logger(opts.Logger)
+	return opts
+}
+
+// take returns the error of a rejected call, setting its trailer with setTrailer.
+func take(ctx context.Context, opts Options, fullMethod string, setTrailer func(metadata.MD)) error {
+	key := opts.Key(ctx, fullMethod)
+	result, err := opts.Limiter.Take(ctx, key)
+	if err != nil {
+		opts.Logger.WarnContext(ctx, "rate limit store failed, allowing call", "error", err)
+		return nil
+	}
+	if result.Allowed {
+		return nil
+	}
+	seconds := common.RetryAfterSeconds(result.RetryAfter)
+	setTrailer(metadata.Pairs(common.RetryAfterKey, strconv.Itoa(seconds)))
+	st := status.New(codes.ResourceExhausted, fmt.Sprintf("rate limit exceeded for %s, retry after %d seconds", key, seconds))
+	detailed, err := st.WithDetails(
+		&errdetails.RetryInfo{RetryDelay: durationpb.New(result.RetryAfter)},
+		&errdetails.QuotaFailure{Violations: []*errdetails.QuotaFailure_Violation{{
+			Subject:     key,
+			Description: "request rate limit exceeded",
+		}}},
+	)
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
@@ -0,0 +1,134 @@
+package ratelimit_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/Azure/aks-middleware/grpc/server/ratelimit"
+	"github.com/Azure/aks-middleware/http/common"
+	httpratelimit "github.com/Azure/aks-middleware/http/common/ratelimit"
+	httpmetadata "github.com/Azure/aks-middleware/http/server/metadata"
+	pb "github.com/Azure/aks-middleware/test/api/v1"
+	"github.com/Azure/aks-middleware/test/server"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var _ = Describe("Rate limit interceptor", func() {
+	var (
+		grpcServer *grpc.Server
+		lis        net.Listener
+		clientConn *grpc.ClientConn
+		client     pb.MyGreeterClient
+	)
+
+	BeforeEach(func() {
+		var err error
+		lis, err = net.Listen("tcp", "localhost:0")
+		Expect(err).ToNot(HaveOccurred())
+		opts := ratelimit.Options{
+			Limiter: httpratelimit.New(httpratelimit.Limit{Rate: 0.001, Burst: 1}),
+			Key:     ratelimit.MetadataKey("x-ms-client-tenant-id"),
+		}
+		grpcServer = grpc.NewServer(grpc.ChainUnaryInterceptor(ratelimit.UnaryServerInterceptor(opts)))
+		pb.RegisterMyGreeterServer(grpcServer, &server.TestServer{})
+		go func() {
+			_ = grpcServer.Serve(lis)
+		}()
+		clientConn, err = grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		Expect(err).ToNot(HaveOccurred())
+		client = pb.NewMyGreeterClient(clientConn)
+	})
+
+	AfterEach(func() {
+		clientConn.Close()
+		grpcServer.Stop()
+		lis.Close()
+	})
+
+	sayHello := func(tenant string, opts ...grpc.CallOption) error {
+		ctx := context.Background()
+		if tenant != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, "x-ms-client-tenant-id", tenant)
+		}
+		_, err := client.SayHello(ctx, &pb.HelloRequest{Name: "Test"}, opts...)
+		return err
+	}
+
+	It("should reject calls over the limit with ResourceExhausted and retry info", func() {
+		Expect(sayHello("tenant1")).To(Succeed())
+		var trailer metadata.MD
+		err := sayHello("tenant1", grpc.Trailer(&trailer))
+		Expect(status.Code(err)).To(Equal(codes.ResourceExhausted))
+		Expect(trailer.Get(common.RetryAfterKey)).ToNot(BeEmpty())
+
+		var retryInfo *errdetails.RetryInfo
+		for _, detail := range status.Convert(err).Details() {
+			if info, ok := detail.(*errdetails.RetryInfo); ok {
+				retryInfo = info
+			}
+		}
+		Expect(retryInfo).ToNot(BeNil())
+		Expect(retryInfo.RetryDelay.AsDuration()).To(BeNumerically(">", 0))
+	})
+
+	It("should keep separate buckets per key and not limit calls without a key", func() {
+		Expect(sayHello("tenant1")).To(Succeed())
+		Expect(sayHello("tenant2")).To(Succeed())
+		Expect(sayHello("")).To(Succeed())
+		Expect(sayHello("")).To(Succeed())
+	})
+
+	It("should require a Limiter", func() {
+		Expect(func() { ratelimit.UnaryServerInterceptor(ratelimit.Options{}) }).To(PanicWith(ContainSubstring("Limiter")))
+		Expect(func() { ratelimit.StreamServerInterceptor(ratelimit.Options{}) }).To(PanicWith(ContainSubstring("Limiter")))
+	})
+
+	It("should key the calls by the subscription forwarded by the gateway by default", func() {
+		gwServer := grpc.NewServer(grpc.UnaryInterceptor(ratelimit.UnaryServerInterceptor(ratelimit.Options{
+			Limiter: httpratelimit.New(httpratelimit.Limit{Rate: 0.001, Burst: 1}),
+		})))
+		pb.RegisterMyGreeterServer(gwServer, &server.TestServer{})
+		gwLis, err := net.Listen("tcp", "localhost:0")
+		Expect(err).ToNot(HaveOccurred())
+		go func() {
+			_ = gwServer.Serve(gwLis)
+		}()
+		defer gwServer.Stop()
+		conn, err := grpc.NewClient(gwLis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+		gwClient := pb.NewMyGreeterClient(conn)
+
+		// The test API has no ARM route, so this one is handled like the generated gateway handlers do.
+		gwMux := runtime.NewServeMux(httpmetadata.NewMetadataMiddleware(nil, nil)...)
+		Expect(gwMux.HandlePath(http.MethodPost, "/subscriptions/{subscriptionID}/hello",
+			func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+				ctx, err := runtime.AnnotateContext(r.Context(), gwMux, r, "/MyGreeter/SayHello")
+				if err == nil {
+					_, err = gwClient.SayHello(ctx, &pb.HelloRequest{Name: "Test"})
+				}
+				if err != nil {
+					runtime.HTTPError(ctx, gwMux, &runtime.JSONPb{}, w, r, err)
+				}
+			})).To(Succeed())
+		post := func(subscription string) int {
+			rec := httptest.NewRecorder()
+			gwMux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/subscriptions/"+subscription+"/hello", nil))
+			return rec.Code
+		}
+
+		Expect(post("sub1")).To(Equal(http.StatusOK))
+		Expect(post("sub2")).To(Equal(http.StatusOK))
+		Expect(post("sub1")).To(Equal(http.StatusTooManyRequests))
+	})
+})
@@ -8,6 +8,7 @@ import (
 	"io"
 
 	"github.com/Azure/aks-middleware/http/common"
+	middleware "github.com/grpc-ecosystem/go-grpc-middleware/v2"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
 )
@@ -16,26 +17,44 @@ import (
 
 // UnaryServerInterceptor returns a server interceptor
 // that add a request ID to the incoming metadata if there is none.
+// The request ID is also sent in the response header metadata, e.g. for the gateway ARM error handler.
 func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo,
 		handler grpc.UnaryHandler) (resp any, err error) {
 		// log.Print("requestid ctx: ", ctx)
-		ctx = generateRequestID(ctx)
+		ctx, id := generateRequestID(ctx)
+		_ = grpc.SetHeader(ctx, metadata.Pairs(common.RequestIDMetadataHeader, id))
 		return handler(ctx, req)
 	}
 }
 
-func generateRequestID(ctx context.Context) context.Context {
+// StreamServerInterceptor returns a stream server interceptor
+// that add a request ID to the incoming metadata if there is none.
+// The enriched context is carried by a wrapped grpc.ServerStream.
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+		wrapped := middleware.WrapServerStream(ss)
+		var id string
+		wrapped.WrappedContext, id = generateRequestID(ss.Context())
+		_ = ss.SetHeader(metadata.Pairs(common.RequestIDMetadataHeader, id))
+		return handler(srv, wrapped)
+	}
+}
+
+// generateRequestID returns ctx with a request ID in the incoming metadata, and that request ID.
+func generateRequestID(ctx context.Context) (context.Context, string) {
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
 		md = metadata.MD{}
 	}
 	if vals := md.Get(common.RequestIDMetadataHeader); len(vals) > 0 {
-		return ctx
+		return ctx, vals[0]
 	}
-	md.Set(common.RequestIDMetadataHeader, shortID())
-	return metadata.NewIncomingContext(ctx, md)
-
+	id := shortID()
+	md = md.Copy()
+	md.Set(common.RequestIDMetadataHeader, id)
+	return metadata.NewIncomingContext(ctx, md), id
 }
 
 func shortID() string {
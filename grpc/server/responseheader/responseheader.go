@@ -18,23 +18,45 @@ func UnaryServerInterceptor(metadataToHeader map[string]string) grpc.UnaryServer
 	}
 }
 
+// StreamServerInterceptor returns a stream server interceptor
+// that copies selected request metadata into response metadata.
+func StreamServerInterceptor(metadataToHeader map[string]string) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		filteredMD := filterMetadata(ss.Context(), metadataToHeader)
+		if filteredMD != nil {
+			if err := ss.SetHeader(filteredMD); err != nil {
+				return err
+			}
+		}
+		return handler(srv, ss)
+	}
+}
+
 func copyMetadata(ctx context.Context, metadataToHeader map[string]string) error {
+	filteredMD := filterMetadata(ctx, metadataToHeader)
+	if filteredMD == nil {
+		return nil
+	}
+
+	// append filteredMD to any existing headers, does not replace the entire header metadata
+	// if setHeader called multiple times, all the provided metadata will be merged
+	if err := grpc.SetHeader(ctx, filteredMD); err != nil {
+		return err
+	}
+	return nil
+}
+
+// filterMetadata returns the allowed incoming metadata, or nil if there is no incoming metadata.
+func filterMetadata(ctx context.Context, metadataToHeader map[string]string) metadata.MD {
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
 		return nil
 	}
-	// Filter and set the allowed metadata as response headers
 	filteredMD := metadata.New(nil)
 	for key := range metadataToHeader {
 		if values, exists := md[key]; exists {
 			filteredMD.Set(key, values...)
 		}
 	}
-
-	// append filteredMD to any existing headers, does not replace the entire header metadata
-	// if setHeader called multiple times, all the provided metadata will be merged
-	if err := grpc.SetHeader(ctx, filteredMD); err != nil {
-		return err
-	}
-	return nil
+	return filteredMD
 }
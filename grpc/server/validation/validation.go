@@ -0,0 +1,144 @@
+package validation
+
+// This package validates gRPC messages with protovalidate. Invalid requests fail with InvalidArgument and
+// google.rpc.BadRequest field violations, and the violations are added to the finished call log.
+
+import (
+	"context"
+	"errors"
+
+	"buf.build/go/protovalidate"
+	middleware "github.com/grpc-ecosystem/go-grpc-middleware/v2"
+	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Log keys of the violations in the finished call log.
+const (
+	RequestLogKey  = "validation_errors"
+	ResponseLogKey = "response_validation_errors"
+)
+
+// Violation is a violation as logged.
+type Violation struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Options configure the validation interceptors.
+type Options struct {
+	// Validator validates the messages. Build it with protovalidate.New to register custom constraints or
+	// compile the rules of known messages eagerly.
+	Validator protovalidate.Validator
+	// ValidateResponses also validates the responses when set. Invalid responses fail with Internal.
+	// It is meant for non-production environments.
+	ValidateResponses bool
+}
+
+// UnaryServerInterceptor returns a server interceptor validating the requests, and the responses when
+// opts.ValidateResponses is set. Register it after the logging interceptor to add the violations to its
+// finished call log.
+func UnaryServerInterceptor(opts Options) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (any, error) {
+		if err := validate(ctx, opts.Validator, req, RequestLogKey); err != nil {
+			return nil, err
+		}
+		resp, err := handler(ctx, req)
+		if err == nil && opts.ValidateResponses {
+			if err := validate(ctx, opts.Validator, resp, ResponseLogKey); err != nil {
+				return nil, err
+			}
+		}
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor is the streaming counterpart of UnaryServerInterceptor.
+// Every received message, and every sent message when opts.ValidateResponses is set, is validated.
+func StreamServerInterceptor(opts Options) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+		return handler(srv, &validatedServerStream{
+			WrappedServerStream: middleware.WrapServerStream(ss),
+			opts:                opts,
+		})
+	}
+}
+
+type validatedServerStream struct {
+	*middleware.WrappedServerStream
+	opts Options
+}
+
+func (s *validatedServerStream) RecvMsg(m any) error {
+	if err := s.WrappedServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return validate(s.Context(), s.opts.Validator, m, RequestLogKey)
+}
+
+func (s *validatedServerStream) SendMsg(m any) error {
+	if s.opts.ValidateResponses {
+		if err := validate(s.Context(), s.opts.Validator, m, ResponseLogKey); err != nil {
+			return err
+		}
+	}
+	return s.WrappedServerStream.SendMsg(m)
+}
+
+// validate validates m and adds its violations to the finished call log under logKey.
+func validate(ctx context.Context, validator protovalidate.Validator, m any, logKey string) error {
+	msg, ok := m.(proto.Message)
+	if !ok {
+		return status.Errorf(codes.Internal, "unsupported message type: %T", m)
+	}
+	err := validator.Validate(msg)
+	if err == nil {
+		return nil
+	}
+	var valErr *protovalidate.ValidationError
+	if !errors.As(err, &valErr) {
+		// The rules don't compile.
+		return status.Error(codes.Internal, err.Error())
+	}
+	logging.AddFields(ctx, logging.Fields{logKey, Violations(valErr)})
+	if logKey == ResponseLogKey {
+		return status.Error(codes.Internal, "invalid response: "+err.Error())
+	}
+	return BadRequest(valErr).Err()
+}
+
+// Violations returns the violations of err as logged.
+func Violations(err *protovalidate.ValidationError) []Violation {
+	violations := make([]Violation, 0, len(err.Violations))
+	for _, violation := range err.Violations {
+		violations = append(violations, Violation{
+			Field:   protovalidate.FieldPathString(violation.Proto.GetField()),
+			Rule:    violation.Proto.GetRuleId(),
+			Message: violation.Proto.GetMessage(),
+		})
+	}
+	return violations
+}
+
+// BadRequest returns an InvalidArgument status with the violations of err as google.rpc.BadRequest field violations.
+func BadRequest(err *protovalidate.ValidationError) *status.Status {
+	badRequest := &errdetails.BadRequest{}
+	for _, violation := range Violations(err) {
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       violation.Field,
+			Description: violation.Message,
+		})
+	}
+	st := status.New(codes.InvalidArgument, err.Error())
+	if detailed, detErr := st.WithDetails(badRequest); detErr == nil {
+		return detailed
+	}
+	return st
+}
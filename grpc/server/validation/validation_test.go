@@ -0,0 +1,118 @@
+package validation_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+
+	"buf.build/go/protovalidate"
+	"github.com/Azure/aks-middleware/grpc/interceptor"
+	"github.com/Azure/aks-middleware/grpc/server/validation"
+	pb "github.com/Azure/aks-middleware/test/api/v1"
+	"github.com/Azure/aks-middleware/test/server"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// failingValidator fails every validation with err.
+type failingValidator struct {
+	err error
+}
+
+func (v failingValidator) Validate(proto.Message, ...protovalidate.ValidationOption) error {
+	return v.err
+}
+
+var _ = Describe("Validation interceptor", func() {
+	var (
+		apiOutput *bytes.Buffer
+		options   interceptor.ServerInterceptorLogOptions
+	)
+
+	BeforeEach(func() {
+		apiOutput = &bytes.Buffer{}
+		options = interceptor.ServerInterceptorLogOptions{
+			Logger:    slog.New(slog.NewJSONHandler(io.Discard, nil)),
+			APIOutput: apiOutput,
+			CtxOutput: io.Discard,
+		}
+	})
+
+	newClient := func() pb.MyGreeterClient {
+		interceptors, err := interceptor.NewServerInterceptors(options)
+		Expect(err).ToNot(HaveOccurred())
+		grpcServer := grpc.NewServer(grpc.ChainUnaryInterceptor(interceptors...))
+		pb.RegisterMyGreeterServer(grpcServer, &server.TestServer{})
+		lis, err := net.Listen("tcp", "localhost:0")
+		Expect(err).ToNot(HaveOccurred())
+		go func() {
+			_ = grpcServer.Serve(lis)
+		}()
+		DeferCleanup(grpcServer.Stop)
+		clientConn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		Expect(err).ToNot(HaveOccurred())
+		DeferCleanup(clientConn.Close)
+		return pb.NewMyGreeterClient(clientConn)
+	}
+
+	It("should return BadRequest field violations and log them", func() {
+		client := newClient()
+		_, err := client.SayHello(context.Background(), &pb.HelloRequest{Name: "T", Age: 30, Email: "invalid"})
+		Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+
+		var fields []string
+		for _, detail := range status.Convert(err).Details() {
+			badRequest, ok := detail.(*errdetails.BadRequest)
+			Expect(ok).To(BeTrue())
+			for _, violation := range badRequest.GetFieldViolations() {
+				fields = append(fields, violation.GetField())
+			}
+		}
+		Expect(fields).To(ConsistOf("name", "email"))
+		Expect(apiOutput.String()).To(ContainSubstring(`"validation_errors":[{"field":"name","rule":"string.min_len"`))
+		Expect(apiOutput.String()).To(ContainSubstring(`"code":"InvalidArgument"`))
+	})
+
+	It("should use the injected validator", func() {
+		validator, err := protovalidate.New(protovalidate.WithMessages(&pb.HelloRequest{}))
+		Expect(err).ToNot(HaveOccurred())
+		options.Validator = validator
+		client := newClient()
+		_, err = client.SayHello(context.Background(), &pb.HelloRequest{Name: "Test", Age: 30, Email: "test@test.com"})
+		Expect(err).ToNot(HaveOccurred())
+
+		options.Validator = failingValidator{err: errors.New("rules don't compile")}
+		client = newClient()
+		_, err = client.SayHello(context.Background(), &pb.HelloRequest{Name: "Test", Age: 30, Email: "test@test.com"})
+		Expect(status.Code(err)).To(Equal(codes.Internal))
+	})
+
+	It("should fail invalid responses when ValidateResponses is set", func() {
+		validator, err := protovalidate.New()
+		Expect(err).ToNot(HaveOccurred())
+		valid := &pb.HelloRequest{Name: "Test", Age: 30, Email: "test@test.com"}
+		invalid := &pb.HelloRequest{Name: "T", Age: 30, Email: "test@test.com"}
+		handler := func(ctx context.Context, req any) (any, error) {
+			return invalid, nil
+		}
+
+		unary := validation.UnaryServerInterceptor(validation.Options{Validator: validator})
+		resp, err := unary(context.Background(), valid, &grpc.UnaryServerInfo{}, handler)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp).To(Equal(invalid))
+
+		unary = validation.UnaryServerInterceptor(validation.Options{Validator: validator, ValidateResponses: true})
+		_, err = unary(context.Background(), valid, &grpc.UnaryServerInfo{}, handler)
+		Expect(status.Code(err)).To(Equal(codes.Internal))
+		Expect(status.Convert(err).Message()).To(HavePrefix("invalid response"))
+	})
+})
@@ -5,23 +5,46 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/Azure/aks-middleware/http/common/deadline"
 	"github.com/Azure/aks-middleware/http/common/logging"
+	"github.com/Azure/aks-middleware/http/common/sampling"
 	armPolicy "github.com/Azure/azure-sdk-for-go/sdk/azcore/arm/policy"
 	azcorePolicy "github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 	"google.golang.org/grpc/codes"
 )
 
 type LoggingPolicy struct {
-	logger log.Logger
+	logger   log.Logger
+	sampling *sampling.Policy
+	budget   *deadline.Budget
+}
+
+// LoggingPolicyOptions are the optional settings of a LoggingPolicy.
+type LoggingPolicyOptions struct {
+	// Sampling samples the records of successful requests when set.
+	Sampling *sampling.Policy
+	// Budget forwards the remaining deadline of the request context minus a safety margin when set.
+	Budget *deadline.Budget
 }
 
 func NewLoggingPolicy(logger log.Logger) *LoggingPolicy {
 	return &LoggingPolicy{logger: logger}
 }
 
+// NewLoggingPolicyWithSampling is like NewLoggingPolicy, but the records of successful requests
+// are sampled with policy.
+func NewLoggingPolicyWithSampling(logger log.Logger, policy *sampling.Policy) *LoggingPolicy {
+	return NewLoggingPolicyWithOptions(logger, LoggingPolicyOptions{Sampling: policy})
+}
+
+// NewLoggingPolicyWithOptions is like NewLoggingPolicy with the settings of opts.
+func NewLoggingPolicyWithOptions(logger log.Logger, opts LoggingPolicyOptions) *LoggingPolicy {
+	return &LoggingPolicy{logger: logger, sampling: opts.Sampling, budget: opts.Budget}
+}
+
 func (p *LoggingPolicy) Do(req *azcorePolicy.Request) (*http.Response, error) {
 	startTime := time.Now()
-	resp, err := req.Next()
+	resp, err := p.next(req)
 
 	logging.LogRequest(logging.LogRequestParams{
 		Logger:    &p.logger,
@@ -29,12 +52,30 @@ func (p *LoggingPolicy) Do(req *azcorePolicy.Request) (*http.Response, error) {
 		Request:   req.Raw(),
 		Response:  resp,
 		Error:     err,
+		Sampling:  p.sampling,
 	})
 	return resp, err
 }
 
+func (p *LoggingPolicy) next(req *azcorePolicy.Request) (*http.Response, error) {
+	if p.budget == nil {
+		return req.Next()
+	}
+	ctx := req.Raw().Context()
+	outCtx, cancel, err := p.budget.Outbound(ctx)
+	if err != nil {
+		return nil, err
+	}
+	outReq := req.WithContext(outCtx)
+	deadline.SetBudgetHeader(outCtx, outReq.Raw().Header)
+	resp, err := outReq.Next()
+	deadline.RecordDependency(ctx, logging.GetMethodInfo(req.Raw().Method, logging.TrimURL(*req.Raw().URL)), err)
+	deadline.CancelOnClose(resp, cancel)
+	return resp, err
+}
+
 func (p *LoggingPolicy) Clone() azcorePolicy.Policy {
-	return &LoggingPolicy{logger: p.logger}
+	return &LoggingPolicy{logger: p.logger, sampling: p.sampling, budget: p.budget}
 }
 
 func GetDefaultArmClientOptions(logger *log.Logger) *armPolicy.ClientOptions {
@@ -5,10 +5,12 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"time"
 
 	log "log/slog"
 
 	serviceHubPolicy "github.com/Azure/aks-middleware/http/client/azuresdk/policy"
+	"github.com/Azure/aks-middleware/http/common/deadline"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
 	. "github.com/onsi/ginkgo/v2"
@@ -56,4 +58,32 @@ var _ = Describe("LoggingPolicy", func() {
 			Expect(buf.String()).To(ContainSubstring("\"time_ms\":"))
 		})
 	})
+
+	Context("when forwarding the deadline budget", func() {
+		It("sends the remaining budget minus the safety margin", func() {
+			var budget time.Duration
+			server.AppendHandlers(ghttp.CombineHandlers(
+				ghttp.VerifyRequest(http.MethodGet, "/"),
+				func(w http.ResponseWriter, r *http.Request) {
+					budget, _ = deadline.BudgetFromHeader(r.Header)
+				},
+				ghttp.RespondWith(http.StatusOK, "Hello, world!"),
+			))
+			clientOptions := new(policy.ClientOptions)
+			pipelineOptions := new(runtime.PipelineOptions)
+			clientOptions.PerCallPolicies = append(clientOptions.PerCallPolicies, serviceHubPolicy.NewLoggingPolicyWithOptions(*logger,
+				serviceHubPolicy.LoggingPolicyOptions{Budget: &deadline.Budget{SafetyMargin: 100 * time.Millisecond}}))
+			pipeline := runtime.NewPipeline("", "", *pipelineOptions, clientOptions)
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			req, err := runtime.NewRequest(ctx, http.MethodGet, server.URL())
+			Expect(err).NotTo(HaveOccurred())
+
+			resp, err := pipeline.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(budget).To(BeNumerically("~", 900*time.Millisecond, 50*time.Millisecond))
+			Expect(buf.String()).To(ContainSubstring("finished call"))
+		})
+	})
 })
@@ -5,32 +5,63 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/Azure/aks-middleware/http/common/deadline"
 	"github.com/Azure/aks-middleware/http/common/logging"
+	"github.com/Azure/aks-middleware/http/common/sampling"
 )
 
 type LoggingRoundTripper struct {
 	Proxied http.RoundTripper
 	Logger  *log.Logger
+	// Sampling samples the records of successful requests when set.
+	Sampling *sampling.Policy
+	// Budget forwards the remaining deadline of the request context minus a safety margin when set.
+	Budget *deadline.Budget
 }
 
 func (lrt *LoggingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 	start := time.Now()
-	resp, err := lrt.Proxied.RoundTrip(req)
+	resp, err := lrt.roundTrip(req)
 	logging.LogRequest(logging.LogRequestParams{
 		Logger:    lrt.Logger,
 		StartTime: start,
 		Request:   req,
 		Response:  resp,
 		Error:     err,
+		Sampling:  lrt.Sampling,
 	})
 	return resp, err
 }
 
+func (lrt *LoggingRoundTripper) roundTrip(req *http.Request) (*http.Response, error) {
+	if lrt.Budget == nil {
+		return lrt.Proxied.RoundTrip(req)
+	}
+	ctx, cancel, err := lrt.Budget.Outbound(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	// RoundTrippers must not modify the caller's request.
+	outReq := req.Clone(ctx)
+	deadline.SetBudgetHeader(ctx, outReq.Header)
+	resp, err := lrt.Proxied.RoundTrip(outReq)
+	deadline.RecordDependency(req.Context(), logging.GetMethodInfo(req.Method, logging.TrimURL(*req.URL)), err)
+	deadline.CancelOnClose(resp, cancel)
+	return resp, err
+}
+
 func NewLoggingClient(logger *log.Logger) *http.Client {
+	return NewLoggingClientWithSampling(logger, nil)
+}
+
+// NewLoggingClientWithSampling is like NewLoggingClient, but the records of successful requests
+// are sampled with policy.
+func NewLoggingClientWithSampling(logger *log.Logger, policy *sampling.Policy) *http.Client {
 	return &http.Client{
 		Transport: &LoggingRoundTripper{
-			Proxied: http.DefaultTransport,
-			Logger:  logger,
+			Proxied:  http.DefaultTransport,
+			Logger:   logger,
+			Sampling: policy,
 		},
 	}
 }
@@ -2,12 +2,17 @@ package restlogger_test
 
 import (
 	"bytes"
+	"context"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"time"
 
 	log "log/slog"
 
 	"github.com/Azure/aks-middleware/http/client/direct/restlogger"
+	"github.com/Azure/aks-middleware/http/common/deadline"
+	"github.com/Azure/aks-middleware/http/common/sampling"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 )
@@ -68,4 +73,69 @@ var _ = Describe("LoggingRoundTripper", func() {
 			Expect(logOutput).To(ContainSubstring("500 Internal Server Error"))
 		})
 	})
+
+	Context("when sampling successful requests", func() {
+		BeforeEach(func() {
+			client = restlogger.NewLoggingClientWithSampling(logger, &sampling.Policy{DefaultRate: 1e-12})
+		})
+
+		It("drops sampled out successful requests and logs errors with the sample rate", func() {
+			fakeServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodPost {
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+			}))
+			resp, err := client.Get(fakeServer.URL)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(logBuffer.String()).To(BeEmpty())
+
+			resp, err = client.Post(fakeServer.URL, "", nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusInternalServerError))
+			Expect(logBuffer.String()).To(ContainSubstring("finished call"))
+			Expect(logBuffer.String()).To(ContainSubstring("sample_rate=1"))
+		})
+	})
+
+	Context("when forwarding the deadline budget", func() {
+		BeforeEach(func() {
+			client = &http.Client{
+				Transport: &restlogger.LoggingRoundTripper{
+					Proxied: http.DefaultTransport,
+					Logger:  logger,
+					Budget:  &deadline.Budget{SafetyMargin: 100 * time.Millisecond},
+				},
+			}
+		})
+
+		It("sends the remaining budget minus the safety margin and keeps the body readable", func() {
+			var budget time.Duration
+			fakeServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				budget, _ = deadline.BudgetFromHeader(r.Header)
+				_, _ = w.Write([]byte("mock response"))
+			}))
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			req, _ := http.NewRequestWithContext(ctx, http.MethodGet, fakeServer.URL, nil)
+			resp, err := client.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+			body, err := io.ReadAll(resp.Body)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.Body.Close()).To(Succeed())
+			Expect(string(body)).To(Equal("mock response"))
+			Expect(budget).To(BeNumerically("~", 900*time.Millisecond, 50*time.Millisecond))
+			Expect(req.Header.Get(deadline.BudgetHeader)).To(BeEmpty())
+		})
+
+		It("fails requests made with an exhausted budget", func() {
+			fakeServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			req, _ := http.NewRequestWithContext(ctx, http.MethodGet, fakeServer.URL, nil)
+			_, err := client.Do(req)
+			Expect(err).To(MatchError(deadline.ErrBudgetExhausted))
+			Expect(logBuffer.String()).To(ContainSubstring("finished call"))
+		})
+	})
 })
@@ -0,0 +1,72 @@
+package armerror
+
+// This package writes errors in the ARM error response contract:
+// https://github.com/Azure/azure-resource-manager-rpc/blob/master/v1.0/common-api-details.md#error-response-content
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"google.golang.org/grpc/codes"
+)
+
+// Error is an ARM error, also used for the entries of Details.
+type Error struct {
+	Code           string           `json:"code"`
+	Message        string           `json:"message"`
+	Target         string           `json:"target,omitempty"`
+	Details        []Error          `json:"details,omitempty"`
+	AdditionalInfo []AdditionalInfo `json:"additionalInfo,omitempty"`
+}
+
+// AdditionalInfo is additional information about an Error, e.g. the request ID.
+type AdditionalInfo struct {
+	Type string `json:"type"`
+	Info any    `json:"info,omitempty"`
+}
+
+// Response is the body of an ARM error response.
+type Response struct {
+	Error Error `json:"error"`
+}
+
+func (e Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// Write writes e as the ARM error response body with statusCode.
+func Write(w http.ResponseWriter, statusCode int, e Error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(Response{Error: e})
+}
+
+// CodeFromGRPC returns the ARM error code of a gRPC status code.
+func CodeFromGRPC(code codes.Code) string {
+	switch code {
+	case codes.InvalidArgument, codes.OutOfRange:
+		return "InvalidParameter"
+	case codes.FailedPrecondition:
+		return "OperationNotAllowed"
+	case codes.NotFound:
+		return "NotFound"
+	case codes.AlreadyExists, codes.Aborted:
+		return "Conflict"
+	case codes.Unauthenticated:
+		return "AuthenticationFailed"
+	case codes.PermissionDenied:
+		return "AuthorizationFailed"
+	case codes.ResourceExhausted:
+		return "TooManyRequests"
+	case codes.Unimplemented:
+		return "NotImplemented"
+	case codes.Unavailable:
+		return "ServiceUnavailable"
+	case codes.DeadlineExceeded:
+		return "GatewayTimeout"
+	case codes.Canceled:
+		return "RequestCanceled"
+	default:
+		return "InternalServerError"
+	}
+}
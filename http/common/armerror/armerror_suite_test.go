@@ -0,0 +1,13 @@
+package armerror_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestArmError(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "ArmError Suite")
+}
@@ -0,0 +1,30 @@
+package armerror_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/Azure/aks-middleware/http/common/armerror"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Write", func() {
+	It("should write the ARM error contract", func() {
+		w := httptest.NewRecorder()
+		armerror.Write(w, http.StatusBadRequest, armerror.Error{
+			Code:    "InvalidParameter",
+			Message: "The value of name is invalid.",
+			Details: []armerror.Error{{Code: "InvalidParameter", Message: "too long", Target: "/properties/name"}},
+		})
+		Expect(w.Code).To(Equal(http.StatusBadRequest))
+		Expect(w.Header().Get("Content-Type")).To(Equal("application/json"))
+
+		var resp map[string]map[string]any
+		Expect(json.Unmarshal(w.Body.Bytes(), &resp)).To(Succeed())
+		Expect(resp["error"]["code"]).To(Equal("InvalidParameter"))
+		Expect(resp["error"]).ToNot(HaveKey("target"))
+		Expect(resp["error"]["details"]).To(ConsistOf(HaveKeyWithValue("target", "/properties/name")))
+	})
+})
@@ -0,0 +1,224 @@
+package auth
+
+// This package validates bearer tokens (JWTs) for the gRPC interceptors and the HTTP middleware.
+// The issuer, audience, expiry and signature of the token are checked, the signing keys coming from a JWKS.
+// The validated caller is put in the context as a Principal.
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Log keys of the principal.
+const (
+	ObjectIDLogKey = "oid"
+	TenantIDLogKey = "tid"
+	AppIDLogKey    = "appid"
+	UPNLogKey      = "upn"
+)
+
+var (
+	// ErrMissingToken is returned when the request has no bearer token.
+	ErrMissingToken = errors.New("missing bearer token")
+	// ErrInvalidToken wraps the validation errors of a token.
+	ErrInvalidToken = errors.New("invalid bearer token")
+)
+
+// Principal is the caller identified by a validated token.
+type Principal struct {
+	// ObjectID is the oid claim.
+	ObjectID string
+	// TenantID is the tid claim.
+	TenantID string
+	// AppID is the appid claim, or the azp claim of v2 tokens.
+	AppID string
+	// UPN is the upn claim, or the preferred_username claim of v2 tokens.
+	UPN string
+	// Subject is the sub claim.
+	Subject string
+	// Roles is the roles claim.
+	Roles []string
+	// Claims are all the claims of the token.
+	Claims map[string]any
+}
+
+// LogAttrs returns the principal as logger attributes, skipping empty values.
+func (p *Principal) LogAttrs() []any {
+	var attrs []any
+	for _, attr := range []struct {
+		key, value string
+	}{
+		{ObjectIDLogKey, p.ObjectID},
+		{TenantIDLogKey, p.TenantID},
+		{AppIDLogKey, p.AppID},
+		{UPNLogKey, p.UPN},
+	} {
+		if attr.value != "" {
+			attrs = append(attrs, attr.key, attr.value)
+		}
+	}
+	return attrs
+}
+
+type principalKeyType int
+
+const (
+	principalKey principalKeyType = iota
+	principalHolderKey
+)
+
+// principalHolder makes the principal available to the middlewares registered before the authentication one.
+type principalHolder struct {
+	mu        sync.Mutex
+	principal *Principal
+}
+
+// WithPrincipalHolder returns ctx with a holder receiving the principal of WithPrincipal calls made with the
+// contexts derived from it. It lets a middleware registered before the authentication middleware, e.g. otelaudit,
+// read the principal with PrincipalFromContext once the request is served.
+func WithPrincipalHolder(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(principalHolderKey).(*principalHolder); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, principalHolderKey, &principalHolder{})
+}
+
+// WithPrincipal returns ctx with p.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	if h, ok := ctx.Value(principalHolderKey).(*principalHolder); ok {
+		h.mu.Lock()
+		h.principal = p
+		h.mu.Unlock()
+	}
+	return context.WithValue(ctx, principalKey, p)
+}
+
+// PrincipalFromContext returns the principal of ctx, or the one received by its holder, if any.
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	if p, ok := ctx.Value(principalKey).(*Principal); ok {
+		return p, true
+	}
+	if h, ok := ctx.Value(principalHolderKey).(*principalHolder); ok {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		return h.principal, h.principal != nil
+	}
+	return nil, false
+}
+
+// KeySource returns the public key of a key id. JWKS is a KeySource.
+type KeySource interface {
+	Key(ctx context.Context, kid string) (crypto.PublicKey, error)
+}
+
+// Options configure a Validator.
+type Options struct {
+	// Issuers are the accepted iss claims. Required.
+	Issuers []string
+	// Audiences are the accepted aud claims, a token must have one of them. Required.
+	Audiences []string
+	// Keys are the signing keys. Required.
+	Keys KeySource
+	// Leeway is the clock skew allowed when checking exp and nbf.
+	Leeway time.Duration
+}
+
+// Validator validates bearer tokens.
+type Validator struct {
+	opts   Options
+	parser *jwt.Parser
+}
+
+// NewValidator returns a Validator with opts.
+func NewValidator(opts Options) (*Validator, error) {
+	if len(opts.Issuers) == 0 {
+		return nil, errors.New("auth: no issuer")
+	}
+	if len(opts.Audiences) == 0 {
+		return nil, errors.New("auth: no audience")
+	}
+	if opts.Keys == nil {
+		return nil, errors.New("auth: no keys")
+	}
+	return &Validator{
+		opts: opts,
+		parser: jwt.NewParser(
+			jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+			jwt.WithAudience(opts.Audiences...),
+			jwt.WithExpirationRequired(),
+			jwt.WithLeeway(opts.Leeway),
+		),
+	}, nil
+}
+
+// claims are the claims of the tokens read by the Validator.
+type claims struct {
+	jwt.RegisteredClaims
+	ObjectID          string   `json:"oid"`
+	TenantID          string   `json:"tid"`
+	AppID             string   `json:"appid"`
+	AuthorizedParty   string   `json:"azp"`
+	UPN               string   `json:"upn"`
+	PreferredUsername string   `json:"preferred_username"`
+	Roles             []string `json:"roles"`
+
+	raw map[string]any
+}
+
+// UnmarshalJSON also keeps all the claims in raw.
+func (c *claims) UnmarshalJSON(data []byte) error {
+	type plain claims
+	if err := json.Unmarshal(data, (*plain)(c)); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &c.raw)
+}
+
+// Validate validates token and returns its principal. The errors wrap ErrInvalidToken.
+func (v *Validator) Validate(ctx context.Context, token string) (*Principal, error) {
+	c := &claims{}
+	_, err := v.parser.ParseWithClaims(token, c, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return v.opts.Keys.Key(ctx, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	if !slices.Contains(v.opts.Issuers, c.Issuer) {
+		return nil, fmt.Errorf("%w: issuer %q is not accepted", ErrInvalidToken, c.Issuer)
+	}
+	p := &Principal{
+		ObjectID: c.ObjectID,
+		TenantID: c.TenantID,
+		AppID:    c.AppID,
+		UPN:      c.UPN,
+		Subject:  c.Subject,
+		Roles:    c.Roles,
+		Claims:   c.raw,
+	}
+	if p.AppID == "" {
+		p.AppID = c.AuthorizedParty
+	}
+	if p.UPN == "" {
+		p.UPN = c.PreferredUsername
+	}
+	return p, nil
+}
+
+// BearerToken returns the token of an Authorization header value, e.g. "Bearer <token>".
+func BearerToken(authorization string) (string, error) {
+	scheme, token, found := strings.Cut(strings.TrimSpace(authorization), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return "", ErrMissingToken
+	}
+	return strings.TrimSpace(token), nil
+}
@@ -0,0 +1,271 @@
+package auth_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/aks-middleware/http/common/auth"
+	testauth "github.com/Azure/aks-middleware/test/auth"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Validator", func() {
+	var (
+		rsaKey    *testauth.Key
+		ecKey     *testauth.Key
+		jwksPath  string
+		validator *auth.Validator
+	)
+
+	writeJWKS := func(keys ...*testauth.Key) {
+		Expect(os.WriteFile(jwksPath, testauth.JWKS(keys...), 0o600)).To(Succeed())
+	}
+
+	newValidator := func(keys auth.KeySource) *auth.Validator {
+		v, err := auth.NewValidator(auth.Options{
+			Issuers:   []string{testauth.Issuer},
+			Audiences: []string{testauth.Audience},
+			Keys:      keys,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		return v
+	}
+
+	BeforeEach(func() {
+		rsaKey = testauth.NewRSAKey("rsa-key")
+		ecKey = testauth.NewECKey("ec-key")
+		jwksPath = filepath.Join(GinkgoT().TempDir(), "jwks.json")
+		writeJWKS(rsaKey, ecKey)
+		validator = newValidator(auth.NewJWKSFromFile(jwksPath, auth.JWKSOptions{}))
+	})
+
+	It("should return the principal of valid tokens", func() {
+		for _, key := range []*testauth.Key{rsaKey, ecKey} {
+			claims := testauth.Claims()
+			claims["roles"] = []string{"Reader"}
+			claims["groups"] = []string{"group-1"}
+			principal, err := validator.Validate(context.Background(), key.Sign(claims))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(principal.ObjectID).To(Equal("test-oid"))
+			Expect(principal.TenantID).To(Equal("test-tid"))
+			Expect(principal.AppID).To(Equal("test-appid"))
+			Expect(principal.UPN).To(Equal("user@test.com"))
+			Expect(principal.Subject).To(Equal("test-subject"))
+			Expect(principal.Roles).To(Equal([]string{"Reader"}))
+			Expect(principal.Claims).To(HaveKeyWithValue("groups", []any{"group-1"}))
+			Expect(principal.Claims).To(HaveKeyWithValue("oid", "test-oid"))
+		}
+	})
+
+	It("should read the v2 azp and preferred_username claims", func() {
+		claims := testauth.Claims()
+		delete(claims, "appid")
+		delete(claims, "upn")
+		claims["azp"] = "test-azp"
+		claims["preferred_username"] = "preferred@test.com"
+		principal, err := validator.Validate(context.Background(), rsaKey.Sign(claims))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(principal.AppID).To(Equal("test-azp"))
+		Expect(principal.UPN).To(Equal("preferred@test.com"))
+		Expect(principal.LogAttrs()).To(Equal([]any{
+			"oid", "test-oid", "tid", "test-tid", "appid", "test-azp", "upn", "preferred@test.com",
+		}))
+	})
+
+	DescribeTable("should reject invalid tokens",
+		func(mutate func(claims map[string]any)) {
+			claims := testauth.Claims()
+			mutate(claims)
+			_, err := validator.Validate(context.Background(), rsaKey.Sign(claims))
+			Expect(err).To(MatchError(auth.ErrInvalidToken))
+		},
+		Entry("wrong issuer", func(claims map[string]any) { claims["iss"] = "https://evil.com/" }),
+		Entry("wrong audience", func(claims map[string]any) { claims["aud"] = "https://other.com/" }),
+		Entry("expired", func(claims map[string]any) { claims["exp"] = time.Now().Add(-time.Minute).Unix() }),
+		Entry("no expiry", func(claims map[string]any) { delete(claims, "exp") }),
+		Entry("not yet valid", func(claims map[string]any) { claims["nbf"] = time.Now().Add(time.Hour).Unix() }),
+	)
+
+	It("should reject tokens signed with an unknown key", func() {
+		_, err := validator.Validate(context.Background(), testauth.NewRSAKey("rsa-key").Sign(testauth.Claims()))
+		Expect(err).To(MatchError(auth.ErrInvalidToken))
+
+		_, err = validator.Validate(context.Background(), testauth.NewRSAKey("other-key").Sign(testauth.Claims()))
+		Expect(err).To(MatchError(auth.ErrInvalidToken))
+		Expect(err).To(MatchError(auth.ErrUnknownKey))
+	})
+
+	It("should reject malformed tokens", func() {
+		_, err := validator.Validate(context.Background(), "not-a-token")
+		Expect(err).To(MatchError(auth.ErrInvalidToken))
+	})
+
+	It("should accept expired tokens within the leeway", func() {
+		v, err := auth.NewValidator(auth.Options{
+			Issuers:   []string{testauth.Issuer},
+			Audiences: []string{testauth.Audience},
+			Keys:      auth.NewJWKSFromFile(jwksPath, auth.JWKSOptions{}),
+			Leeway:    5 * time.Minute,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		claims := testauth.Claims()
+		claims["exp"] = time.Now().Add(-time.Minute).Unix()
+		_, err = v.Validate(context.Background(), rsaKey.Sign(claims))
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("should require the issuers, audiences and keys", func() {
+		_, err := auth.NewValidator(auth.Options{Audiences: []string{testauth.Audience}, Keys: auth.NewJWKSFromFile(jwksPath, auth.JWKSOptions{})})
+		Expect(err).To(HaveOccurred())
+		_, err = auth.NewValidator(auth.Options{Issuers: []string{testauth.Issuer}, Keys: auth.NewJWKSFromFile(jwksPath, auth.JWKSOptions{})})
+		Expect(err).To(HaveOccurred())
+		_, err = auth.NewValidator(auth.Options{Issuers: []string{testauth.Issuer}, Audiences: []string{testauth.Audience}})
+		Expect(err).To(HaveOccurred())
+	})
+
+	Context("key rotation", func() {
+		It("should pick up the keys added to the file", func() {
+			validator = newValidator(auth.NewJWKSFromFile(jwksPath, auth.JWKSOptions{MinRefreshInterval: time.Nanosecond}))
+			_, err := validator.Validate(context.Background(), rsaKey.Sign(testauth.Claims()))
+			Expect(err).ToNot(HaveOccurred())
+
+			rotated := testauth.NewRSAKey("rotated-key")
+			writeJWKS(rotated)
+			_, err = validator.Validate(context.Background(), rotated.Sign(testauth.Claims()))
+			Expect(err).ToNot(HaveOccurred())
+			_, err = validator.Validate(context.Background(), rsaKey.Sign(testauth.Claims()))
+			Expect(err).To(MatchError(auth.ErrUnknownKey))
+		})
+
+		It("should not reload for unknown keys before MinRefreshInterval", func() {
+			_, err := validator.Validate(context.Background(), rsaKey.Sign(testauth.Claims()))
+			Expect(err).ToNot(HaveOccurred())
+
+			rotated := testauth.NewRSAKey("rotated-key")
+			writeJWKS(rotated)
+			_, err = validator.Validate(context.Background(), rotated.Sign(testauth.Claims()))
+			Expect(err).To(MatchError(auth.ErrUnknownKey))
+		})
+
+		It("should keep the previous keys when a reload fails", func() {
+			validator = newValidator(auth.NewJWKSFromFile(jwksPath, auth.JWKSOptions{RefreshInterval: time.Nanosecond}))
+			_, err := validator.Validate(context.Background(), rsaKey.Sign(testauth.Claims()))
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(os.Remove(jwksPath)).To(Succeed())
+			_, err = validator.Validate(context.Background(), rsaKey.Sign(testauth.Claims()))
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("should fetch the rotated keys from the URL", func() {
+			var jwks atomic.Value
+			jwks.Store(testauth.JWKS(rsaKey))
+			var fetches atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fetches.Add(1)
+				_, _ = w.Write(jwks.Load().([]byte))
+			}))
+			DeferCleanup(server.Close)
+			validator = newValidator(auth.NewJWKSFromURL(server.URL, auth.JWKSOptions{MinRefreshInterval: time.Nanosecond}))
+
+			_, err := validator.Validate(context.Background(), rsaKey.Sign(testauth.Claims()))
+			Expect(err).ToNot(HaveOccurred())
+			_, err = validator.Validate(context.Background(), rsaKey.Sign(testauth.Claims()))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fetches.Load()).To(BeEquivalentTo(1))
+
+			rotated := testauth.NewECKey("rotated-key")
+			jwks.Store(testauth.JWKS(rsaKey, rotated))
+			_, err = validator.Validate(context.Background(), rotated.Sign(testauth.Claims()))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fetches.Load()).To(BeEquivalentTo(2))
+		})
+
+		It("should fail when the URL can't be fetched", func() {
+			server := httptest.NewServer(http.NotFoundHandler())
+			DeferCleanup(server.Close)
+			validator = newValidator(auth.NewJWKSFromURL(server.URL, auth.JWKSOptions{}))
+			_, err := validator.Validate(context.Background(), rsaKey.Sign(testauth.Claims()))
+			Expect(err).To(MatchError(auth.ErrInvalidToken))
+			Expect(err.Error()).To(ContainSubstring("404"))
+		})
+	})
+})
+
+var _ = Describe("JWKS", func() {
+	It("should return the single key for an empty key id", func() {
+		key := testauth.NewECKey("ec-key")
+		keys, err := auth.ParseJWKS(testauth.JWKS(key))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(keys).To(HaveKey("ec-key"))
+
+		path := filepath.Join(GinkgoT().TempDir(), "jwks.json")
+		Expect(os.WriteFile(path, testauth.JWKS(key), 0o600)).To(Succeed())
+		_, err = auth.NewJWKSFromFile(path, auth.JWKSOptions{}).Key(context.Background(), "")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("should skip encryption and unsupported keys", func() {
+		keys, err := auth.ParseJWKS([]byte(`{"keys":[{"kty":"RSA","kid":"enc","use":"enc"},{"kty":"oct","kid":"sym"}]}`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(keys).To(BeEmpty())
+	})
+
+	It("should fail on invalid key sets", func() {
+		_, err := auth.ParseJWKS([]byte(`not json`))
+		Expect(err).To(HaveOccurred())
+		_, err = auth.ParseJWKS([]byte(`{"keys":[{"kty":"EC","kid":"ec","crv":"P-192"}]}`))
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("BearerToken", func() {
+	DescribeTable("should parse the Authorization header",
+		func(authorization, token string, valid bool) {
+			got, err := auth.BearerToken(authorization)
+			if !valid {
+				Expect(err).To(MatchError(auth.ErrMissingToken))
+				return
+			}
+			Expect(err).ToNot(HaveOccurred())
+			Expect(got).To(Equal(token))
+		},
+		Entry("bearer", "Bearer abc", "abc", true),
+		Entry("lower case scheme", "bearer abc", "abc", true),
+		Entry("empty", "", "", false),
+		Entry("basic", "Basic abc", "", false),
+		Entry("no token", "Bearer ", "", false),
+	)
+})
+
+var _ = Describe("Principal context", func() {
+	It("should return the principal set in the context", func() {
+		_, ok := auth.PrincipalFromContext(context.Background())
+		Expect(ok).To(BeFalse())
+
+		principal := &auth.Principal{ObjectID: "oid"}
+		got, ok := auth.PrincipalFromContext(auth.WithPrincipal(context.Background(), principal))
+		Expect(ok).To(BeTrue())
+		Expect(got).To(Equal(principal))
+	})
+
+	It("should return the principal set downstream through the holder", func() {
+		ctx := auth.WithPrincipalHolder(context.Background())
+		_, ok := auth.PrincipalFromContext(ctx)
+		Expect(ok).To(BeFalse())
+
+		principal := &auth.Principal{ObjectID: "oid"}
+		type key struct{}
+		downstream := context.WithValue(ctx, key{}, "value")
+		_ = auth.WithPrincipal(downstream, principal)
+		got, ok := auth.PrincipalFromContext(ctx)
+		Expect(ok).To(BeTrue())
+		Expect(got).To(Equal(principal))
+	})
+})
@@ -0,0 +1,212 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrUnknownKey is returned for key ids missing from the JWKS.
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// JWKSOptions configure a JWKS. Zero values use the defaults.
+type JWKSOptions struct {
+	// RefreshInterval is how often the key set is reloaded to pick up rotated keys. Defaults to 1h.
+	RefreshInterval time.Duration
+	// MinRefreshInterval is the minimum time between two reloads triggered by an unknown key id. Defaults to 5m.
+	MinRefreshInterval time.Duration
+	// Client fetches the key set of NewJWKSFromURL. Defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// JWKS is a JSON Web Key Set. The keys are loaded on first use, reloaded every RefreshInterval and when a
+// token is signed with an unknown key, so that rotated keys are picked up. The previous keys are kept when
+// a reload fails.
+type JWKS struct {
+	load func(ctx context.Context) ([]byte, error)
+	opts JWKSOptions
+	now  func() time.Time
+
+	mu       sync.Mutex
+	keys     map[string]crypto.PublicKey
+	loadedAt time.Time
+}
+
+// NewJWKSFromFile returns the JWKS of the file at path.
+func NewJWKSFromFile(path string, opts JWKSOptions) *JWKS {
+	return newJWKS(func(context.Context) ([]byte, error) {
+		return os.ReadFile(path)
+	}, opts)
+}
+
+// NewJWKSFromURL returns the JWKS served at url, e.g. the jwks_uri of an OpenID provider.
+func NewJWKSFromURL(url string, opts JWKSOptions) *JWKS {
+	client := opts.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return newJWKS(func(ctx context.Context) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("fetching %s: %s", url, resp.Status)
+		}
+		return io.ReadAll(resp.Body)
+	}, opts)
+}
+
+func newJWKS(load func(ctx context.Context) ([]byte, error), opts JWKSOptions) *JWKS {
+	if opts.RefreshInterval <= 0 {
+		opts.RefreshInterval = time.Hour
+	}
+	if opts.MinRefreshInterval <= 0 {
+		opts.MinRefreshInterval = 5 * time.Minute
+	}
+	return &JWKS{
+		load: load,
+		opts: opts,
+		now:  time.Now,
+	}
+}
+
+// Key returns the key of kid. When kid is empty and the set has a single key, that key is returned.
+func (k *JWKS) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	sinceLoad := k.now().Sub(k.loadedAt)
+	_, known := k.keys[kid]
+	if k.keys == nil || sinceLoad >= k.opts.RefreshInterval || (!known && sinceLoad >= k.opts.MinRefreshInterval) {
+		if err := k.reload(ctx); err != nil && k.keys == nil {
+			return nil, err
+		}
+	}
+	if key, ok := k.keys[kid]; ok {
+		return key, nil
+	}
+	if kid == "" && len(k.keys) == 1 {
+		for _, key := range k.keys {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnknownKey, kid)
+}
+
+// reload must be called with k.mu held.
+func (k *JWKS) reload(ctx context.Context) error {
+	// Failed reloads also count, so that an unavailable key set isn't fetched for every token.
+	k.loadedAt = k.now()
+	data, err := k.load(ctx)
+	if err != nil {
+		return fmt.Errorf("loading JWKS: %w", err)
+	}
+	keys, err := ParseJWKS(data)
+	if err != nil {
+		return err
+	}
+	k.keys = keys
+	return nil
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// ParseJWKS returns the RSA and EC signing keys of a JSON Web Key Set by key id.
+func ParseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("parsing JWKS: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, key := range set.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		publicKey, err := key.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("parsing JWKS key %q: %w", key.Kid, err)
+		}
+		if publicKey != nil {
+			keys[key.Kid] = publicKey
+		}
+	}
+	return keys, nil
+}
+
+// publicKey returns the key, or nil for unsupported key types.
+func (key jwk) publicKey() (crypto.PublicKey, error) {
+	switch key.Kty {
+	case "RSA":
+		n, err := decodeBigInt(key.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(key.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() {
+			return nil, errors.New("invalid exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch key.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", key.Crv)
+		}
+		x, err := decodeBigInt(key.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(key.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, nil
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
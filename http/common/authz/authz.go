@@ -0,0 +1,195 @@
+package authz
+
+// This package evaluates declarative authorization policies for the gRPC interceptors and the HTTP middleware.
+// A policy maps gRPC full methods and mux route names to rules on the principal of the http/common/auth
+// Validator: allowed app ids, tenant ids, roles or claims.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/Azure/aks-middleware/http/common/auth"
+)
+
+const (
+	// AnyMethod is the Policy.Methods key of the rules of the methods without rules of their own.
+	AnyMethod = "*"
+	// ImplicitDenyRule is the Decision.Rule of the calls matching no rule.
+	ImplicitDenyRule = "implicit-deny"
+	// DeniedLogMessage is the CtxLog message of the deny decisions.
+	DeniedLogMessage = "authorization denied"
+	// RuleLogKey is the log key of the rule of a decision.
+	RuleLogKey = "authz_rule"
+)
+
+// Effect is the decision of a matching rule.
+type Effect string
+
+const (
+	Allow Effect = "Allow"
+	Deny  Effect = "Deny"
+)
+
+// Rule matches the principals meeting all of its conditions, empty conditions match every principal.
+type Rule struct {
+	// Name identifies the rule in the logs and audit events.
+	Name string `json:"name"`
+	// Effect is the decision of the matching calls. Defaults to Allow.
+	Effect Effect `json:"effect,omitempty"`
+	// AppIDs are the accepted app ids.
+	AppIDs []string `json:"appIds,omitempty"`
+	// TenantIDs are the accepted tenant ids.
+	TenantIDs []string `json:"tenantIds,omitempty"`
+	// Roles are the accepted roles, the principal must have one of them.
+	Roles []string `json:"roles,omitempty"`
+	// Claims are the accepted values by claim name. String and string array claims are supported.
+	Claims map[string][]string `json:"claims,omitempty"`
+}
+
+// Matches returns whether p meets the conditions of r. A nil principal only matches rules without conditions.
+func (r *Rule) Matches(p *auth.Principal) bool {
+	if p == nil {
+		p = &auth.Principal{}
+	}
+	if len(r.AppIDs) > 0 && !slices.Contains(r.AppIDs, p.AppID) {
+		return false
+	}
+	if len(r.TenantIDs) > 0 && !slices.Contains(r.TenantIDs, p.TenantID) {
+		return false
+	}
+	if len(r.Roles) > 0 && !slices.ContainsFunc(p.Roles, func(role string) bool {
+		return slices.Contains(r.Roles, role)
+	}) {
+		return false
+	}
+	for name, accepted := range r.Claims {
+		if !slices.ContainsFunc(claimValues(p.Claims[name]), func(v string) bool {
+			return slices.Contains(accepted, v)
+		}) {
+			return false
+		}
+	}
+	return true
+}
+
+func claimValues(claim any) []string {
+	switch v := claim.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+// Policy holds the rules by gRPC full method (e.g. "/package.Service/Method") or mux route name.
+// The rules of a method are evaluated in order and the first matching rule decides. The calls
+// matching no rule are denied.
+type Policy struct {
+	Methods map[string][]Rule `json:"methods"`
+}
+
+// ParsePolicy parses a JSON policy, e.g.
+//
+//	{"methods": {"/package.Service/Method": [{"name": "admins", "roles": ["Admin"]}]}}
+func ParsePolicy(data []byte) (*Policy, error) {
+	p := &Policy{}
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("parsing authorization policy: %w", err)
+	}
+	for method, rules := range p.Methods {
+		for _, rule := range rules {
+			if rule.Effect != "" && rule.Effect != Allow && rule.Effect != Deny {
+				return nil, fmt.Errorf("parsing authorization policy: rule %q of %q has invalid effect %q", rule.Name, method, rule.Effect)
+			}
+		}
+	}
+	return p, nil
+}
+
+// LoadPolicy parses the JSON policy of the file at path.
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePolicy(data)
+}
+
+// Decision is the result of an authorization.
+type Decision struct {
+	Allowed bool
+	Method  string
+	// Rule is the name of the matching rule, or ImplicitDenyRule.
+	Rule string
+}
+
+// LogAttrs returns the decision as logger attributes.
+func (d Decision) LogAttrs() []any {
+	return []any{"method", d.Method, RuleLogKey, d.Rule}
+}
+
+// Authorize evaluates the rules of method for the principal of ctx, see auth.PrincipalFromContext.
+// The decision is also given to the holder of ctx, if any.
+func (p *Policy) Authorize(ctx context.Context, method string) Decision {
+	principal, _ := auth.PrincipalFromContext(ctx)
+	rules, ok := p.Methods[method]
+	if !ok {
+		rules = p.Methods[AnyMethod]
+	}
+	d := Decision{Method: method, Rule: ImplicitDenyRule}
+	for _, rule := range rules {
+		if rule.Matches(principal) {
+			d.Allowed = rule.Effect != Deny
+			d.Rule = rule.Name
+			break
+		}
+	}
+	if h, ok := ctx.Value(decisionHolderKey{}).(*decisionHolder); ok {
+		h.mu.Lock()
+		h.decision = &d
+		h.mu.Unlock()
+	}
+	return d
+}
+
+type decisionHolderKey struct{}
+
+type decisionHolder struct {
+	mu       sync.Mutex
+	decision *Decision
+}
+
+// WithDecisionHolder returns ctx with a holder receiving the decisions of the Authorize calls made with the
+// contexts derived from it, see auth.WithPrincipalHolder.
+func WithDecisionHolder(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(decisionHolderKey{}).(*decisionHolder); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, decisionHolderKey{}, &decisionHolder{})
+}
+
+// DecisionFromContext returns the decision received by the holder of ctx, if any.
+func DecisionFromContext(ctx context.Context) (Decision, bool) {
+	h, ok := ctx.Value(decisionHolderKey{}).(*decisionHolder)
+	if !ok {
+		return Decision{}, false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.decision == nil {
+		return Decision{}, false
+	}
+	return *h.decision, true
+}
@@ -0,0 +1,100 @@
+package authz_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/Azure/aks-middleware/http/common/auth"
+	"github.com/Azure/aks-middleware/http/common/authz"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const policyJSON = `{
+	"methods": {
+		"/MyGreeter/SayHello": [
+			{"name": "blocked-tenant", "effect": "Deny", "tenantIds": ["blocked-tid"]},
+			{"name": "readers", "roles": ["Reader", "Admin"]},
+			{"name": "first-party", "appIds": ["fp-appid"], "tenantIds": ["fp-tid"]},
+			{"name": "group", "claims": {"groups": ["group-1"]}}
+		],
+		"/MyGreeter/Health": [
+			{"name": "anyone"}
+		],
+		"*": [
+			{"name": "admins", "roles": ["Admin"]}
+		]
+	}
+}`
+
+var _ = Describe("Policy", func() {
+	var policy *authz.Policy
+
+	BeforeEach(func() {
+		var err error
+		policy, err = authz.ParsePolicy([]byte(policyJSON))
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	authorize := func(method string, principal *auth.Principal) authz.Decision {
+		ctx := context.Background()
+		if principal != nil {
+			ctx = auth.WithPrincipal(ctx, principal)
+		}
+		return policy.Authorize(ctx, method)
+	}
+
+	DescribeTable("should decide with the first matching rule",
+		func(method string, principal *auth.Principal, allowed bool, rule string) {
+			Expect(authorize(method, principal)).To(Equal(authz.Decision{Allowed: allowed, Method: method, Rule: rule}))
+		},
+		Entry("role", "/MyGreeter/SayHello", &auth.Principal{Roles: []string{"Writer", "Reader"}}, true, "readers"),
+		Entry("deny rule first", "/MyGreeter/SayHello", &auth.Principal{TenantID: "blocked-tid", Roles: []string{"Admin"}}, false, "blocked-tenant"),
+		Entry("app and tenant", "/MyGreeter/SayHello", &auth.Principal{AppID: "fp-appid", TenantID: "fp-tid"}, true, "first-party"),
+		Entry("app in another tenant", "/MyGreeter/SayHello", &auth.Principal{AppID: "fp-appid", TenantID: "other-tid"}, false, authz.ImplicitDenyRule),
+		Entry("string array claim", "/MyGreeter/SayHello", &auth.Principal{Claims: map[string]any{"groups": []any{"group-0", "group-1"}}}, true, "group"),
+		Entry("string claim", "/MyGreeter/SayHello", &auth.Principal{Claims: map[string]any{"groups": "group-1"}}, true, "group"),
+		Entry("other claim value", "/MyGreeter/SayHello", &auth.Principal{Claims: map[string]any{"groups": []any{"group-2"}}}, false, authz.ImplicitDenyRule),
+		Entry("no principal", "/MyGreeter/SayHello", nil, false, authz.ImplicitDenyRule),
+		Entry("rule without conditions", "/MyGreeter/Health", nil, true, "anyone"),
+		Entry("any method", "/MyGreeter/Other", &auth.Principal{Roles: []string{"Admin"}}, true, "admins"),
+		Entry("any method denied", "/MyGreeter/Other", &auth.Principal{Roles: []string{"Reader"}}, false, authz.ImplicitDenyRule),
+	)
+
+	It("should deny every call without rules", func() {
+		policy = &authz.Policy{}
+		Expect(authorize("/MyGreeter/SayHello", &auth.Principal{Roles: []string{"Admin"}}).Allowed).To(BeFalse())
+	})
+
+	It("should give the decision to the holder", func() {
+		ctx := authz.WithDecisionHolder(context.Background())
+		_, ok := authz.DecisionFromContext(ctx)
+		Expect(ok).To(BeFalse())
+
+		policy.Authorize(auth.WithPrincipal(ctx, &auth.Principal{TenantID: "blocked-tid"}), "/MyGreeter/SayHello")
+		decision, ok := authz.DecisionFromContext(ctx)
+		Expect(ok).To(BeTrue())
+		Expect(decision.Allowed).To(BeFalse())
+		Expect(decision.Rule).To(Equal("blocked-tenant"))
+		Expect(decision.LogAttrs()).To(Equal([]any{"method", "/MyGreeter/SayHello", authz.RuleLogKey, "blocked-tenant"}))
+	})
+
+	It("should load the policy from a file", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "policy.json")
+		Expect(os.WriteFile(path, []byte(policyJSON), 0o600)).To(Succeed())
+		loaded, err := authz.LoadPolicy(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(loaded).To(Equal(policy))
+
+		_, err = authz.LoadPolicy(filepath.Join(GinkgoT().TempDir(), "missing.json"))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should reject invalid policies", func() {
+		_, err := authz.ParsePolicy([]byte(`{"methods": {"*": [{"name": "typo", "effect": "Alow"}]}}`))
+		Expect(err).To(MatchError(ContainSubstring(`invalid effect "Alow"`)))
+		_, err = authz.ParsePolicy([]byte(`not json`))
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,164 @@
+package bulkhead
+
+// This package isolates classes of operations, e.g. reads and writes, in separate concurrency pools so that
+// a burst of slow operations of one class can't starve the others. Each pool admits MaxConcurrent operations
+// and queues up to MaxQueue more for at most MaxWait.
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/aks-middleware/http/common/logging"
+)
+
+// Log attributes of the request log.
+const (
+	ClassLogKey  = "bulkhead"
+	QueuedLogKey = "queued_ms"
+)
+
+// Operation classes of OperationClass.
+const (
+	ClassRead   = "read"
+	ClassList   = "list"
+	ClassWrite  = "write"
+	ClassDelete = "delete"
+)
+
+var (
+	// ErrQueueFull is returned when the pool and its queue are full.
+	ErrQueueFull = errors.New("bulkhead queue full")
+	// ErrQueueTimeout is returned when an operation waited MaxWait in the queue.
+	ErrQueueTimeout = errors.New("bulkhead queue timeout")
+)
+
+// PoolConfig is the size of a pool.
+type PoolConfig struct {
+	// MaxConcurrent is the number of operations running at once. Defaults to 10.
+	MaxConcurrent int
+	// MaxQueue is the number of operations waiting for a slot. 0 rejects operations when the pool is full.
+	MaxQueue int
+	// MaxWait is the longest an operation waits in the queue. 0 waits until the context is done.
+	MaxWait time.Duration
+}
+
+// Bulkhead holds a pool per class.
+type Bulkhead struct {
+	configs       map[string]PoolConfig
+	defaultConfig PoolConfig
+
+	mu    sync.Mutex
+	pools map[string]*pool
+}
+
+// New returns a Bulkhead with the pools of configs. Classes without a config get their own pool
+// of defaultConfig.
+func New(configs map[string]PoolConfig, defaultConfig PoolConfig) *Bulkhead {
+	return &Bulkhead{
+		configs:       configs,
+		defaultConfig: defaultConfig,
+		pools:         map[string]*pool{},
+	}
+}
+
+// Acquire admits an operation of class, waiting in the queue when its pool is full. It returns the
+// function to call once the operation finishes and the time spent in the queue.
+func (b *Bulkhead) Acquire(ctx context.Context, class string) (func(), time.Duration, error) {
+	return b.pool(class).acquire(ctx)
+}
+
+// InFlight returns the number of running operations of class.
+func (b *Bulkhead) InFlight(class string) int {
+	return len(b.pool(class).slots)
+}
+
+func (b *Bulkhead) pool(class string) *pool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	p, ok := b.pools[class]
+	if !ok {
+		config, ok := b.configs[class]
+		if !ok {
+			config = b.defaultConfig
+		}
+		p = newPool(config)
+		b.pools[class] = p
+	}
+	return p
+}
+
+type pool struct {
+	config PoolConfig
+	slots  chan struct{}
+
+	mu      sync.Mutex
+	waiting int
+}
+
+func newPool(config PoolConfig) *pool {
+	if config.MaxConcurrent <= 0 {
+		config.MaxConcurrent = 10
+	}
+	return &pool{
+		config: config,
+		slots:  make(chan struct{}, config.MaxConcurrent),
+	}
+}
+
+func (p *pool) acquire(ctx context.Context) (func(), time.Duration, error) {
+	release := func() { <-p.slots }
+	select {
+	case p.slots <- struct{}{}:
+		return release, 0, nil
+	default:
+	}
+
+	p.mu.Lock()
+	if p.waiting >= p.config.MaxQueue {
+		p.mu.Unlock()
+		return nil, 0, ErrQueueFull
+	}
+	p.waiting++
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		p.waiting--
+		p.mu.Unlock()
+	}()
+
+	var timeout <-chan time.Time
+	if p.config.MaxWait > 0 {
+		timer := time.NewTimer(p.config.MaxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	start := time.Now()
+	select {
+	case p.slots <- struct{}{}:
+		return release, time.Since(start), nil
+	case <-timeout:
+		return nil, time.Since(start), ErrQueueTimeout
+	case <-ctx.Done():
+		return nil, time.Since(start), ctx.Err()
+	}
+}
+
+// OperationClass returns the class of an HTTP request from its logging.GetMethodInfo operation:
+// ClassList and ClassRead for reads, ClassDelete for DELETE and ClassWrite for PUT, PATCH and POST,
+// like the otelaudit operation types.
+func OperationClass(method, path string) string {
+	switch method {
+	case http.MethodPatch, http.MethodPost, http.MethodPut:
+		return ClassWrite
+	case http.MethodDelete:
+		return ClassDelete
+	}
+	if strings.HasSuffix(logging.GetMethodInfo(method, path), " - LIST") {
+		return ClassList
+	}
+	return ClassRead
+}
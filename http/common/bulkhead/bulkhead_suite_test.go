@@ -0,0 +1,13 @@
+package bulkhead_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestBulkhead(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Bulkhead Suite")
+}
@@ -0,0 +1,80 @@
+package bulkhead_test
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/Azure/aks-middleware/http/common/bulkhead"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Bulkhead", func() {
+	var (
+		b   *bulkhead.Bulkhead
+		ctx context.Context
+	)
+
+	BeforeEach(func() {
+		b = bulkhead.New(map[string]bulkhead.PoolConfig{
+			bulkhead.ClassWrite: {MaxConcurrent: 1, MaxQueue: 1, MaxWait: 20 * time.Millisecond},
+		}, bulkhead.PoolConfig{MaxConcurrent: 2})
+		ctx = context.Background()
+	})
+
+	It("should isolate the pools of each class", func() {
+		release, queued, err := b.Acquire(ctx, bulkhead.ClassWrite)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(queued).To(BeZero())
+		defer release()
+
+		for i := 0; i < 2; i++ {
+			_, _, err := b.Acquire(ctx, bulkhead.ClassRead)
+			Expect(err).ToNot(HaveOccurred())
+		}
+		Expect(b.InFlight(bulkhead.ClassRead)).To(Equal(2))
+		Expect(b.InFlight(bulkhead.ClassWrite)).To(Equal(1))
+		_, _, err = b.Acquire(ctx, bulkhead.ClassRead)
+		Expect(err).To(MatchError(bulkhead.ErrQueueFull))
+	})
+
+	It("should queue operations until a slot is released", func() {
+		release, _, err := b.Acquire(ctx, bulkhead.ClassWrite)
+		Expect(err).ToNot(HaveOccurred())
+		go func() {
+			time.Sleep(5 * time.Millisecond)
+			release()
+		}()
+		release, queued, err := b.Acquire(ctx, bulkhead.ClassWrite)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(queued).To(BeNumerically(">", 0))
+		release()
+	})
+
+	It("should reject operations when the queue is full or after MaxWait", func() {
+		release, _, err := b.Acquire(ctx, bulkhead.ClassWrite)
+		Expect(err).ToNot(HaveOccurred())
+		defer release()
+
+		waited := make(chan error)
+		go func() {
+			_, _, err := b.Acquire(ctx, bulkhead.ClassWrite)
+			waited <- err
+		}()
+		// Let the goroutine take the only place in the queue.
+		time.Sleep(5 * time.Millisecond)
+		_, _, err = b.Acquire(ctx, bulkhead.ClassWrite)
+		Expect(err).To(MatchError(bulkhead.ErrQueueFull))
+		Expect(<-waited).To(MatchError(bulkhead.ErrQueueTimeout))
+	})
+
+	It("should classify HTTP operations", func() {
+		const base = "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.ContainerService/managedClusters"
+		Expect(bulkhead.OperationClass(http.MethodGet, base)).To(Equal(bulkhead.ClassList))
+		Expect(bulkhead.OperationClass(http.MethodGet, base+"/mc")).To(Equal(bulkhead.ClassRead))
+		Expect(bulkhead.OperationClass(http.MethodPut, base+"/mc")).To(Equal(bulkhead.ClassWrite))
+		Expect(bulkhead.OperationClass(http.MethodPost, base+"/mc/stop")).To(Equal(bulkhead.ClassWrite))
+		Expect(bulkhead.OperationClass(http.MethodDelete, base+"/mc")).To(Equal(bulkhead.ClassDelete))
+	})
+})
@@ -0,0 +1,401 @@
+package circuitbreaker
+
+// This package stops calling dependencies that are failing. A CircuitBreaker keeps a breaker per key, e.g. a
+// gRPC target and method or a host and ARM resource type. A breaker opens when the failure rate over its
+// window reaches FailureRate, fails calls fast while open, and lets a few probe calls through once OpenTimeout
+// has passed (half-open): it closes again when they all succeed and reopens when one fails. Probes that never
+// report back, e.g. abandoned streams, free their slot after another OpenTimeout.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/aks-middleware/http/common/logging"
+	"github.com/Azure/aks-middleware/http/common/metrics"
+	azcorePolicy "github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// State is the state of a breaker.
+type State int
+
+const (
+	StateClosed State = iota
+	StateHalfOpen
+	StateOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateHalfOpen:
+		return "half-open"
+	case StateOpen:
+		return "open"
+	default:
+		return fmt.Sprintf("State(%d)", int(s))
+	}
+}
+
+// StateMetricName is the gauge of RegisterMetrics: 0 closed, 1 half-open, 2 open, per key.
+const StateMetricName = "circuitbreaker.state"
+
+// KeyAttributeKey is the attribute holding the breaker key in the StateMetricName gauge.
+const KeyAttributeKey = attribute.Key("key")
+
+// ErrOpen is returned for calls failed fast by an open breaker.
+var ErrOpen = errors.New("circuit breaker open")
+
+// openError is the error of the calls failed fast. It is not retried by the Azure SDK retry policy.
+type openError struct {
+	key string
+}
+
+func (e *openError) Error() string {
+	return ErrOpen.Error() + " for " + e.key
+}
+
+func (e *openError) Is(target error) bool {
+	return target == ErrOpen
+}
+
+// NonRetriable implements errorinfo.NonRetriable.
+func (e *openError) NonRetriable() {}
+
+// Options configure a CircuitBreaker. Zero values use the defaults.
+type Options struct {
+	// Window is the period the failure rate is computed over. Defaults to 10s.
+	Window time.Duration
+	// MinRequests is the number of calls in the window below which the breaker doesn't open. Defaults to 20.
+	MinRequests int
+	// FailureRate is the fraction of failed calls in the window that opens the breaker. Defaults to 0.5.
+	FailureRate float64
+	// OpenTimeout is how long the breaker stays open before letting probe calls through. Defaults to 30s.
+	OpenTimeout time.Duration
+	// HalfOpenRequests is the number of probe calls that must succeed to close the breaker. Defaults to 1.
+	HalfOpenRequests int
+	// GetLogger returns the logger the state transitions are logged to, e.g. ctxlogger.GetLogger
+	// for the CtxLog. Defaults to slog.Default().
+	GetLogger func(ctx context.Context) *slog.Logger
+}
+
+// windowBuckets is the number of buckets the window slides by.
+const windowBuckets = 10
+
+// CircuitBreaker holds a breaker per key.
+type CircuitBreaker struct {
+	opts Options
+	now  func() time.Time
+
+	mu       sync.Mutex
+	breakers map[string]*breaker
+}
+
+// New returns a CircuitBreaker with opts.
+func New(opts Options) *CircuitBreaker {
+	if opts.Window <= 0 {
+		opts.Window = 10 * time.Second
+	}
+	if opts.MinRequests <= 0 {
+		opts.MinRequests = 20
+	}
+	if opts.FailureRate <= 0 || opts.FailureRate > 1 {
+		opts.FailureRate = 0.5
+	}
+	if opts.OpenTimeout <= 0 {
+		opts.OpenTimeout = 30 * time.Second
+	}
+	if opts.HalfOpenRequests <= 0 {
+		opts.HalfOpenRequests = 1
+	}
+	if opts.GetLogger == nil {
+		opts.GetLogger = func(context.Context) *slog.Logger { return slog.Default() }
+	}
+	return &CircuitBreaker{
+		opts:     opts,
+		now:      time.Now,
+		breakers: map[string]*breaker{},
+	}
+}
+
+type bucket struct {
+	start               time.Time
+	successes, failures int
+}
+
+type breaker struct {
+	state State
+	// changedAt is the time of the last transition.
+	changedAt time.Time
+	buckets   [windowBuckets]bucket
+	// probes are the calls let through while half-open, successes those that succeeded.
+	probes, successes int
+}
+
+// Allow returns whether a call for key can be made. When it can, done must be called with whether the
+// call failed once it finishes. When it can't, the error wraps ErrOpen.
+func (cb *CircuitBreaker) Allow(ctx context.Context, key string) (func(failed bool), error) {
+	cb.mu.Lock()
+	state, change, err := cb.allow(key)
+	cb.mu.Unlock()
+	cb.logChange(ctx, change)
+	if err != nil {
+		return nil, err
+	}
+	return func(failed bool) {
+		cb.done(ctx, key, state, failed)
+	}, nil
+}
+
+// allow must be called with cb.mu held. It returns the state the call is allowed in.
+func (cb *CircuitBreaker) allow(key string) (State, *stateChange, error) {
+	b := cb.breaker(key)
+	now := cb.now()
+	var change *stateChange
+	if b.state != StateClosed && now.Sub(b.changedAt) >= cb.opts.OpenTimeout {
+		if b.state == StateOpen {
+			change = cb.transition(key, b, StateHalfOpen, now)
+		} else if b.probes >= cb.opts.HalfOpenRequests {
+			b.changedAt = now
+			b.probes, b.successes = 0, 0
+		}
+	}
+	switch b.state {
+	case StateOpen:
+		return b.state, change, &openError{key: key}
+	case StateHalfOpen:
+		if b.probes >= cb.opts.HalfOpenRequests {
+			return b.state, change, &openError{key: key}
+		}
+		b.probes++
+	}
+	return b.state, change, nil
+}
+
+func (cb *CircuitBreaker) done(ctx context.Context, key string, allowedIn State, failed bool) {
+	cb.mu.Lock()
+	change := cb.record(key, allowedIn, failed)
+	cb.mu.Unlock()
+	cb.logChange(ctx, change)
+}
+
+// record must be called with cb.mu held.
+func (cb *CircuitBreaker) record(key string, allowedIn State, failed bool) *stateChange {
+	b := cb.breaker(key)
+	now := cb.now()
+	switch b.state {
+	case StateHalfOpen:
+		if allowedIn != StateHalfOpen {
+			// A call made before the breaker opened.
+			return nil
+		}
+		if failed {
+			return cb.transition(key, b, StateOpen, now)
+		}
+		b.successes++
+		if b.successes >= cb.opts.HalfOpenRequests {
+			return cb.transition(key, b, StateClosed, now)
+		}
+	case StateClosed:
+		current := b.bucket(now, cb.opts.Window)
+		if failed {
+			current.failures++
+		} else {
+			current.successes++
+		}
+		successes, failures := b.counts(now, cb.opts.Window)
+		total := successes + failures
+		if failed && total >= cb.opts.MinRequests && float64(failures)/float64(total) >= cb.opts.FailureRate {
+			return cb.transition(key, b, StateOpen, now)
+		}
+	}
+	return nil
+}
+
+// stateChange is a transition of a breaker, logged once cb.mu is released.
+type stateChange struct {
+	key      string
+	from, to State
+}
+
+// transition must be called with cb.mu held.
+func (cb *CircuitBreaker) transition(key string, b *breaker, to State, now time.Time) *stateChange {
+	from := b.state
+	b.state = to
+	b.changedAt = now
+	b.probes, b.successes = 0, 0
+	if to == StateClosed {
+		b.buckets = [windowBuckets]bucket{}
+	}
+	return &stateChange{key: key, from: from, to: to}
+}
+
+// logChange must be called without cb.mu held, the logger being user code.
+func (cb *CircuitBreaker) logChange(ctx context.Context, change *stateChange) {
+	if change == nil {
+		return
+	}
+	cb.opts.GetLogger(ctx).WarnContext(ctx, "circuit breaker state changed",
+		"breaker", change.key,
+		"from", change.from.String(),
+		"to", change.to.String(),
+	)
+}
+
+// breaker must be called with cb.mu held.
+func (cb *CircuitBreaker) breaker(key string) *breaker {
+	b, ok := cb.breakers[key]
+	if !ok {
+		b = &breaker{}
+		cb.breakers[key] = b
+	}
+	return b
+}
+
+// bucket returns the bucket of now, resetting it when it holds the counts of an earlier window.
+func (b *breaker) bucket(now time.Time, window time.Duration) *bucket {
+	width := window / windowBuckets
+	start := now.Truncate(width)
+	current := &b.buckets[(start.UnixNano()/int64(width))%windowBuckets]
+	if !current.start.Equal(start) {
+		*current = bucket{start: start}
+	}
+	return current
+}
+
+// counts returns the calls of the buckets within window of now.
+func (b *breaker) counts(now time.Time, window time.Duration) (int, int) {
+	var successes, failures int
+	for _, bucket := range b.buckets {
+		if now.Sub(bucket.start) < window {
+			successes += bucket.successes
+			failures += bucket.failures
+		}
+	}
+	return successes, failures
+}
+
+// State returns the state of the breaker of key.
+func (cb *CircuitBreaker) State(key string) State {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if b, ok := cb.breakers[key]; ok {
+		return b.state
+	}
+	return StateClosed
+}
+
+// States returns the state of every breaker.
+func (cb *CircuitBreaker) States() map[string]State {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	states := make(map[string]State, len(cb.breakers))
+	for key, b := range cb.breakers {
+		states[key] = b.state
+	}
+	return states
+}
+
+// RegisterMetrics exports the state of every breaker as the StateMetricName gauge of mp.
+func (cb *CircuitBreaker) RegisterMetrics(mp metric.MeterProvider) error {
+	meter := mp.Meter(metrics.InstrumentationName)
+	gauge, err := meter.Int64ObservableGauge(StateMetricName,
+		metric.WithDescription("Circuit breaker state: 0 closed, 1 half-open, 2 open."),
+	)
+	if err != nil {
+		return err
+	}
+	_, err = meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		states := cb.States()
+		keys := make([]string, 0, len(states))
+		for key := range states {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			o.ObserveInt64(gauge, int64(states[key]), metric.WithAttributes(KeyAttributeKey.String(key)))
+		}
+		return nil
+	}, gauge)
+	return err
+}
+
+// RoundTripper fails outbound requests fast while the breaker of their host and ARM resource type is open.
+type RoundTripper struct {
+	Proxied        http.RoundTripper
+	CircuitBreaker *CircuitBreaker
+}
+
+// NewRoundTripper wraps proxied with a circuit breaking RoundTripper.
+func NewRoundTripper(proxied http.RoundTripper, cb *CircuitBreaker) *RoundTripper {
+	return &RoundTripper{
+		Proxied:        proxied,
+		CircuitBreaker: cb,
+	}
+}
+
+func (t *RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	done, err := t.CircuitBreaker.Allow(req.Context(), RESTKey(req.URL))
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.Proxied.RoundTrip(req)
+	done(RESTFailed(resp, err))
+	return resp, err
+}
+
+// Policy is an Azure SDK policy failing requests fast while the breaker of their host and ARM resource type
+// is open. Add it to the PerRetryPolicies so every attempt counts; its error stops the SDK retries.
+type Policy struct {
+	cb *CircuitBreaker
+}
+
+// NewPolicy returns an Azure SDK policy using cb.
+func NewPolicy(cb *CircuitBreaker) *Policy {
+	return &Policy{cb: cb}
+}
+
+func (p *Policy) Do(req *azcorePolicy.Request) (*http.Response, error) {
+	raw := req.Raw()
+	done, err := p.cb.Allow(raw.Context(), RESTKey(raw.URL))
+	if err != nil {
+		return nil, err
+	}
+	resp, err := req.Next()
+	done(RESTFailed(resp, err))
+	return resp, err
+}
+
+func (p *Policy) Clone() azcorePolicy.Policy {
+	return &Policy{cb: p.cb}
+}
+
+// RESTKey returns the breaker key of an outbound request URL: its host and ARM resource type,
+// e.g. "management.azure.com managedclusters", or its host alone for other URLs.
+func RESTKey(u *url.URL) string {
+	path := u.Path
+	methodInfo := logging.GetMethodInfo(http.MethodPut, path)
+	if methodInfo == http.MethodPut+" "+path {
+		return u.Host
+	}
+	return u.Host + " " + strings.TrimPrefix(methodInfo, http.MethodPut+" ")
+}
+
+// RESTFailed returns whether an outbound HTTP call counts as a failure of the dependency:
+// a transport error, a throttling response or a server error.
+func RESTFailed(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled)
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+}
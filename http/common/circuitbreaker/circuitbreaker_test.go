@@ -0,0 +1,208 @@
+package circuitbreaker_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	"github.com/Azure/aks-middleware/http/common/circuitbreaker"
+	azcorePolicy "github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// call makes a call for key through cb and reports whether it failed.
+func call(cb *circuitbreaker.CircuitBreaker, key string, failed bool) error {
+	done, err := cb.Allow(context.Background(), key)
+	if err != nil {
+		return err
+	}
+	done(failed)
+	return nil
+}
+
+var _ = Describe("CircuitBreaker", func() {
+	var (
+		buf  *bytes.Buffer
+		opts circuitbreaker.Options
+	)
+
+	BeforeEach(func() {
+		buf = &bytes.Buffer{}
+		logger := slog.New(slog.NewJSONHandler(buf, nil))
+		opts = circuitbreaker.Options{
+			MinRequests: 4,
+			FailureRate: 0.5,
+			OpenTimeout: 20 * time.Millisecond,
+			GetLogger:   func(context.Context) *slog.Logger { return logger },
+		}
+	})
+
+	It("should open when the failure rate is reached and fail fast", func() {
+		cb := circuitbreaker.New(opts)
+		Expect(call(cb, "a", false)).To(Succeed())
+		Expect(call(cb, "a", false)).To(Succeed())
+		Expect(call(cb, "a", true)).To(Succeed())
+		Expect(cb.State("a")).To(Equal(circuitbreaker.StateClosed))
+		Expect(call(cb, "a", true)).To(Succeed())
+		Expect(cb.State("a")).To(Equal(circuitbreaker.StateOpen))
+
+		err := call(cb, "a", false)
+		Expect(errors.Is(err, circuitbreaker.ErrOpen)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("for a"))
+		Expect(buf.String()).To(ContainSubstring(`"msg":"circuit breaker state changed","breaker":"a","from":"closed","to":"open"`))
+
+		Expect(cb.State("b")).To(Equal(circuitbreaker.StateClosed))
+		Expect(call(cb, "b", false)).To(Succeed())
+	})
+
+	It("should not open below MinRequests", func() {
+		cb := circuitbreaker.New(opts)
+		for i := 0; i < 3; i++ {
+			Expect(call(cb, "a", true)).To(Succeed())
+		}
+		Expect(cb.State("a")).To(Equal(circuitbreaker.StateClosed))
+	})
+
+	It("should forget the failures outside the window", func() {
+		opts.Window = 20 * time.Millisecond
+		cb := circuitbreaker.New(opts)
+		for i := 0; i < 3; i++ {
+			Expect(call(cb, "a", true)).To(Succeed())
+		}
+		time.Sleep(30 * time.Millisecond)
+		Expect(call(cb, "a", true)).To(Succeed())
+		Expect(cb.State("a")).To(Equal(circuitbreaker.StateClosed))
+	})
+
+	It("should close after successful probes when half-open", func() {
+		opts.HalfOpenRequests = 2
+		cb := circuitbreaker.New(opts)
+		for i := 0; i < 4; i++ {
+			Expect(call(cb, "a", true)).To(Succeed())
+		}
+		Expect(cb.State("a")).To(Equal(circuitbreaker.StateOpen))
+		time.Sleep(30 * time.Millisecond)
+
+		first, err := cb.Allow(context.Background(), "a")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cb.State("a")).To(Equal(circuitbreaker.StateHalfOpen))
+		second, err := cb.Allow(context.Background(), "a")
+		Expect(err).ToNot(HaveOccurred())
+		_, err = cb.Allow(context.Background(), "a")
+		Expect(errors.Is(err, circuitbreaker.ErrOpen)).To(BeTrue())
+
+		first(false)
+		Expect(cb.State("a")).To(Equal(circuitbreaker.StateHalfOpen))
+		second(false)
+		Expect(cb.State("a")).To(Equal(circuitbreaker.StateClosed))
+		Expect(buf.String()).To(ContainSubstring(`"from":"half-open","to":"closed"`))
+	})
+
+	It("should reopen when a probe fails", func() {
+		cb := circuitbreaker.New(opts)
+		for i := 0; i < 4; i++ {
+			Expect(call(cb, "a", true)).To(Succeed())
+		}
+		time.Sleep(30 * time.Millisecond)
+		Expect(call(cb, "a", true)).To(Succeed())
+		Expect(cb.State("a")).To(Equal(circuitbreaker.StateOpen))
+		Expect(errors.Is(call(cb, "a", false), circuitbreaker.ErrOpen)).To(BeTrue())
+	})
+
+	It("should export the states", func() {
+		cb := circuitbreaker.New(opts)
+		for i := 0; i < 4; i++ {
+			Expect(call(cb, "a", true)).To(Succeed())
+		}
+		Expect(call(cb, "b", false)).To(Succeed())
+		Expect(cb.States()).To(Equal(map[string]circuitbreaker.State{
+			"a": circuitbreaker.StateOpen,
+			"b": circuitbreaker.StateClosed,
+		}))
+
+		reader := sdkmetric.NewManualReader()
+		Expect(cb.RegisterMetrics(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))).To(Succeed())
+		var rm metricdata.ResourceMetrics
+		Expect(reader.Collect(context.Background(), &rm)).To(Succeed())
+		gauge := rm.ScopeMetrics[0].Metrics[0]
+		Expect(gauge.Name).To(Equal(circuitbreaker.StateMetricName))
+		values := map[string]int64{}
+		for _, dp := range gauge.Data.(metricdata.Gauge[int64]).DataPoints {
+			key, _ := dp.Attributes.Value(circuitbreaker.KeyAttributeKey)
+			values[key.AsString()] = dp.Value
+		}
+		Expect(values).To(Equal(map[string]int64{"a": 2, "b": 0}))
+	})
+})
+
+var _ = Describe("RESTKey", func() {
+	It("should key ARM URLs by host and resource type", func() {
+		u, err := url.Parse("https://management.azure.com/subscriptions/sub/resourceGroups/rg/providers/Microsoft.ContainerService/managedClusters/mc?api-version=2024-01-01")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(circuitbreaker.RESTKey(u)).To(Equal("management.azure.com managedclusters"))
+	})
+
+	It("should key other URLs by host", func() {
+		u, err := url.Parse("https://example.com/foo/bar")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(circuitbreaker.RESTKey(u)).To(Equal("example.com"))
+	})
+})
+
+var _ = Describe("RoundTripper and Policy", func() {
+	var (
+		server *httptest.Server
+		calls  int
+		cb     *circuitbreaker.CircuitBreaker
+	)
+
+	BeforeEach(func() {
+		calls = 0
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		cb = circuitbreaker.New(circuitbreaker.Options{MinRequests: 2, GetLogger: func(context.Context) *slog.Logger {
+			return slog.New(slog.NewTextHandler(io.Discard, nil))
+		}})
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should fail requests fast once the dependency fails", func() {
+		client := &http.Client{Transport: circuitbreaker.NewRoundTripper(http.DefaultTransport, cb)}
+		for i := 0; i < 2; i++ {
+			resp, err := client.Get(server.URL)
+			Expect(err).ToNot(HaveOccurred())
+			resp.Body.Close()
+		}
+		_, err := client.Get(server.URL)
+		Expect(errors.Is(err, circuitbreaker.ErrOpen)).To(BeTrue())
+		Expect(calls).To(Equal(2))
+	})
+
+	It("should stop the Azure SDK retries", func() {
+		pl := runtime.NewPipeline("test", "v1.0.0", runtime.PipelineOptions{
+			PerRetry: []azcorePolicy.Policy{circuitbreaker.NewPolicy(cb)},
+		}, &azcorePolicy.ClientOptions{
+			Retry: azcorePolicy.RetryOptions{MaxRetries: 5, RetryDelay: time.Millisecond, MaxRetryDelay: time.Millisecond},
+		})
+		req, err := runtime.NewRequest(context.Background(), http.MethodGet, server.URL)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = pl.Do(req)
+		Expect(errors.Is(err, circuitbreaker.ErrOpen)).To(BeTrue())
+		Expect(calls).To(Equal(2))
+	})
+})
@@ -5,6 +5,9 @@ const (
     OperationIDKey        = "operationid"
     ARMClientRequestIDKey = "armclientrequestid"
     RequestIDLogKey       = "request-id"
+    // RetryAfterKey is the gRPC trailer holding the seconds to wait before retrying a rejected call,
+    // returned as the Retry-After header through the gateway.
+    RetryAfterKey = "retry-after"
 
     // Details can be found here:
     // https://github.com/Azure/azure-resource-manager-rpc/blob/master/v1.0/common-api-details.md#client-request-headers
@@ -16,6 +19,10 @@ const (
     // RequestIDMetadataKey is the key in the gRPC
     // metadata.
     RequestIDMetadataHeader = "x-request-id"
+    // RequestClientTenantIDHeader is the tenant of the caller, set by ARM
+    RequestClientTenantIDHeader = "x-ms-client-tenant-id"
+    // RequestClientAppIDHeader is the application ID of the caller, set by ARM
+    RequestClientAppIDHeader = "x-ms-client-app-id"
 	RequestAcceptLanguageHeader = "Accept-Language"
 )
 
@@ -0,0 +1,199 @@
+package deadline
+
+// This package applies per-method server timeouts and forwards the remaining deadline budget of a request
+// to its outbound calls. Servers install a tracker in the request context; client middlewares record in it
+// the dependency whose call ran out of time, so that an expired request can be logged as having run out of
+// budget locally or in a dependency.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// BudgetHeader carries the remaining budget of an outbound HTTP request in milliseconds.
+// The HTTP server middleware caps the route timeout with it. gRPC uses its own grpc-timeout header.
+const BudgetHeader = "x-ms-deadline-budget-ms"
+
+// Log attributes of an expired request.
+const (
+	// ExceededLogKey is ExceededLocally or ExceededInDependency.
+	ExceededLogKey = "deadline_exceeded"
+	// DependencyLogKey is the method of the dependency call that ran out of time.
+	DependencyLogKey = "deadline_dependency"
+)
+
+const (
+	ExceededLocally      = "local"
+	ExceededInDependency = "dependency"
+)
+
+// DefaultSafetyMargin is the margin used by a Budget without SafetyMargin.
+const DefaultSafetyMargin = 50 * time.Millisecond
+
+// ErrBudgetExhausted is returned for outbound calls made with less than the safety margin left.
+var ErrBudgetExhausted = fmt.Errorf("deadline budget exhausted: %w", context.DeadlineExceeded)
+
+// Timeouts are the default server timeouts. A nil *Timeouts applies no timeout.
+type Timeouts struct {
+	// Default is the timeout of methods without an entry in Methods. 0 applies no timeout.
+	Default time.Duration
+	// Methods overrides Default per method: the gRPC full method, e.g. "/pkg.Service/Method",
+	// or the logging.GetMethodInfo operation for HTTP, e.g. "PUT resourcetype".
+	Methods map[string]time.Duration
+}
+
+// For returns the timeout of method, 0 for none.
+func (t *Timeouts) For(method string) time.Duration {
+	if t == nil {
+		return 0
+	}
+	if timeout, ok := t.Methods[method]; ok {
+		return timeout
+	}
+	return t.Default
+}
+
+// WithTimeout returns ctx with the timeout of method and a tracker for the dependency calls.
+// An earlier deadline already on ctx, e.g. set by the caller, is kept.
+func (t *Timeouts) WithTimeout(ctx context.Context, method string) (context.Context, context.CancelFunc) {
+	ctx = WithTracker(ctx)
+	if timeout := t.For(method); timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// Budget forwards the remaining deadline of a request to its outbound calls, minus a safety margin
+// left to handle their result. A nil *Budget forwards the deadline unchanged.
+type Budget struct {
+	// SafetyMargin is subtracted from the remaining time. Defaults to DefaultSafetyMargin.
+	SafetyMargin time.Duration
+}
+
+// Outbound returns the context for an outbound call. Without a deadline on ctx, ctx is returned as is.
+// ErrBudgetExhausted is returned when no more than the safety margin is left.
+func (b *Budget) Outbound(ctx context.Context) (context.Context, context.CancelFunc, error) {
+	deadline, ok := ctx.Deadline()
+	if b == nil || !ok {
+		return ctx, func() {}, nil
+	}
+	margin := b.SafetyMargin
+	if margin <= 0 {
+		margin = DefaultSafetyMargin
+	}
+	if time.Until(deadline) <= margin {
+		return ctx, func() {}, ErrBudgetExhausted
+	}
+	outCtx, cancel := context.WithDeadline(ctx, deadline.Add(-margin))
+	return outCtx, cancel, nil
+}
+
+// Remaining returns the time left before the deadline of ctx, and false when ctx has no deadline.
+func Remaining(ctx context.Context) (time.Duration, bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+	return time.Until(deadline), true
+}
+
+// SetBudgetHeader sets BudgetHeader on header from the deadline of ctx.
+func SetBudgetHeader(ctx context.Context, header http.Header) {
+	if remaining, ok := Remaining(ctx); ok {
+		header.Set(BudgetHeader, strconv.FormatInt(remaining.Milliseconds(), 10))
+	}
+}
+
+// BudgetFromHeader returns the budget in BudgetHeader, and false when it is missing or invalid.
+func BudgetFromHeader(header http.Header) (time.Duration, bool) {
+	ms, err := strconv.ParseInt(header.Get(BudgetHeader), 10, 64)
+	if err != nil || ms <= 0 {
+		return 0, false
+	}
+	return time.Duration(ms) * time.Millisecond, true
+}
+
+// CancelOnClose calls cancel when the body of resp is closed, so that the outbound context
+// stays valid while the body is read. cancel is called immediately when there is no body.
+func CancelOnClose(resp *http.Response, cancel context.CancelFunc) {
+	if resp == nil || resp.Body == nil {
+		cancel()
+		return
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+}
+
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+type trackerKey struct{}
+
+// tracker holds the first dependency call of a request that ran out of time.
+type tracker struct {
+	mu         sync.Mutex
+	dependency string
+}
+
+// WithTracker returns ctx with a tracker for the dependency calls made with it.
+func WithTracker(ctx context.Context) context.Context {
+	return context.WithValue(ctx, trackerKey{}, &tracker{})
+}
+
+// RecordDependency records dependency as having run out of time when err is a deadline error.
+// It is a no-op without a tracker on ctx.
+func RecordDependency(ctx context.Context, dependency string, err error) {
+	if !errors.Is(err, context.DeadlineExceeded) {
+		return
+	}
+	RecordDependencyExceeded(ctx, dependency)
+}
+
+// RecordDependencyExceeded records dependency as having run out of time.
+func RecordDependencyExceeded(ctx context.Context, dependency string) {
+	t, ok := ctx.Value(trackerKey{}).(*tracker)
+	if !ok {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.dependency == "" {
+		t.dependency = dependency
+	}
+}
+
+// Exceeded returns where the budget of an expired request ran out: ExceededInDependency and the dependency
+// when one of its dependency calls ran out of time, ExceededLocally otherwise.
+func Exceeded(ctx context.Context) (string, string) {
+	if t, ok := ctx.Value(trackerKey{}).(*tracker); ok {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if t.dependency != "" {
+			return ExceededInDependency, t.dependency
+		}
+	}
+	return ExceededLocally, ""
+}
+
+// LogAttrs returns the ExceededLogKey and DependencyLogKey attributes of an expired request.
+func LogAttrs(ctx context.Context) []any {
+	where, dependency := Exceeded(ctx)
+	attrs := []any{ExceededLogKey, where}
+	if dependency != "" {
+		attrs = append(attrs, DependencyLogKey, dependency)
+	}
+	return attrs
+}
@@ -0,0 +1,105 @@
+package deadline_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Azure/aks-middleware/http/common/deadline"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Timeouts", func() {
+	timeouts := &deadline.Timeouts{
+		Default: time.Minute,
+		Methods: map[string]time.Duration{"/MyGreeter/SayHello": time.Second},
+	}
+
+	It("should return the timeout of a method", func() {
+		Expect(timeouts.For("/MyGreeter/SayHello")).To(Equal(time.Second))
+		Expect(timeouts.For("/MyGreeter/List")).To(Equal(time.Minute))
+		var nilTimeouts *deadline.Timeouts
+		Expect(nilTimeouts.For("/MyGreeter/SayHello")).To(BeZero())
+	})
+
+	It("should apply the timeout unless the context has an earlier deadline", func() {
+		ctx, cancel := timeouts.WithTimeout(context.Background(), "/MyGreeter/SayHello")
+		defer cancel()
+		remaining, ok := deadline.Remaining(ctx)
+		Expect(ok).To(BeTrue())
+		Expect(remaining).To(BeNumerically("~", time.Second, 100*time.Millisecond))
+
+		parent, parentCancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer parentCancel()
+		ctx, cancel = timeouts.WithTimeout(parent, "/MyGreeter/List")
+		defer cancel()
+		remaining, _ = deadline.Remaining(ctx)
+		Expect(remaining).To(BeNumerically("<=", 10*time.Millisecond))
+	})
+})
+
+var _ = Describe("Budget", func() {
+	budget := &deadline.Budget{SafetyMargin: 100 * time.Millisecond}
+
+	It("should subtract the safety margin", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		outCtx, outCancel, err := budget.Outbound(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		defer outCancel()
+		remaining, ok := deadline.Remaining(outCtx)
+		Expect(ok).To(BeTrue())
+		Expect(remaining).To(BeNumerically("~", 900*time.Millisecond, 50*time.Millisecond))
+	})
+
+	It("should leave contexts without a deadline unchanged", func() {
+		ctx := context.Background()
+		outCtx, outCancel, err := budget.Outbound(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		defer outCancel()
+		Expect(outCtx).To(Equal(ctx))
+	})
+
+	It("should fail when the budget is exhausted", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, _, err := budget.Outbound(ctx)
+		Expect(err).To(MatchError(deadline.ErrBudgetExhausted))
+		Expect(errors.Is(err, context.DeadlineExceeded)).To(BeTrue())
+	})
+
+	It("should forward the budget in a header", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		header := http.Header{}
+		deadline.SetBudgetHeader(ctx, header)
+		budget, ok := deadline.BudgetFromHeader(header)
+		Expect(ok).To(BeTrue())
+		Expect(budget).To(BeNumerically("~", time.Second, 100*time.Millisecond))
+
+		_, ok = deadline.BudgetFromHeader(http.Header{deadline.BudgetHeader: []string{"soon"}})
+		Expect(ok).To(BeFalse())
+	})
+})
+
+var _ = Describe("Exceeded", func() {
+	It("should report local expiry without a dependency", func() {
+		ctx := deadline.WithTracker(context.Background())
+		deadline.RecordDependency(ctx, "/Dependency/Get", errors.New("not a deadline"))
+		where, dependency := deadline.Exceeded(ctx)
+		Expect(where).To(Equal(deadline.ExceededLocally))
+		Expect(dependency).To(BeEmpty())
+	})
+
+	It("should report the first dependency that ran out of time", func() {
+		ctx := deadline.WithTracker(context.Background())
+		deadline.RecordDependency(ctx, "/Dependency/Get", context.DeadlineExceeded)
+		deadline.RecordDependency(ctx, "/Dependency/List", context.DeadlineExceeded)
+		Expect(deadline.LogAttrs(ctx)).To(Equal([]any{
+			deadline.ExceededLogKey, deadline.ExceededInDependency,
+			deadline.DependencyLogKey, "/Dependency/Get",
+		}))
+	})
+})
@@ -0,0 +1,202 @@
+package idempotency
+
+// This package replays the responses of retried write requests, identified by their x-ms-client-request-id.
+// A fingerprint of the request is stored with its response, so that a different request reusing the client
+// request id, e.g. a second downstream call made with the forwarded id, isn't answered with the response of the
+// first one. The responses live in a Store, in memory by default; a shared backend can implement Store so that a retry
+// reaching another instance of a service is also replayed.
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReplayLogKey is the log key set on the requests answered with a stored response.
+const ReplayLogKey = "idempotent_replay"
+
+// CodeClientRequestIDReused is the ARM error code of the requests reusing the key of a completed request
+// with a different fingerprint, see ErrFingerprintMismatch. They get a 400 over HTTP and through the gateway.
+const CodeClientRequestIDReused = "ClientRequestIdReused"
+
+// DefaultTTL is how long the responses are kept by default.
+const DefaultTTL = 10 * time.Minute
+
+// ErrInFlight is returned by Begin when the first attempt of the request is still in flight.
+var ErrInFlight = errors.New("request with the same client request id is in flight")
+
+// ErrFingerprintMismatch is returned by Begin when the stored response answers a different request with the
+// same key.
+var ErrFingerprintMismatch = errors.New("request with the same client request id has a different content")
+
+// Response is a stored response. The gRPC interceptors store the gRPC code in StatusCode and the
+// marshaled response or status in Body.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	// Fingerprint is the fingerprint of the request answered by the response, see Fingerprint.
+	Fingerprint string
+}
+
+// Entry is the state of a key in a Store.
+type Entry struct {
+	// InFlight is set until the response of the first attempt is stored.
+	InFlight bool
+	// Response is the stored response when the entry isn't in flight.
+	Response Response
+}
+
+// Store holds the entries by key. The entries expire after the ttl of their last write.
+type Store interface {
+	// Start creates an in-flight entry for key when it has none and returns nil.
+	// Otherwise it returns the existing entry.
+	Start(ctx context.Context, key string, ttl time.Duration) (*Entry, error)
+	// Finish stores the response of key.
+	Finish(ctx context.Context, key string, resp Response, ttl time.Duration) error
+	// Cancel removes the entry of key, so that the next attempt is executed.
+	Cancel(ctx context.Context, key string) error
+}
+
+// MemoryStore is an in-memory Store. Expired entries are removed periodically.
+type MemoryStore struct {
+	now func() time.Time
+
+	mu        sync.Mutex
+	entries   map[string]*memoryEntry
+	lastPrune time.Time
+}
+
+type memoryEntry struct {
+	Entry
+	expires time.Time
+}
+
+// pruneInterval is how often MemoryStore removes its expired entries.
+const pruneInterval = time.Minute
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		now:     time.Now,
+		entries: map[string]*memoryEntry{},
+	}
+}
+
+func (s *MemoryStore) Start(ctx context.Context, key string, ttl time.Duration) (*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Sub(s.lastPrune) > pruneInterval {
+		s.prune(now)
+		s.lastPrune = now
+	}
+	if e, ok := s.entries[key]; ok && now.Before(e.expires) {
+		entry := e.Entry
+		return &entry, nil
+	}
+	s.entries[key] = &memoryEntry{Entry: Entry{InFlight: true}, expires: now.Add(ttl)}
+	return nil, nil
+}
+
+func (s *MemoryStore) Finish(ctx context.Context, key string, resp Response, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = &memoryEntry{Entry: Entry{Response: resp}, expires: s.now().Add(ttl)}
+	return nil
+}
+
+func (s *MemoryStore) Cancel(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+// prune must be called with s.mu held.
+func (s *MemoryStore) prune(now time.Time) {
+	for key, e := range s.entries {
+		if !now.Before(e.expires) {
+			delete(s.entries, key)
+		}
+	}
+}
+
+// Idempotency stores the responses of requests through Store for TTL.
+type Idempotency struct {
+	Store Store
+	TTL   time.Duration
+}
+
+// New returns an Idempotency with an in-memory store keeping the responses for DefaultTTL.
+func New() *Idempotency {
+	return &Idempotency{Store: NewMemoryStore(), TTL: DefaultTTL}
+}
+
+// Begin starts the request of key with fingerprint. It returns the stored response of a completed duplicate,
+// ErrInFlight when the first attempt is still in flight, ErrFingerprintMismatch when the completed request of
+// key had another fingerprint, or nil when the request must be executed; its response must then be given to End.
+func (i *Idempotency) Begin(ctx context.Context, key, fingerprint string) (*Response, error) {
+	entry, err := i.Store.Start(ctx, key, i.ttl())
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	if entry.InFlight {
+		return nil, ErrInFlight
+	}
+	if entry.Response.Fingerprint != fingerprint {
+		return nil, ErrFingerprintMismatch
+	}
+	return &entry.Response, nil
+}
+
+// End stores resp, answering the request of key with fingerprint, for the duplicates of key. A nil resp
+// cancels the key instead, so that the next attempt is executed, e.g. when the request failed with a
+// retriable error.
+func (i *Idempotency) End(ctx context.Context, key, fingerprint string, resp *Response) error {
+	if resp == nil {
+		return i.Store.Cancel(ctx, key)
+	}
+	stored := *resp
+	stored.Fingerprint = fingerprint
+	return i.Store.Finish(ctx, key, stored, i.ttl())
+}
+
+func (i *Idempotency) ttl() time.Duration {
+	if i.TTL <= 0 {
+		return DefaultTTL
+	}
+	return i.TTL
+}
+
+// Key returns the key of a request from its client request id, subscription and route. Requests without a
+// client request id have no key and are not made idempotent.
+func Key(clientRequestID, subscriptionID, route string) string {
+	if clientRequestID == "" {
+		return ""
+	}
+	return strings.Join([]string{strings.ToLower(subscriptionID), route, clientRequestID}, "|")
+}
+
+// Fingerprint returns the fingerprint of a request from its content, e.g. its query and body, a hash of parts.
+func Fingerprint(parts ...[]byte) string {
+	h := sha256.New()
+	for _, part := range parts {
+		// The length prefix keeps e.g. ("ab", "c") and ("a", "bc") apart.
+		_, _ = h.Write([]byte(strconv.Itoa(len(part)) + ":"))
+		_, _ = h.Write(part)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Retriable returns whether the clients retry the responses of statusCode, in which case they aren't stored.
+func Retriable(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= http.StatusInternalServerError
+}
@@ -0,0 +1,104 @@
+package idempotency_test
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/Azure/aks-middleware/http/common/idempotency"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Idempotency", func() {
+	var (
+		i           *idempotency.Idempotency
+		ctx         context.Context
+		key         string
+		fingerprint string
+	)
+
+	BeforeEach(func() {
+		i = idempotency.New()
+		ctx = context.Background()
+		key = idempotency.Key("client-request-id", "sub1", "PUT /subscriptions/sub1/resourceGroups/rg")
+		fingerprint = idempotency.Fingerprint([]byte(`{"location":"eastus"}`))
+	})
+
+	It("should execute the first request and reject the duplicates while it is in flight", func() {
+		stored, err := i.Begin(ctx, key, fingerprint)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stored).To(BeNil())
+
+		_, err = i.Begin(ctx, key, fingerprint)
+		Expect(err).To(MatchError(idempotency.ErrInFlight))
+	})
+
+	It("should return the stored response to the duplicates", func() {
+		_, err := i.Begin(ctx, key, fingerprint)
+		Expect(err).ToNot(HaveOccurred())
+		resp := &idempotency.Response{StatusCode: http.StatusCreated, Header: http.Header{"Location": {"/ops/1"}}, Body: []byte("{}")}
+		Expect(i.End(ctx, key, fingerprint, resp)).To(Succeed())
+
+		stored, err := i.Begin(ctx, key, fingerprint)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stored.StatusCode).To(Equal(resp.StatusCode))
+		Expect(stored.Header).To(Equal(resp.Header))
+		Expect(stored.Body).To(Equal(resp.Body))
+		Expect(stored.Fingerprint).To(Equal(fingerprint))
+
+		other, err := i.Begin(ctx, idempotency.Key("client-request-id", "sub2", "PUT /subscriptions/sub2/resourceGroups/rg"), fingerprint)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(other).To(BeNil())
+	})
+
+	It("should not return the stored response to a different request with the same key", func() {
+		_, err := i.Begin(ctx, key, fingerprint)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(i.End(ctx, key, fingerprint, &idempotency.Response{StatusCode: http.StatusCreated})).To(Succeed())
+
+		stored, err := i.Begin(ctx, key, idempotency.Fingerprint([]byte(`{"location":"westus"}`)))
+		Expect(err).To(MatchError(idempotency.ErrFingerprintMismatch))
+		Expect(stored).To(BeNil())
+	})
+
+	It("should fingerprint the parts of a request", func() {
+		Expect(idempotency.Fingerprint([]byte("a"), []byte("b"))).To(Equal(idempotency.Fingerprint([]byte("a"), []byte("b"))))
+		Expect(idempotency.Fingerprint([]byte("ab"), []byte("c"))).ToNot(Equal(idempotency.Fingerprint([]byte("a"), []byte("bc"))))
+	})
+
+	It("should execute the request again once cancelled", func() {
+		_, err := i.Begin(ctx, key, fingerprint)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(i.End(ctx, key, fingerprint, nil)).To(Succeed())
+
+		stored, err := i.Begin(ctx, key, fingerprint)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stored).To(BeNil())
+	})
+
+	It("should forget the responses after the TTL", func() {
+		i.TTL = 50 * time.Millisecond
+		_, err := i.Begin(ctx, key, fingerprint)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(i.End(ctx, key, fingerprint, &idempotency.Response{StatusCode: http.StatusOK})).To(Succeed())
+
+		Eventually(func() *idempotency.Response {
+			stored, err := i.Begin(ctx, key, fingerprint)
+			Expect(err).ToNot(HaveOccurred())
+			return stored
+		}).Should(BeNil())
+	})
+
+	It("should not key the requests without a client request id", func() {
+		Expect(idempotency.Key("", "sub1", "PUT /")).To(BeEmpty())
+		Expect(idempotency.Key("id", "SUB1", "PUT /")).To(Equal(idempotency.Key("id", "sub1", "PUT /")))
+	})
+
+	It("should not store the retriable responses", func() {
+		Expect(idempotency.Retriable(http.StatusTooManyRequests)).To(BeTrue())
+		Expect(idempotency.Retriable(http.StatusServiceUnavailable)).To(BeTrue())
+		Expect(idempotency.Retriable(http.StatusConflict)).To(BeFalse())
+		Expect(idempotency.Retriable(http.StatusOK)).To(BeFalse())
+	})
+})
@@ -0,0 +1,167 @@
+package loadshed
+
+// This package sheds load with an adaptive concurrency limit. The limit follows AIMD (additive increase,
+// multiplicative decrease) on the observed latency: it grows by about one for every limit's worth of requests
+// served under the latency threshold while the limit is in use, and is cut by BackoffRatio when a request is
+// slower. Requests arriving with the limit reached are rejected before reaching the handler.
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/Azure/aks-middleware/http/common/metrics"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// ShedLogKey is the log attribute set to true on the ApiRequestLog entry of a shed request.
+const ShedLogKey = "shed"
+
+// Instrument names of RegisterMetrics.
+const (
+	LimitMetricName    = "loadshed.limit"
+	InFlightMetricName = "loadshed.in_flight"
+)
+
+// Options configure a Limiter. Zero values use the defaults.
+type Options struct {
+	// InitialLimit defaults to 100.
+	InitialLimit int
+	// MinLimit defaults to 1.
+	MinLimit int
+	// MaxLimit defaults to 1000.
+	MaxLimit int
+	// LatencyThreshold is the latency above which the limit decreases. Defaults to 1s.
+	LatencyThreshold time.Duration
+	// BackoffRatio multiplies the limit when it decreases, in (0, 1). Defaults to 0.9.
+	BackoffRatio float64
+	// RetryAfter is sent to the rejected callers. Defaults to 1s.
+	RetryAfter time.Duration
+}
+
+// Limiter is an adaptive concurrency limiter.
+type Limiter struct {
+	opts Options
+
+	mu       sync.Mutex
+	limit    float64
+	inFlight int
+}
+
+// NewLimiter returns a Limiter with opts.
+func NewLimiter(opts Options) *Limiter {
+	if opts.MinLimit <= 0 {
+		opts.MinLimit = 1
+	}
+	if opts.MaxLimit <= 0 {
+		opts.MaxLimit = 1000
+	}
+	if opts.InitialLimit <= 0 {
+		opts.InitialLimit = 100
+	}
+	opts.InitialLimit = min(max(opts.InitialLimit, opts.MinLimit), opts.MaxLimit)
+	if opts.LatencyThreshold <= 0 {
+		opts.LatencyThreshold = time.Second
+	}
+	if opts.BackoffRatio <= 0 || opts.BackoffRatio >= 1 {
+		opts.BackoffRatio = 0.9
+	}
+	if opts.RetryAfter <= 0 {
+		opts.RetryAfter = time.Second
+	}
+	return &Limiter{opts: opts, limit: float64(opts.InitialLimit)}
+}
+
+// Acquire admits a request when the limit isn't reached. The returned function must be called
+// once the admitted request finishes, and is nil when the request is rejected.
+func (l *Limiter) Acquire() func() {
+	if !l.admit() {
+		return nil
+	}
+	start := time.Now()
+	return func() {
+		l.release(time.Since(start))
+	}
+}
+
+// AcquireStream is Acquire for long-lived requests such as streams. They count toward the in-flight requests,
+// but their duration isn't a latency signal, so the limit isn't adjusted when they finish.
+func (l *Limiter) AcquireStream() func() {
+	if !l.admit() {
+		return nil
+	}
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.inFlight--
+	}
+}
+
+func (l *Limiter) admit() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inFlight >= int(l.limit) {
+		return false
+	}
+	l.inFlight++
+	return true
+}
+
+func (l *Limiter) release(latency time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// The limit is only increased while it is in use, so that it can't grow unbounded under low traffic.
+	utilized := l.inFlight*2 >= int(l.limit)
+	l.inFlight--
+	switch {
+	case latency > l.opts.LatencyThreshold:
+		l.limit = math.Max(float64(l.opts.MinLimit), l.limit*l.opts.BackoffRatio)
+	case utilized:
+		l.limit = math.Min(float64(l.opts.MaxLimit), l.limit+1/l.limit)
+	}
+}
+
+// Limit returns the current concurrency limit.
+func (l *Limiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit)
+}
+
+// InFlight returns the number of admitted requests that haven't finished.
+func (l *Limiter) InFlight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inFlight
+}
+
+// RetryAfter returns the retry delay sent to the rejected callers.
+func (l *Limiter) RetryAfter() time.Duration {
+	return l.opts.RetryAfter
+}
+
+// RegisterMetrics exports the current limit and in-flight count as gauges of mp.
+func (l *Limiter) RegisterMetrics(mp metric.MeterProvider) error {
+	meter := mp.Meter(metrics.InstrumentationName)
+	limit, err := meter.Int64ObservableGauge(LimitMetricName,
+		metric.WithDescription("Current adaptive concurrency limit."),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return err
+	}
+	inFlight, err := meter.Int64ObservableGauge(InFlightMetricName,
+		metric.WithDescription("Number of admitted requests in flight."),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return err
+	}
+	_, err = meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		o.ObserveInt64(limit, int64(l.Limit()))
+		o.ObserveInt64(inFlight, int64(l.InFlight()))
+		return nil
+	}, limit, inFlight)
+	return err
+}
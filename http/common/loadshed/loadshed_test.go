@@ -0,0 +1,99 @@
+package loadshed_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/Azure/aks-middleware/http/common/loadshed"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+var _ = Describe("Limiter", func() {
+	It("should reject requests over the limit", func() {
+		limiter := loadshed.NewLimiter(loadshed.Options{InitialLimit: 2})
+		first := limiter.Acquire()
+		second := limiter.Acquire()
+		Expect(first).ToNot(BeNil())
+		Expect(second).ToNot(BeNil())
+		Expect(limiter.Acquire()).To(BeNil())
+		Expect(limiter.InFlight()).To(Equal(2))
+
+		first()
+		Expect(limiter.InFlight()).To(Equal(1))
+		Expect(limiter.Acquire()).ToNot(BeNil())
+	})
+
+	It("should decrease the limit on slow requests", func() {
+		limiter := loadshed.NewLimiter(loadshed.Options{InitialLimit: 10, LatencyThreshold: time.Millisecond, BackoffRatio: 0.5})
+		release := limiter.Acquire()
+		time.Sleep(2 * time.Millisecond)
+		release()
+		Expect(limiter.Limit()).To(Equal(5))
+	})
+
+	It("should not adjust the limit when streams finish", func() {
+		limiter := loadshed.NewLimiter(loadshed.Options{InitialLimit: 2, LatencyThreshold: time.Millisecond})
+		release := limiter.AcquireStream()
+		Expect(limiter.InFlight()).To(Equal(1))
+		time.Sleep(2 * time.Millisecond)
+		release()
+		Expect(limiter.InFlight()).To(BeZero())
+		Expect(limiter.Limit()).To(Equal(2))
+
+		first := limiter.AcquireStream()
+		second := limiter.AcquireStream()
+		Expect(first).ToNot(BeNil())
+		Expect(second).ToNot(BeNil())
+		Expect(limiter.AcquireStream()).To(BeNil())
+		Expect(limiter.Acquire()).To(BeNil())
+	})
+
+	It("should not decrease the limit below MinLimit", func() {
+		limiter := loadshed.NewLimiter(loadshed.Options{InitialLimit: 2, MinLimit: 2, LatencyThreshold: time.Millisecond})
+		release := limiter.Acquire()
+		time.Sleep(2 * time.Millisecond)
+		release()
+		Expect(limiter.Limit()).To(Equal(2))
+	})
+
+	It("should increase the limit while it is in use and requests are fast", func() {
+		limiter := loadshed.NewLimiter(loadshed.Options{InitialLimit: 2, MaxLimit: 3})
+		for i := 0; i < 20; i++ {
+			first := limiter.Acquire()
+			second := limiter.Acquire()
+			first()
+			second()
+		}
+		Expect(limiter.Limit()).To(Equal(3))
+
+		limiter = loadshed.NewLimiter(loadshed.Options{InitialLimit: 10})
+		for i := 0; i < 20; i++ {
+			limiter.Acquire()()
+		}
+		Expect(limiter.Limit()).To(Equal(10))
+	})
+
+	It("should export the limit and in-flight count", func() {
+		reader := sdkmetric.NewManualReader()
+		limiter := loadshed.NewLimiter(loadshed.Options{InitialLimit: 5})
+		Expect(limiter.RegisterMetrics(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))).To(Succeed())
+		release := limiter.Acquire()
+		defer release()
+
+		var rm metricdata.ResourceMetrics
+		Expect(reader.Collect(context.Background(), &rm)).To(Succeed())
+		values := map[string]int64{}
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				values[m.Name] = m.Data.(metricdata.Gauge[int64]).DataPoints[0].Value
+			}
+		}
+		Expect(values).To(Equal(map[string]int64{
+			loadshed.LimitMetricName:    5,
+			loadshed.InFlightMetricName: 1,
+		}))
+	})
+})
@@ -9,6 +9,8 @@ import (
     "time"
 
     "github.com/Azure/aks-middleware/http/common"
+    "github.com/Azure/aks-middleware/http/common/sampling"
+    "github.com/Azure/aks-middleware/http/common/scrub"
     "github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
     azcorePolicy "github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 )
@@ -19,6 +21,8 @@ type LogRequestParams struct {
     Request   interface{}
     Response  *http.Response
     Error     error
+    // Sampling samples the records of successful requests when set.
+    Sampling *sampling.Policy
 }
 
 func trimToSubscription(rawURL string) string {
@@ -89,6 +93,8 @@ func TrimURL(parsedURL url.URL) string {
 }
 
 func LogRequest(params LogRequestParams) {
+    // The URL, headers and errors can carry secrets (e.g. SAS tokens in the query string).
+    params.Logger = scrub.Logger(params.Logger)
     var method, service, reqURL string
     switch req := params.Request.(type) {
     case *http.Request:
@@ -130,6 +136,12 @@ func LogRequest(params LogRequestParams) {
         headers = extractHeaders(params.Response.Header)
     }
 
+    failed := params.Error != nil || params.Response == nil || sampling.FailedStatus(params.Response.StatusCode)
+    sampled, rate := params.Sampling.Sample(methodInfo, failed, time.Since(params.StartTime))
+    if !sampled {
+        return
+    }
+
     logEntry := params.Logger.With(
         "source", "ApiRequestLog",
         "protocol", "REST",
@@ -141,6 +153,9 @@ func LogRequest(params LogRequestParams) {
         "url", fullURL,
         "headers", headers,
     )
+    if params.Sampling != nil {
+        logEntry = logEntry.With(sampling.SampleRateLogKey, rate)
+    }
 
     if params.Error != nil || params.Response == nil {
         logEntry.With("error", params.Error.Error(), "code", "na").Error("finished call")
@@ -157,4 +157,24 @@ var _ = Describe("LogRequest", func() {
 			Expect(logBuffer.String()).ToNot(ContainSubstring("GET https://example.com/api/nonResourcePath?param1=value1&api-version=2023-01-01"))
 		})
 	})
+
+	Context("when the URL contains secrets", func() {
+		It("scrubs the logged URL", func() {
+			parsedURL, err := url.Parse("https://account.blob.core.windows.net/container/blob?sv=2021-08-06&sig=c2VjcmV0")
+			Expect(err).To(BeNil())
+
+			params := logging.LogRequestParams{
+				Logger:    logger,
+				StartTime: time.Now(),
+				Request:   &http.Request{Method: "GET", URL: parsedURL},
+				Response:  &http.Response{StatusCode: 200},
+				Error:     nil,
+			}
+
+			logging.LogRequest(params)
+
+			Expect(logBuffer.String()).To(ContainSubstring("sig=[REDACTED]"))
+			Expect(logBuffer.String()).ToNot(ContainSubstring("c2VjcmV0"))
+		})
+	})
 })
@@ -0,0 +1,70 @@
+package loglevel
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// SetRequest is the body of a level change.
+type SetRequest struct {
+	Source Source `json:"source"`
+	Method string `json:"method,omitempty"`
+	// Level is a slog level name, e.g. "DEBUG" or "WARN+2".
+	Level string `json:"level"`
+	// TTL is a time.Duration string, e.g. "15m". Empty for no expiry.
+	TTL string `json:"ttl,omitempty"`
+}
+
+// Apply applies the change to c.
+func (s SetRequest) Apply(ctx context.Context, c *Controller) error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s.Level)); err != nil {
+		return err
+	}
+	var ttl time.Duration
+	if s.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(s.TTL); err != nil {
+			return err
+		}
+	}
+	return c.Set(ctx, s.Source, s.Method, level, ttl)
+}
+
+// NewAdminHandler returns an HTTP handler managing the levels of c. Mount it on an admin-only listener:
+//
+//	GET    lists the settings
+//	PUT    sets a level from a SetRequest JSON body
+//	DELETE resets the level of the "source" and "method" query parameters
+func NewAdminHandler(c *Controller) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			var req SetRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := req.Apply(r.Context(), c); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		case http.MethodDelete:
+			query := r.URL.Query()
+			if err := c.Reset(r.Context(), Source(query.Get("source")), query.Get("method")); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST, DELETE")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(c.Settings())
+	})
+}
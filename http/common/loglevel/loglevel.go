@@ -0,0 +1,285 @@
+package loglevel
+
+// This package changes log levels at runtime. A Controller holds a base level per logger source
+// (ApiRequestLog, CtxLog) backed by a slog.LevelVar, and per-method overrides that can expire.
+// Handlers wrapped with Controller.Handler apply them, and the admin HTTP handler and gRPC service
+// (grpc/server/loglevel) change them without a redeploy.
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Source is the logger source a level applies to.
+type Source string
+
+const (
+	SourceAPIRequestLog Source = "ApiRequestLog"
+	SourceCtxLog        Source = "CtxLog"
+)
+
+// Sources are the sources managed by a Controller.
+var Sources = []Source{SourceAPIRequestLog, SourceCtxLog}
+
+// Setting is the level of a source, or of a method of a source when Method is set.
+type Setting struct {
+	Source Source     `json:"source"`
+	Method string     `json:"method,omitempty"`
+	Level  slog.Level `json:"level"`
+	// ExpiresAt is when the setting reverts. Nil when it doesn't expire.
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+type settingKey struct {
+	source Source
+	method string
+}
+
+type setting struct {
+	Setting
+	timer *time.Timer
+}
+
+// Controller holds the runtime log levels.
+type Controller struct {
+	getLogger func(ctx context.Context) *slog.Logger
+	defaults  map[Source]slog.Level
+	// levels are the base levels, used as the HandlerOptions.Level of the wrapped handlers.
+	levels map[Source]*slog.LevelVar
+	// minLevels are the lowest level of each source over its base level and method overrides.
+	minLevels map[Source]*slog.LevelVar
+
+	mu       sync.RWMutex
+	settings map[settingKey]*setting
+}
+
+// NewController returns a Controller with the Info level for every source.
+// Level changes are logged to the logger returned by getLogger for the context of the change,
+// e.g. ctxlogger.GetLogger for the CtxLog. A nil getLogger uses slog.Default().
+func NewController(getLogger func(ctx context.Context) *slog.Logger) *Controller {
+	if getLogger == nil {
+		getLogger = func(context.Context) *slog.Logger { return slog.Default() }
+	}
+	c := &Controller{
+		getLogger: getLogger,
+		defaults:  map[Source]slog.Level{},
+		levels:    map[Source]*slog.LevelVar{},
+		minLevels: map[Source]*slog.LevelVar{},
+		settings:  map[settingKey]*setting{},
+	}
+	for _, source := range Sources {
+		c.defaults[source] = slog.LevelInfo
+		c.levels[source] = &slog.LevelVar{}
+		c.minLevels[source] = &slog.LevelVar{}
+	}
+	return c
+}
+
+// LevelVar returns the base level of source, for use as slog.HandlerOptions.Level.
+func (c *Controller) LevelVar(source Source) *slog.LevelVar {
+	return c.levels[source]
+}
+
+// Level returns the effective level of method for source.
+func (c *Controller) Level(source Source, method string) slog.Level {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if s, ok := c.settings[settingKey{source, method}]; ok && method != "" {
+		return s.Level
+	}
+	if levelVar, ok := c.levels[source]; ok {
+		return levelVar.Level()
+	}
+	return slog.LevelInfo
+}
+
+// Settings returns the base level of every source followed by the method overrides.
+func (c *Controller) Settings() []Setting {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var settings []Setting
+	for _, source := range Sources {
+		base := Setting{Source: source, Level: c.levels[source].Level()}
+		if s, ok := c.settings[settingKey{source, ""}]; ok {
+			base = s.Setting
+		}
+		settings = append(settings, base)
+	}
+	var overrides []Setting
+	for key, s := range c.settings {
+		if key.method != "" {
+			overrides = append(overrides, s.Setting)
+		}
+	}
+	sort.Slice(overrides, func(i, j int) bool {
+		if overrides[i].Source != overrides[j].Source {
+			return overrides[i].Source < overrides[j].Source
+		}
+		return overrides[i].Method < overrides[j].Method
+	})
+	return append(settings, overrides...)
+}
+
+// Set sets the level of method for source, or the base level of source when method is empty.
+// A positive ttl reverts the setting after ttl.
+func (c *Controller) Set(ctx context.Context, source Source, method string, level slog.Level, ttl time.Duration) error {
+	if _, ok := c.levels[source]; !ok {
+		return fmt.Errorf("unknown log source %q", source)
+	}
+	logger := c.getLogger(ctx)
+
+	c.mu.Lock()
+	key := settingKey{source, method}
+	if old, ok := c.settings[key]; ok && old.timer != nil {
+		old.timer.Stop()
+	}
+	s := &setting{Setting: Setting{Source: source, Method: method, Level: level}}
+	if ttl > 0 {
+		expiresAt := time.Now().Add(ttl)
+		s.ExpiresAt = &expiresAt
+		s.timer = time.AfterFunc(ttl, func() {
+			c.revert(logger, key, s)
+		})
+	}
+	c.settings[key] = s
+	if method == "" {
+		c.levels[source].Set(level)
+	}
+	c.updateMinLevel(source)
+	c.mu.Unlock()
+
+	logger.InfoContext(ctx, "log level changed",
+		"log_source", string(source),
+		"log_method", method,
+		"log_level", level.String(),
+		"ttl", ttl.String(),
+	)
+	return nil
+}
+
+// Reset removes the level of method for source, or resets the base level of source when method is empty.
+func (c *Controller) Reset(ctx context.Context, source Source, method string) error {
+	if _, ok := c.levels[source]; !ok {
+		return fmt.Errorf("unknown log source %q", source)
+	}
+	c.mu.Lock()
+	c.remove(settingKey{source, method})
+	c.mu.Unlock()
+
+	c.getLogger(ctx).InfoContext(ctx, "log level reset",
+		"log_source", string(source),
+		"log_method", method,
+	)
+	return nil
+}
+
+// revert removes s when its TTL expires, unless it was replaced in the meantime.
+func (c *Controller) revert(logger *slog.Logger, key settingKey, s *setting) {
+	c.mu.Lock()
+	if c.settings[key] != s {
+		c.mu.Unlock()
+		return
+	}
+	c.remove(key)
+	c.mu.Unlock()
+
+	logger.Info("log level reverted",
+		"log_source", string(key.source),
+		"log_method", key.method,
+	)
+}
+
+// remove must be called with c.mu held.
+func (c *Controller) remove(key settingKey) {
+	if old, ok := c.settings[key]; ok && old.timer != nil {
+		old.timer.Stop()
+	}
+	delete(c.settings, key)
+	if key.method == "" {
+		c.levels[key.source].Set(c.defaults[key.source])
+	}
+	c.updateMinLevel(key.source)
+}
+
+// updateMinLevel must be called with c.mu held.
+func (c *Controller) updateMinLevel(source Source) {
+	minLevel := c.levels[source].Level()
+	for key, s := range c.settings {
+		if key.source == source && s.Level < minLevel {
+			minLevel = s.Level
+		}
+	}
+	c.minLevels[source].Set(minLevel)
+}
+
+// Handler wraps next so that records are filtered with the levels of source.
+// The method of a record is its "method" attribute, or "/<grpc.service>/<grpc.method>" for the
+// go-grpc-middleware logging fields, so that per-method levels work for the gRPC and HTTP loggers.
+func (c *Controller) Handler(source Source, next slog.Handler) slog.Handler {
+	return &levelHandler{next: next, controller: c, source: source}
+}
+
+type levelHandler struct {
+	next       slog.Handler
+	controller *Controller
+	source     Source
+	attrs      methodAttrs
+}
+
+// methodAttrs are the attributes identifying the method of a record.
+type methodAttrs struct {
+	method, grpcService, grpcMethod string
+}
+
+func (m *methodAttrs) add(a slog.Attr) {
+	switch a.Key {
+	case "method":
+		m.method = a.Value.String()
+	case "grpc.service":
+		m.grpcService = a.Value.String()
+	case "grpc.method":
+		m.grpcMethod = a.Value.String()
+	}
+}
+
+func (m methodAttrs) name() string {
+	if m.grpcService != "" && m.grpcMethod != "" {
+		return "/" + m.grpcService + "/" + m.grpcMethod
+	}
+	return m.method
+}
+
+func (h *levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.controller.minLevels[h.source].Level()
+}
+
+func (h *levelHandler) Handle(ctx context.Context, r slog.Record) error {
+	attrs := h.attrs
+	r.Attrs(func(a slog.Attr) bool {
+		attrs.add(a)
+		return true
+	})
+	if r.Level < h.controller.Level(h.source, attrs.name()) {
+		return nil
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	for _, a := range attrs {
+		clone.attrs.add(a)
+	}
+	clone.next = h.next.WithAttrs(attrs)
+	return &clone
+}
+
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	clone := *h
+	clone.next = h.next.WithGroup(name)
+	return &clone
+}
@@ -0,0 +1,13 @@
+package loglevel_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestLogLevel(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "LogLevel Suite")
+}
@@ -0,0 +1,144 @@
+package loglevel_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/Azure/aks-middleware/http/common/loglevel"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Controller", func() {
+	var (
+		controller *loglevel.Controller
+		changeBuf  *bytes.Buffer
+		logBuf     *bytes.Buffer
+		logger     *slog.Logger
+		ctx        context.Context
+	)
+
+	BeforeEach(func() {
+		changeBuf = &bytes.Buffer{}
+		changeLogger := slog.New(slog.NewJSONHandler(changeBuf, nil))
+		controller = loglevel.NewController(func(context.Context) *slog.Logger { return changeLogger })
+
+		logBuf = &bytes.Buffer{}
+		h := slog.NewJSONHandler(logBuf, &slog.HandlerOptions{Level: controller.LevelVar(loglevel.SourceCtxLog)})
+		logger = slog.New(controller.Handler(loglevel.SourceCtxLog, h))
+		ctx = context.Background()
+	})
+
+	It("should default to the info level", func() {
+		logger.Debug("hidden")
+		logger.Info("shown")
+		Expect(logBuf.String()).ToNot(ContainSubstring("hidden"))
+		Expect(logBuf.String()).To(ContainSubstring("shown"))
+		Expect(controller.Level(loglevel.SourceCtxLog, "")).To(Equal(slog.LevelInfo))
+	})
+
+	It("should change the base level and log the change", func() {
+		Expect(controller.Set(ctx, loglevel.SourceCtxLog, "", slog.LevelWarn, 0)).To(Succeed())
+		logger.Info("hidden")
+		logger.Warn("shown")
+		Expect(logBuf.String()).ToNot(ContainSubstring("hidden"))
+		Expect(logBuf.String()).To(ContainSubstring("shown"))
+		Expect(changeBuf.String()).To(ContainSubstring(`"msg":"log level changed"`))
+		Expect(changeBuf.String()).To(ContainSubstring(`"log_level":"WARN"`))
+
+		Expect(controller.Reset(ctx, loglevel.SourceCtxLog, "")).To(Succeed())
+		Expect(controller.Level(loglevel.SourceCtxLog, "")).To(Equal(slog.LevelInfo))
+		Expect(changeBuf.String()).To(ContainSubstring(`"msg":"log level reset"`))
+	})
+
+	It("should apply method overrides", func() {
+		Expect(controller.Set(ctx, loglevel.SourceCtxLog, "/MyGreeter/SayHello", slog.LevelDebug, 0)).To(Succeed())
+		logger.With("method", "/MyGreeter/SayHello").Debug("debug say hello")
+		logger.With("method", "/MyGreeter/List").Debug("debug list")
+		logger.Debug("debug grpc fields", "grpc.service", "MyGreeter", "grpc.method", "SayHello")
+		Expect(logBuf.String()).To(ContainSubstring("debug say hello"))
+		Expect(logBuf.String()).To(ContainSubstring("debug grpc fields"))
+		Expect(logBuf.String()).ToNot(ContainSubstring("debug list"))
+	})
+
+	It("should list the settings", func() {
+		Expect(controller.Set(ctx, loglevel.SourceAPIRequestLog, "/MyGreeter/SayHello", slog.LevelError, time.Hour)).To(Succeed())
+		settings := controller.Settings()
+		Expect(settings).To(HaveLen(3))
+		Expect(settings[0].Source).To(Equal(loglevel.SourceAPIRequestLog))
+		Expect(settings[1].Source).To(Equal(loglevel.SourceCtxLog))
+		Expect(settings[2].Method).To(Equal("/MyGreeter/SayHello"))
+		Expect(settings[2].Level).To(Equal(slog.LevelError))
+		Expect(settings[0].ExpiresAt).To(BeNil())
+		Expect(settings[2].ExpiresAt).ToNot(BeNil())
+	})
+
+	It("should revert a setting after its TTL", func() {
+		Expect(controller.Set(ctx, loglevel.SourceCtxLog, "", slog.LevelDebug, 10*time.Millisecond)).To(Succeed())
+		Expect(controller.Level(loglevel.SourceCtxLog, "")).To(Equal(slog.LevelDebug))
+		Eventually(func() slog.Level {
+			return controller.Level(loglevel.SourceCtxLog, "")
+		}).Should(Equal(slog.LevelInfo))
+		Eventually(changeBuf.String).Should(ContainSubstring(`"msg":"log level reverted"`))
+	})
+
+	It("should reject unknown sources", func() {
+		Expect(controller.Set(ctx, "Unknown", "", slog.LevelDebug, 0)).ToNot(Succeed())
+		Expect(controller.Reset(ctx, "Unknown", "")).ToNot(Succeed())
+	})
+})
+
+var _ = Describe("Admin handler", func() {
+	var (
+		controller *loglevel.Controller
+		handler    http.Handler
+	)
+
+	BeforeEach(func() {
+		controller = loglevel.NewController(nil)
+		handler = loglevel.NewAdminHandler(controller)
+	})
+
+	serve := func(method, target, body string) (*httptest.ResponseRecorder, []loglevel.Setting) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		var settings []loglevel.Setting
+		if rec.Code == http.StatusOK {
+			Expect(json.Unmarshal(rec.Body.Bytes(), &settings)).To(Succeed())
+		}
+		return rec, settings
+	}
+
+	It("should get, set and reset levels", func() {
+		rec, settings := serve(http.MethodGet, "/loglevels", "")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(settings).To(HaveLen(2))
+		Expect(rec.Body.String()).ToNot(ContainSubstring("expiresAt"))
+
+		rec, settings = serve(http.MethodPut, "/loglevels",
+			`{"source":"ApiRequestLog","method":"/MyGreeter/SayHello","level":"DEBUG","ttl":"5m"}`)
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(settings).To(HaveLen(3))
+		Expect(controller.Level(loglevel.SourceAPIRequestLog, "/MyGreeter/SayHello")).To(Equal(slog.LevelDebug))
+
+		rec, settings = serve(http.MethodDelete, "/loglevels?source=ApiRequestLog&method=/MyGreeter/SayHello", "")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(settings).To(HaveLen(2))
+		Expect(controller.Level(loglevel.SourceAPIRequestLog, "/MyGreeter/SayHello")).To(Equal(slog.LevelInfo))
+	})
+
+	It("should reject invalid requests", func() {
+		rec, _ := serve(http.MethodPut, "/loglevels", `{"source":"ApiRequestLog","level":"LOUD"}`)
+		Expect(rec.Code).To(Equal(http.StatusBadRequest))
+		rec, _ = serve(http.MethodPut, "/loglevels", `{"source":"ApiRequestLog","level":"DEBUG","ttl":"soon"}`)
+		Expect(rec.Code).To(Equal(http.StatusBadRequest))
+		rec, _ = serve(http.MethodPatch, "/loglevels", "")
+		Expect(rec.Code).To(Equal(http.StatusMethodNotAllowed))
+	})
+})
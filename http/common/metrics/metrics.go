@@ -0,0 +1,149 @@
+package metrics
+
+// This package records RED (rate, errors, duration) metrics through an OpenTelemetry MeterProvider.
+// The Recorder is shared by the gRPC interceptors and the HTTP middleware so that both export the same
+// instruments with the same attributes. Export to Prometheus by passing a MeterProvider backed by the
+// OpenTelemetry Prometheus exporter.
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Azure/aks-middleware/http/common/logging"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// InstrumentationName is the name of the meter used by this module.
+const InstrumentationName = "github.com/Azure/aks-middleware"
+
+// Instrument names.
+const (
+	RequestsMetricName         = "requests"
+	RequestDurationMetricName  = "request.duration"
+	RequestsInFlightMetricName = "requests.in_flight"
+)
+
+// Attribute keys. Values are bounded: method is a gRPC full method or a route based ARM operation,
+// never a raw URL.
+const (
+	MethodAttributeKey    = attribute.Key("method")
+	CodeAttributeKey      = attribute.Key("code")
+	ComponentAttributeKey = attribute.Key("component")
+	ProtocolAttributeKey  = attribute.Key("protocol")
+)
+
+const (
+	ComponentClient = "client"
+	ComponentServer = "server"
+	ProtocolGRPC    = "grpc"
+	ProtocolHTTP    = "http"
+)
+
+// Recorder records request counts, latency and in-flight requests.
+type Recorder struct {
+	requests metric.Int64Counter
+	duration metric.Float64Histogram
+	inFlight metric.Int64UpDownCounter
+}
+
+// NewRecorder creates the instruments from mp.
+// Instrument creation errors are reported to the global OpenTelemetry error handler,
+// the SDK still returns usable instruments in that case.
+func NewRecorder(mp metric.MeterProvider) *Recorder {
+	meter := mp.Meter(InstrumentationName)
+	requests, err := meter.Int64Counter(RequestsMetricName,
+		metric.WithDescription("Number of finished requests."),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+	duration, err := meter.Float64Histogram(RequestDurationMetricName,
+		metric.WithDescription("Duration of finished requests."),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+	inFlight, err := meter.Int64UpDownCounter(RequestsInFlightMetricName,
+		metric.WithDescription("Number of requests in flight."),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+	return &Recorder{
+		requests: requests,
+		duration: duration,
+		inFlight: inFlight,
+	}
+}
+
+// Start records a request as in flight. The returned function must be called with the
+// result code once the request finishes.
+func (r *Recorder) Start(ctx context.Context, protocol, component, method string) func(code string) {
+	start := time.Now()
+	base := []attribute.KeyValue{
+		ProtocolAttributeKey.String(protocol),
+		ComponentAttributeKey.String(component),
+		MethodAttributeKey.String(method),
+	}
+	r.inFlight.Add(ctx, 1, metric.WithAttributes(base...))
+	return func(code string) {
+		r.inFlight.Add(ctx, -1, metric.WithAttributes(base...))
+		attrs := metric.WithAttributes(append(base, CodeAttributeKey.String(code))...)
+		r.requests.Add(ctx, 1, attrs)
+		r.duration.Record(ctx, time.Since(start).Seconds(), attrs)
+	}
+}
+
+// NewMetricsMiddleware returns a mux middleware recording RED metrics for every request.
+func NewMetricsMiddleware(mp metric.MeterProvider) mux.MiddlewareFunc {
+	recorder := NewRecorder(mp)
+	return func(next http.Handler) http.Handler {
+		return &metricsMiddleware{
+			next:     next,
+			recorder: recorder,
+		}
+	}
+}
+
+var _ http.Handler = &metricsMiddleware{}
+
+type metricsMiddleware struct {
+	next     http.Handler
+	recorder *Recorder
+}
+
+func (m *metricsMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	customWriter := logging.NewResponseWriter(w)
+	end := m.recorder.Start(r.Context(), ProtocolHTTP, ComponentServer, RouteMethod(r))
+	m.next.ServeHTTP(customWriter, r)
+
+	statusCode := customWriter.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	end(strconv.Itoa(statusCode))
+}
+
+// RouteMethod returns a bounded method label for r.
+// ARM URLs use the GetMethodInfo operation (e.g. "GET resourcetype - READ"). Other URLs fall back to the
+// mux route template, and to "unmatched" when there is none, so that raw URLs never become labels.
+func RouteMethod(r *http.Request) string {
+	methodInfo := logging.GetMethodInfo(r.Method, r.URL.Path)
+	if methodInfo != r.Method+" "+r.URL.Path {
+		return methodInfo
+	}
+	if route := mux.CurrentRoute(r); route != nil {
+		if tmpl, err := route.GetPathTemplate(); err == nil {
+			return r.Method + " " + tmpl
+		}
+	}
+	return r.Method + " unmatched"
+}
@@ -0,0 +1,102 @@
+package metrics_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/Azure/aks-middleware/http/common/metrics"
+	"github.com/gorilla/mux"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+var _ = Describe("HTTP metrics", func() {
+	var (
+		reader *sdkmetric.ManualReader
+		router *mux.Router
+	)
+
+	BeforeEach(func() {
+		reader = sdkmetric.NewManualReader()
+		mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+		router = mux.NewRouter()
+		router.Use(metrics.NewMetricsMiddleware(mp))
+		router.HandleFunc("/subscriptions/{subscriptionID}/resourceGroups/{resourceGroup}/providers/{resourceProvider}/{resourceType}/{resourceName}",
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+			})
+		router.HandleFunc("/healthz/{probe}", func(w http.ResponseWriter, r *http.Request) {})
+	})
+
+	collect := func() metricdata.ResourceMetrics {
+		var rm metricdata.ResourceMetrics
+		Expect(reader.Collect(context.Background(), &rm)).To(Succeed())
+		return rm
+	}
+
+	It("should label ARM requests with the operation and status code", func() {
+		for _, name := range []string{"name1", "name2"} {
+			req := httptest.NewRequest(http.MethodGet, "/subscriptions/sub1/resourceGroups/rg1/providers/Microsoft.Test/resourceType1/"+name, nil)
+			router.ServeHTTP(httptest.NewRecorder(), req)
+		}
+
+		rm := collect()
+		requests := findMetric(rm, metrics.RequestsMetricName).Data.(metricdata.Sum[int64])
+		Expect(requests.DataPoints).To(HaveLen(1))
+		Expect(requests.DataPoints[0].Value).To(Equal(int64(2)))
+		attrs := attributeMap(requests.DataPoints[0].Attributes.ToSlice())
+		Expect(attrs).To(HaveKeyWithValue(metrics.MethodAttributeKey, "GET resourcetype1 - READ"))
+		Expect(attrs).To(HaveKeyWithValue(metrics.CodeAttributeKey, "404"))
+		Expect(attrs).To(HaveKeyWithValue(metrics.ComponentAttributeKey, metrics.ComponentServer))
+		Expect(attrs).To(HaveKeyWithValue(metrics.ProtocolAttributeKey, "http"))
+
+		duration := findMetric(rm, metrics.RequestDurationMetricName).Data.(metricdata.Histogram[float64])
+		Expect(duration.DataPoints).To(HaveLen(1))
+		Expect(duration.DataPoints[0].Count).To(Equal(uint64(2)))
+
+		inFlight := findMetric(rm, metrics.RequestsInFlightMetricName).Data.(metricdata.Sum[int64])
+		Expect(inFlight.DataPoints).To(HaveLen(1))
+		Expect(inFlight.DataPoints[0].Value).To(BeZero())
+	})
+
+	It("should use the route template for other requests", func() {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz/live", nil))
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz/ready", nil))
+
+		requests := findMetric(collect(), metrics.RequestsMetricName).Data.(metricdata.Sum[int64])
+		Expect(requests.DataPoints).To(HaveLen(1))
+		attrs := attributeMap(requests.DataPoints[0].Attributes.ToSlice())
+		Expect(attrs).To(HaveKeyWithValue(metrics.MethodAttributeKey, "GET /healthz/{probe}"))
+		Expect(attrs).To(HaveKeyWithValue(metrics.CodeAttributeKey, "200"))
+	})
+
+	It("should not use raw URLs for unmatched requests", func() {
+		req := httptest.NewRequest(http.MethodGet, "/unknown/path", nil)
+		Expect(metrics.RouteMethod(req)).To(Equal("GET unmatched"))
+	})
+})
+
+func findMetric(rm metricdata.ResourceMetrics, name string) metricdata.Metrics {
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == name {
+				return m
+			}
+		}
+	}
+	Fail("metric " + name + " not found")
+	return metricdata.Metrics{}
+}
+
+func attributeMap(kvs []attribute.KeyValue) map[attribute.Key]string {
+	m := make(map[attribute.Key]string, len(kvs))
+	for _, kv := range kvs {
+		m[kv.Key] = kv.Value.Emit()
+	}
+	return m
+}
@@ -0,0 +1,256 @@
+package panicreport
+
+// This package turns a recovered panic into a structured report shared by the gRPC and HTTP recovery
+// middlewares: the parsed stack frames of the application, the panic site and a fingerprint that is
+// stable across pods running the same build, so identical panics can be grouped.
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// fingerprintFrames is the number of frames from the panic site used to compute the fingerprint.
+const fingerprintFrames = 3
+
+// SkippedPackagePrefixes lists the packages whose frames are not reported: the runtime, the test runners,
+// the gRPC and HTTP servers and the middlewares that sit between the server and the application handler.
+var SkippedPackagePrefixes = []string{
+	"runtime",
+	"testing",
+	"github.com/onsi/ginkgo",
+	"net/http",
+	"google.golang.org/grpc",
+	"github.com/grpc-ecosystem/go-grpc-middleware",
+	"github.com/gorilla/mux",
+	"github.com/Azure/aks-middleware/grpc/common",
+	"github.com/Azure/aks-middleware/grpc/server",
+	"github.com/Azure/aks-middleware/http/common",
+	"github.com/Azure/aks-middleware/http/server",
+}
+
+// Frame is a parsed stack frame.
+type Frame struct {
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	// Module is the go module of the function, or its package when the module isn't in the build info.
+	Module string `json:"module"`
+}
+
+func (f Frame) String() string {
+	return fmt.Sprintf("%s (%s:%d)", f.Function, f.File, f.Line)
+}
+
+// Report describes a recovered panic.
+type Report struct {
+	// Value is the panic value.
+	Value string
+	// Frames are the application frames from the panic site to the outermost caller.
+	Frames []Frame
+	// Fingerprint identifies the panic site and the type of the panic value.
+	Fingerprint string
+}
+
+// Site returns the frame where the panic occurred.
+func (r Report) Site() (Frame, bool) {
+	if len(r.Frames) == 0 {
+		return Frame{}, false
+	}
+	return r.Frames[0], true
+}
+
+// Capture builds the report of p from the current stack. It must be called from the deferred function
+// that recovered p, or from a function it calls, so that the stack still contains the panic.
+func Capture(p any) Report {
+	return New(p, string(debug.Stack()))
+}
+
+// New builds the report of p from stack, in the format of debug.Stack.
+func New(p any, stack string) Report {
+	frames := ParseStack(stack)
+
+	// The frames up to the runtime panic call are the recovery code. Use the outermost panic call so that
+	// the original site is reported when a recovery middleware panics again.
+	start := 0
+	for i, frame := range frames {
+		if frame.Function == "panic" {
+			start = i + 1
+		}
+	}
+	frames = frames[start:]
+
+	appFrames := make([]Frame, 0, len(frames))
+	for _, frame := range frames {
+		if !skipped(frame) {
+			appFrames = append(appFrames, frame)
+		}
+	}
+	// Keep the unfiltered frames when the panic site itself is in a skipped package.
+	if len(appFrames) == 0 {
+		appFrames = frames
+	}
+
+	return Report{
+		Value:       fmt.Sprint(p),
+		Frames:      appFrames,
+		Fingerprint: fingerprint(p, appFrames),
+	}
+}
+
+var fileLineRegexp = regexp.MustCompile(`^(.+\.go):(\d+)(?: \+0x[0-9a-f]+)?$`)
+
+// ParseStack parses a goroutine stack in the format of debug.Stack into frames.
+// Lines that are neither a function nor a file line, like the goroutine header, are ignored.
+func ParseStack(stack string) []Frame {
+	var frames []Frame
+	function := ""
+	for _, line := range strings.Split(stack, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "goroutine ") {
+			continue
+		}
+		if match := fileLineRegexp.FindStringSubmatch(line); match != nil {
+			if function == "" {
+				continue
+			}
+			lineNum, _ := strconv.Atoi(match[2])
+			frames = append(frames, Frame{
+				Function: function,
+				File:     match[1],
+				Line:     lineNum,
+				Module:   moduleOf(function),
+			})
+			function = ""
+			continue
+		}
+		function = functionName(line)
+	}
+	return frames
+}
+
+// functionName strips the arguments and the "created by" prefix of a function line,
+// e.g. "pkg.(*T).M(0x0?, {0xd0a960?, 0xc0007fa270?})" becomes "pkg.(*T).M".
+func functionName(line string) string {
+	if strings.HasPrefix(line, "created by ") {
+		line = strings.TrimPrefix(line, "created by ")
+		if i := strings.Index(line, " in goroutine "); i >= 0 {
+			line = line[:i]
+		}
+		return line
+	}
+	if strings.HasSuffix(line, ")") {
+		if i := strings.LastIndex(line, "("); i > 0 {
+			line = line[:i]
+		}
+	}
+	return line
+}
+
+// packageOf returns the package path of function, e.g. "github.com/a/b" for "github.com/a/b.(*T).M".
+func packageOf(function string) string {
+	lastSlash := strings.LastIndex(function, "/")
+	dot := strings.Index(function[lastSlash+1:], ".")
+	if dot < 0 {
+		return function
+	}
+	return function[:lastSlash+1+dot]
+}
+
+var modulePaths = sync.OnceValue(func() []string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return nil
+	}
+	paths := []string{info.Main.Path}
+	for _, dep := range info.Deps {
+		paths = append(paths, dep.Path)
+	}
+	// Longest first so that nested modules win.
+	sort.Slice(paths, func(i, j int) bool { return len(paths[i]) > len(paths[j]) })
+	return paths
+})
+
+func moduleOf(function string) string {
+	pkg := packageOf(function)
+	for _, path := range modulePaths() {
+		if path != "" && (pkg == path || strings.HasPrefix(pkg, path+"/")) {
+			return path
+		}
+	}
+	return pkg
+}
+
+func skipped(frame Frame) bool {
+	pkg := packageOf(frame.Function)
+	for _, prefix := range SkippedPackagePrefixes {
+		if pkg == prefix || strings.HasPrefix(pkg, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// fingerprint hashes the type of the panic value and the function and line of the innermost frames.
+// File paths are left out since they depend on where the binary was built.
+func fingerprint(p any, frames []Frame) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%T", p)
+	for i, frame := range frames {
+		if i == fingerprintFrames {
+			break
+		}
+		fmt.Fprintf(h, "|%s:%d", frame.Function, frame.Line)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+var counts sync.Map // fingerprint -> *atomic.Int64
+
+// Record increments the in-process counter of the report's fingerprint and returns the new count.
+func Record(r Report) int64 {
+	value, _ := counts.LoadOrStore(r.Fingerprint, &atomic.Int64{})
+	return value.(*atomic.Int64).Add(1)
+}
+
+// Count returns the number of recorded panics with fingerprint.
+func Count(fingerprint string) int64 {
+	value, ok := counts.Load(fingerprint)
+	if !ok {
+		return 0
+	}
+	return value.(*atomic.Int64).Load()
+}
+
+// Counts returns the number of recorded panics per fingerprint.
+func Counts() map[string]int64 {
+	result := map[string]int64{}
+	counts.Range(func(key, value any) bool {
+		result[key.(string)] = value.(*atomic.Int64).Load()
+		return true
+	})
+	return result
+}
+
+type reportKeyType int
+
+const reportKey reportKeyType = iota
+
+// WithReport returns a copy of ctx carrying r, so that custom panic handlers can use it.
+func WithReport(ctx context.Context, r Report) context.Context {
+	return context.WithValue(ctx, reportKey, r)
+}
+
+// FromContext returns the report carried by ctx.
+func FromContext(ctx context.Context) (Report, bool) {
+	r, ok := ctx.Value(reportKey).(Report)
+	return r, ok
+}
@@ -0,0 +1,13 @@
+package panicreport_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestPanicreport(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Panicreport Suite")
+}
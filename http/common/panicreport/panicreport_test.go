@@ -0,0 +1,106 @@
+package panicreport_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/Azure/aks-middleware/http/common/panicreport"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const stack = `goroutine 7 [running]:
+runtime/debug.Stack()
+	/usr/local/go/src/runtime/debug/stack.go:24 +0x5e
+github.com/Azure/aks-middleware/grpc/common.GetRecoveryOptsWithConfig.func1({0xd0a960, 0xc0007fa270}, {0xac4000, 0xd00610})
+	/root/go/pkg/mod/github.com/Azure/aks-middleware@v0.1.16/grpc/common/recoveryOpts.go:38 +0x3e
+panic({0xac4000, 0xd00610})
+	/usr/local/go/src/runtime/panic.go:884 +0x212
+go.goms.io/aks/rp/mygreeterv3/server/internal/server.(*Server).SayHello(0x0?, {0xd0a960?, 0xc0007fa270?}, 0xc00073d1d0)
+	/root/aks-rp/mygreeterv3/server/internal/server/api.go:34 +0x299
+go.goms.io/aks/rp/mygreeterv3/api/v1._MyGreeter_SayHello_Handler.func1({0xd0a960, 0xc0007fa270}, {0xb7b9c0?, 0xc00073d1d0})
+	/root/aks-rp/mygreeterv3/api/v1/api_grpc.pb.go:92 +0x78
+github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/recovery.UnaryServerInterceptor.func1({0xd0a960?, 0xc0007fa270?}, {0xb7b9c0?, 0xc00073d1d0?}, 0x0?, 0xc13a97a20e07f6e5?)
+	/root/go/pkg/mod/github.com/grpc-ecosystem/go-grpc-middleware/v2@v2.0.0/interceptors/recovery/interceptors.go:34 +0xa7
+github.com/Azure/aks-middleware/grpc/server/ctxlogger.UnaryServerInterceptor.func1({0xd0a960, 0xc0007fa150}, {0xb7b9c0, 0xc00073d1d0}, 0xc00070e020?, 0xc0007ee900)
+	/root/go/pkg/mod/github.com/Azure/aks-middleware@v0.1.16/grpc/server/ctxlogger/ctxlogger.go:57 +0xfe
+google.golang.org/grpc.(*Server).processUnaryRPC(0xc0003f4f00, {0xd0fdb8, 0xc00093cea0}, 0xc0004d0000, 0xc000962300, 0x122e880, 0x0)
+	/root/go/pkg/mod/google.golang.org/grpc@v1.58.1/server.go:1376 +0xdf1
+created by google.golang.org/grpc.(*Server).serveStreams.func1 in goroutine 5
+	/root/go/pkg/mod/google.golang.org/grpc@v1.58.1/server.go:996 +0x18c`
+
+var _ = Describe("Panic reports", func() {
+	It("should parse all frames", func() {
+		frames := panicreport.ParseStack(stack)
+		Expect(frames).To(HaveLen(9))
+		Expect(frames[3]).To(Equal(panicreport.Frame{
+			Function: "go.goms.io/aks/rp/mygreeterv3/server/internal/server.(*Server).SayHello",
+			File:     "/root/aks-rp/mygreeterv3/server/internal/server/api.go",
+			Line:     34,
+			Module:   "go.goms.io/aks/rp/mygreeterv3/server/internal/server",
+		}))
+		Expect(frames[8].Function).To(Equal("google.golang.org/grpc.(*Server).serveStreams.func1"))
+	})
+
+	It("should only report the application frames after the panic", func() {
+		report := panicreport.New(errors.New("boom"), stack)
+		Expect(report.Value).To(Equal("boom"))
+		Expect(report.Frames).To(HaveLen(2))
+		site, ok := report.Site()
+		Expect(ok).To(BeTrue())
+		Expect(site.File).To(HaveSuffix("api.go"))
+		Expect(site.Line).To(Equal(34))
+	})
+
+	It("should compute a fingerprint independent of file paths and panic messages", func() {
+		report := panicreport.New(errors.New("boom"), stack)
+		Expect(report.Fingerprint).To(HaveLen(16))
+
+		moved := panicreport.New(errors.New("other message"), strings.ReplaceAll(stack, "/root/aks-rp/", "/src/"))
+		Expect(moved.Fingerprint).To(Equal(report.Fingerprint))
+
+		otherType := panicreport.New("boom", stack)
+		Expect(otherType.Fingerprint).ToNot(Equal(report.Fingerprint))
+
+		otherLine := panicreport.New(errors.New("boom"), strings.ReplaceAll(stack, "api.go:34", "api.go:35"))
+		Expect(otherLine.Fingerprint).ToNot(Equal(report.Fingerprint))
+	})
+
+	It("should not fail on unusual traces", func() {
+		Expect(panicreport.ParseStack("")).To(BeEmpty())
+		report := panicreport.New("boom", "not a stack")
+		_, ok := report.Site()
+		Expect(ok).To(BeFalse())
+	})
+
+	It("should capture the current panic", func() {
+		var report panicreport.Report
+		func() {
+			defer func() {
+				report = panicreport.Capture(recover())
+			}()
+			panic("captured")
+		}()
+		site, ok := report.Site()
+		Expect(ok).To(BeTrue())
+		Expect(site.File).To(HaveSuffix("panicreport_test.go"))
+		Expect(site.Module).To(Equal("github.com/Azure/aks-middleware"))
+	})
+
+	It("should count panics per fingerprint", func() {
+		report := panicreport.New("counted", stack)
+		before := panicreport.Count(report.Fingerprint)
+		Expect(panicreport.Record(report)).To(Equal(before + 1))
+		Expect(panicreport.Record(report)).To(Equal(before + 2))
+		Expect(panicreport.Counts()).To(HaveKeyWithValue(report.Fingerprint, before+2))
+	})
+
+	It("should carry the report in the context", func() {
+		report := panicreport.New("boom", stack)
+		ctx := panicreport.WithReport(context.Background(), report)
+		fromCtx, ok := panicreport.FromContext(ctx)
+		Expect(ok).To(BeTrue())
+		Expect(fromCtx).To(Equal(report))
+	})
+})
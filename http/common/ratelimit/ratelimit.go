@@ -0,0 +1,117 @@
+package ratelimit
+
+// This package rate limits requests with token buckets keyed by a caller dimension, e.g. the subscription.
+// The buckets live in a Store, in memory by default; a shared backend can implement Store so that the
+// instances of a service enforce a single limit.
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit is a token bucket refilled at Rate tokens per second up to Burst tokens.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Result is the outcome of taking a token.
+type Result struct {
+	Allowed bool
+	// Remaining is the number of whole tokens left in the bucket.
+	Remaining int
+	// RetryAfter is the time until a token is available when the request is not allowed.
+	RetryAfter time.Duration
+}
+
+// Store holds the token buckets.
+type Store interface {
+	// Take takes a token from the bucket of key, creating it full with limit when needed.
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// MemoryStore is an in-memory Store. Buckets that are full again are removed periodically.
+type MemoryStore struct {
+	now func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastPrune time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	limit  Limit
+}
+
+// pruneInterval is how often MemoryStore removes its full buckets.
+const pruneInterval = time.Minute
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		now:     time.Now,
+		buckets: map[string]*bucket{},
+	}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Sub(s.lastPrune) > pruneInterval {
+		s.prune(now)
+		s.lastPrune = now
+	}
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), last: now, limit: limit}
+		s.buckets[key] = b
+	}
+	b.refill(now)
+	if b.tokens < 1 {
+		wait := (1 - b.tokens) / limit.Rate
+		return Result{RetryAfter: time.Duration(math.Ceil(wait * float64(time.Second)))}, nil
+	}
+	b.tokens--
+	return Result{Allowed: true, Remaining: int(b.tokens)}, nil
+}
+
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	b.last = now
+	b.tokens = math.Min(float64(b.limit.Burst), b.tokens+elapsed*b.limit.Rate)
+}
+
+// prune must be called with s.mu held.
+func (s *MemoryStore) prune(now time.Time) {
+	for key, b := range s.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.limit.Burst) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// Limiter applies Limit to keys through Store.
+type Limiter struct {
+	Store Store
+	Limit Limit
+}
+
+// New returns a Limiter with limit and an in-memory store.
+func New(limit Limit) *Limiter {
+	return &Limiter{Store: NewMemoryStore(), Limit: limit}
+}
+
+// Take takes a token for key. Requests without a key are not limited.
+func (l *Limiter) Take(ctx context.Context, key string) (Result, error) {
+	if key == "" || l.Limit.Rate <= 0 {
+		return Result{Allowed: true, Remaining: l.Limit.Burst}, nil
+	}
+	return l.Store.Take(ctx, key, l.Limit)
+}
@@ -0,0 +1,56 @@
+package ratelimit_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/Azure/aks-middleware/http/common/ratelimit"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Limiter", func() {
+	var (
+		limiter *ratelimit.Limiter
+		ctx     context.Context
+	)
+
+	BeforeEach(func() {
+		limiter = ratelimit.New(ratelimit.Limit{Rate: 1, Burst: 2})
+		ctx = context.Background()
+	})
+
+	take := func(key string) ratelimit.Result {
+		result, err := limiter.Take(ctx, key)
+		Expect(err).ToNot(HaveOccurred())
+		return result
+	}
+
+	It("should allow the burst and then reject with the time to the next token", func() {
+		Expect(take("sub1")).To(Equal(ratelimit.Result{Allowed: true, Remaining: 1}))
+		Expect(take("sub1")).To(Equal(ratelimit.Result{Allowed: true, Remaining: 0}))
+		result := take("sub1")
+		Expect(result.Allowed).To(BeFalse())
+		Expect(result.RetryAfter).To(BeNumerically("~", time.Second, 50*time.Millisecond))
+	})
+
+	It("should keep separate buckets per key", func() {
+		take("sub1")
+		take("sub1")
+		Expect(take("sub1").Allowed).To(BeFalse())
+		Expect(take("sub2").Allowed).To(BeTrue())
+	})
+
+	It("should refill the bucket over time", func() {
+		limiter.Limit = ratelimit.Limit{Rate: 100, Burst: 1}
+		Expect(take("sub1").Allowed).To(BeTrue())
+		Expect(take("sub1").Allowed).To(BeFalse())
+		Eventually(func() bool { return take("sub1").Allowed }).Should(BeTrue())
+	})
+
+	It("should not limit requests without a key", func() {
+		for i := 0; i < 5; i++ {
+			Expect(take("").Allowed).To(BeTrue())
+		}
+	})
+})
@@ -0,0 +1,15 @@
+package common
+
+import (
+	"math"
+	"time"
+)
+
+// RetryAfterSeconds returns d rounded up to whole seconds, at least 1, for the Retry-After header.
+func RetryAfterSeconds(d time.Duration) int {
+	seconds := int(math.Ceil(d.Seconds()))
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
@@ -0,0 +1,16 @@
+package common_test
+
+import (
+	"time"
+
+	"github.com/Azure/aks-middleware/http/common"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RetryAfterSeconds", func() {
+	It("should round up to whole seconds", func() {
+		Expect(common.RetryAfterSeconds(0)).To(Equal(1))
+		Expect(common.RetryAfterSeconds(1500 * time.Millisecond)).To(Equal(2))
+	})
+})
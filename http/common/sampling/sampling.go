@@ -0,0 +1,63 @@
+package sampling
+
+// This package decides which successful ApiRequestLog "finished call" records are written.
+// Failed and slow calls are always written. Every record written under a policy carries the
+// sample_rate it was sampled at, so counts can be re-weighted downstream (count / sample_rate).
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// SampleRateLogKey is the log attribute holding the rate a record was sampled at.
+const SampleRateLogKey = "sample_rate"
+
+// Policy is a sampling policy for successful calls. A nil *Policy logs every call.
+type Policy struct {
+	// DefaultRate is the fraction of successful calls logged, in (0, 1].
+	// A rate of 0 or less, or above 1, logs every call.
+	DefaultRate float64
+	// MethodRates overrides DefaultRate per method. Methods are the values of the "method" log attribute:
+	// the gRPC full method, e.g. "/pkg.Service/Method", or the logging.GetMethodInfo operation for HTTP,
+	// e.g. "GET resourcetype - LIST".
+	MethodRates map[string]float64
+	// LatencyThreshold logs every call slower than it. 0 disables the threshold.
+	LatencyThreshold time.Duration
+}
+
+// FailedStatus returns whether an HTTP call with statusCode failed, i.e. isn't sampled. Only 2xx succeed,
+// for the server and client logs alike.
+func FailedStatus(statusCode int) bool {
+	return statusCode < http.StatusOK || statusCode >= http.StatusMultipleChoices
+}
+
+// Sample returns whether the call to method should be logged, and the rate to record as SampleRateLogKey.
+// Calls that failed or took longer than LatencyThreshold are always logged with a rate of 1.
+func (p *Policy) Sample(method string, failed bool, latency time.Duration) (bool, float64) {
+	if p.Always(failed, latency) {
+		return true, 1
+	}
+	rate := p.rate(method)
+	if rate == 1 {
+		return true, 1
+	}
+	return rand.Float64() < rate, rate
+}
+
+// Always returns whether a call is logged whatever the sampling, because it failed or took longer than
+// LatencyThreshold. It is true for a nil policy.
+func (p *Policy) Always(failed bool, latency time.Duration) bool {
+	return p == nil || failed || (p.LatencyThreshold > 0 && latency > p.LatencyThreshold)
+}
+
+func (p *Policy) rate(method string) float64 {
+	rate, ok := p.MethodRates[method]
+	if !ok {
+		rate = p.DefaultRate
+	}
+	if rate <= 0 || rate > 1 {
+		return 1
+	}
+	return rate
+}
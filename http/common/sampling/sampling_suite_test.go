@@ -0,0 +1,13 @@
+package sampling_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestSampling(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Sampling Suite")
+}
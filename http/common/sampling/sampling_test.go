@@ -0,0 +1,77 @@
+package sampling_test
+
+import (
+	"time"
+
+	"github.com/Azure/aks-middleware/http/common/sampling"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// neverRate is low enough that a call sampled with it is never logged in practice.
+const neverRate = 1e-12
+
+var _ = Describe("Sampling policy", func() {
+	var policy *sampling.Policy
+
+	BeforeEach(func() {
+		policy = &sampling.Policy{
+			DefaultRate:      neverRate,
+			MethodRates:      map[string]float64{"/MyGreeter/SayHello": 1},
+			LatencyThreshold: time.Second,
+		}
+	})
+
+	It("should log every call without a policy", func() {
+		var nilPolicy *sampling.Policy
+		sampled, rate := nilPolicy.Sample("/MyGreeter/List", false, 0)
+		Expect(sampled).To(BeTrue())
+		Expect(rate).To(Equal(1.0))
+	})
+
+	It("should sample successful calls with the method rate", func() {
+		sampled, rate := policy.Sample("/MyGreeter/List", false, time.Millisecond)
+		Expect(sampled).To(BeFalse())
+		Expect(rate).To(Equal(neverRate))
+
+		sampled, rate = policy.Sample("/MyGreeter/SayHello", false, time.Millisecond)
+		Expect(sampled).To(BeTrue())
+		Expect(rate).To(Equal(1.0))
+	})
+
+	It("should always log failed and slow calls", func() {
+		sampled, rate := policy.Sample("/MyGreeter/List", true, time.Millisecond)
+		Expect(sampled).To(BeTrue())
+		Expect(rate).To(Equal(1.0))
+
+		sampled, rate = policy.Sample("/MyGreeter/List", false, 2*time.Second)
+		Expect(sampled).To(BeTrue())
+		Expect(rate).To(Equal(1.0))
+	})
+
+	It("should only count 2xx HTTP calls as successful", func() {
+		Expect(sampling.FailedStatus(200)).To(BeFalse())
+		Expect(sampling.FailedStatus(204)).To(BeFalse())
+		Expect(sampling.FailedStatus(302)).To(BeTrue())
+		Expect(sampling.FailedStatus(404)).To(BeTrue())
+		Expect(sampling.FailedStatus(503)).To(BeTrue())
+	})
+
+	It("should sample about the configured fraction", func() {
+		policy = &sampling.Policy{DefaultRate: 0.5}
+		logged := 0
+		for i := 0; i < 10000; i++ {
+			if sampled, _ := policy.Sample("GET resourcetype - LIST", false, 0); sampled {
+				logged++
+			}
+		}
+		Expect(logged).To(BeNumerically("~", 5000, 500))
+	})
+
+	It("should treat out of range rates as 1", func() {
+		policy = &sampling.Policy{}
+		sampled, rate := policy.Sample("GET resourcetype - LIST", false, 0)
+		Expect(sampled).To(BeTrue())
+		Expect(rate).To(Equal(1.0))
+	})
+})
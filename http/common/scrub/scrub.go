@@ -0,0 +1,291 @@
+package scrub
+
+// This package removes secrets and PII from logged values. The loggable proto annotation only covers
+// annotated fields, so free-form strings (payloads, URLs, headers, error messages) are matched against
+// detectors before they are written.
+//
+// Every logger created or wrapped by the middlewares of this module goes through Handler, which uses the
+// package default Scrubber. Replace it with SetDefault to add detectors.
+
+import (
+	"context"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+// Redacted replaces the scrubbed values.
+const Redacted = "[REDACTED]"
+
+// Detector finds a secret in a string. Matches are replaced with Replacement, which can reference
+// submatches like regexp.ReplaceAllString, e.g. "${1}[REDACTED]" to keep a prefix.
+// An empty Replacement replaces the whole match with Redacted.
+type Detector struct {
+	Name        string
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+// BuiltinDetectors are the detectors used by New.
+var BuiltinDetectors = []Detector{
+	{
+		Name:        "sas-signature",
+		Pattern:     regexp.MustCompile(`(?i)(\bsig=)[^&\s"';]+`),
+		Replacement: "${1}" + Redacted,
+	},
+	{
+		Name:    "jwt",
+		Pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+	},
+	{
+		// Credentials of an authorization header, e.g. "Authorization: Bearer ..." or "authorization=Basic ...".
+		Name:        "authorization",
+		Pattern:     regexp.MustCompile(`(?i)(\bauthorization["']?\s*[:=]\s*["']?(?:bearer|basic)\s+)[A-Za-z0-9\-._~+/]+=*`),
+		Replacement: "${1}" + Redacted,
+	},
+	{
+		// Token-shaped credentials without a header, so that phrases like "Basic load balancer" are kept.
+		Name:        "authorization-token",
+		Pattern:     regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9\-._~+/]{20,}=*`),
+		Replacement: "${1} " + Redacted,
+	},
+	{
+		Name:        "connection-string",
+		Pattern:     regexp.MustCompile(`(?i)\b(AccountKey|SharedAccessKey|SharedAccessSignature|Password|Pwd)=[^;\s"']+`),
+		Replacement: "${1}=" + Redacted,
+	},
+	{
+		// Only at the start of YAML lines, so that messages like "failed to get token: context deadline
+		// exceeded" keep their cause.
+		Name:        "kubeconfig",
+		Pattern:     regexp.MustCompile(`(?im)^([ \t]*(?:-[ \t]+)?(?:client-key-data|client-certificate-data|token|password):[ \t]+)[^\s"',}]+`),
+		Replacement: "${1}" + Redacted,
+	},
+	{
+		Name:        "url-credentials",
+		Pattern:     regexp.MustCompile(`(://[^:/@\s]+):[^@/\s]+@`),
+		Replacement: "${1}:" + Redacted + "@",
+	},
+	{
+		Name:        "query-secret",
+		Pattern:     regexp.MustCompile(`(?i)([?&](?:access_token|client_secret|code|password|token)=)[^&#\s"']+`),
+		Replacement: "${1}" + Redacted,
+	},
+	{
+		Name:    "email",
+		Pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	},
+}
+
+// SensitiveKeys are the header and field names whose values are always redacted. Keys are compared case-insensitively.
+var SensitiveKeys = []string{
+	"authorization",
+	"proxy-authorization",
+	"x-ms-authorization-auxiliary",
+	"cookie",
+	"set-cookie",
+	"password",
+	"client_secret",
+	"access_token",
+	"refresh_token",
+}
+
+// Scrubber redacts the secrets found by its detectors.
+type Scrubber struct {
+	detectors     []Detector
+	sensitiveKeys map[string]bool
+}
+
+// New returns a Scrubber with the built-in detectors and one detector per user pattern.
+// Matches of the user patterns are replaced with Redacted.
+func New(patterns ...*regexp.Regexp) *Scrubber {
+	detectors := append([]Detector{}, BuiltinDetectors...)
+	for _, p := range patterns {
+		detectors = append(detectors, Detector{Name: p.String(), Pattern: p})
+	}
+	return NewWithDetectors(detectors...)
+}
+
+// NewWithDetectors returns a Scrubber with only the given detectors.
+func NewWithDetectors(detectors ...Detector) *Scrubber {
+	s := &Scrubber{
+		detectors:     detectors,
+		sensitiveKeys: make(map[string]bool, len(SensitiveKeys)),
+	}
+	for _, k := range SensitiveKeys {
+		s.sensitiveKeys[k] = true
+	}
+	return s
+}
+
+var defaultScrubber atomic.Pointer[Scrubber]
+
+func init() {
+	defaultScrubber.Store(New())
+}
+
+// Default returns the Scrubber used by the middlewares.
+func Default() *Scrubber {
+	return defaultScrubber.Load()
+}
+
+// SetDefault replaces the Scrubber used by the middlewares.
+func SetDefault(s *Scrubber) {
+	defaultScrubber.Store(s)
+}
+
+// String returns v with the secrets redacted.
+func (s *Scrubber) String(v string) string {
+	for _, d := range s.detectors {
+		replacement := d.Replacement
+		if replacement == "" {
+			replacement = Redacted
+		}
+		v = d.Pattern.ReplaceAllString(v, replacement)
+	}
+	return v
+}
+
+// IsSensitiveKey returns whether the values of the header or field key are always redacted.
+func (s *Scrubber) IsSensitiveKey(key string) bool {
+	return s.sensitiveKeys[strings.ToLower(key)]
+}
+
+// Headers returns a copy of headers with the sensitive headers and the secrets in the other values redacted.
+func (s *Scrubber) Headers(headers map[string]string) map[string]string {
+	if headers == nil {
+		return nil
+	}
+	scrubbed := make(map[string]string, len(headers))
+	for k, v := range headers {
+		if s.IsSensitiveKey(k) {
+			scrubbed[k] = Redacted
+		} else {
+			scrubbed[k] = s.String(v)
+		}
+	}
+	return scrubbed
+}
+
+// Map returns a copy of m with the sensitive fields and the secrets in the other values redacted.
+func (s *Scrubber) Map(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	scrubbed := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if s.IsSensitiveKey(k) {
+			scrubbed[k] = Redacted
+		} else {
+			scrubbed[k] = s.Value(v)
+		}
+	}
+	return scrubbed
+}
+
+// Value returns v with the secrets redacted. Strings, errors, string maps and slices are scrubbed
+// recursively, other values are returned unchanged.
+func (s *Scrubber) Value(v any) any {
+	switch v := v.(type) {
+	case string:
+		return s.String(v)
+	case error:
+		return s.String(v.Error())
+	case map[string]interface{}:
+		return s.Map(v)
+	case map[string]string:
+		return s.Headers(v)
+	case []interface{}:
+		scrubbed := make([]interface{}, len(v))
+		for i, e := range v {
+			scrubbed[i] = s.Value(e)
+		}
+		return scrubbed
+	case []string:
+		scrubbed := make([]string, len(v))
+		for i, e := range v {
+			scrubbed[i] = s.String(e)
+		}
+		return scrubbed
+	}
+	return v
+}
+
+// Attr returns a with the secrets redacted.
+func (s *Scrubber) Attr(a slog.Attr) slog.Attr {
+	if s.IsSensitiveKey(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+	value := a.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, s.String(value.String()))
+	case slog.KindGroup:
+		attrs := value.Group()
+		scrubbed := make([]slog.Attr, len(attrs))
+		for i, attr := range attrs {
+			scrubbed[i] = s.Attr(attr)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(scrubbed...)}
+	case slog.KindAny:
+		return slog.Any(a.Key, s.Value(value.Any()))
+	}
+	return slog.Attr{Key: a.Key, Value: value}
+}
+
+// Handler wraps next so that the message and the attributes of every record are scrubbed
+// before being written. A nil s uses the Default Scrubber at the time of each record.
+func Handler(next slog.Handler, s *Scrubber) slog.Handler {
+	if h, ok := next.(*scrubHandler); ok && h.scrubber == s {
+		return h
+	}
+	return &scrubHandler{next: next, scrubber: s}
+}
+
+// Logger returns a logger writing to the handler of l wrapped with Handler and the Default Scrubber.
+func Logger(l *slog.Logger) *slog.Logger {
+	if _, ok := l.Handler().(*scrubHandler); ok {
+		return l
+	}
+	return slog.New(Handler(l.Handler(), nil))
+}
+
+type scrubHandler struct {
+	next     slog.Handler
+	scrubber *Scrubber
+}
+
+func (h *scrubHandler) get() *Scrubber {
+	if h.scrubber != nil {
+		return h.scrubber
+	}
+	return Default()
+}
+
+func (h *scrubHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *scrubHandler) Handle(ctx context.Context, r slog.Record) error {
+	s := h.get()
+	scrubbed := slog.NewRecord(r.Time, r.Level, s.String(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		scrubbed.AddAttrs(s.Attr(a))
+		return true
+	})
+	return h.next.Handle(ctx, scrubbed)
+}
+
+func (h *scrubHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	s := h.get()
+	scrubbed := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		scrubbed[i] = s.Attr(a)
+	}
+	return &scrubHandler{next: h.next.WithAttrs(scrubbed), scrubber: h.scrubber}
+}
+
+func (h *scrubHandler) WithGroup(name string) slog.Handler {
+	return &scrubHandler{next: h.next.WithGroup(name), scrubber: h.scrubber}
+}
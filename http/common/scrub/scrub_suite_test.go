@@ -0,0 +1,13 @@
+package scrub_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestScrub(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Scrub Suite")
+}
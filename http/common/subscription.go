@@ -0,0 +1,29 @@
+package common
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// SubscriptionID returns the subscription of an ARM request: its SubscriptionIDKey route variable, or the
+// path segment following "subscriptions" for the requests not routed by mux, e.g. through the gRPC gateway.
+func SubscriptionID(r *http.Request) string {
+	if id := mux.Vars(r)[SubscriptionIDKey]; id != "" {
+		return id
+	}
+	return SubscriptionIDFromPath(r.URL.Path)
+}
+
+// SubscriptionIDFromPath returns the path segment following "subscriptions", compared case-insensitively
+// like ARM does, e.g. "sub1" for "/subscriptions/sub1/resourceGroups/rg".
+func SubscriptionIDFromPath(path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i := 0; i+1 < len(segments); i++ {
+		if strings.EqualFold(segments[i], "subscriptions") {
+			return segments[i+1]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,27 @@
+package common_test
+
+import (
+	"net/http/httptest"
+
+	"github.com/Azure/aks-middleware/http/common"
+	"github.com/gorilla/mux"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SubscriptionID", func() {
+	It("should prefer the route variable", func() {
+		r := mux.SetURLVars(httptest.NewRequest("GET", "/subscriptions/path", nil), map[string]string{common.SubscriptionIDKey: "var"})
+		Expect(common.SubscriptionID(r)).To(Equal("var"))
+	})
+
+	DescribeTable("should fall back to the path",
+		func(path, expected string) {
+			Expect(common.SubscriptionID(httptest.NewRequest("GET", path, nil))).To(Equal(expected))
+		},
+		Entry("ARM path", "/subscriptions/sub1/resourceGroups/rg", "sub1"),
+		Entry("other casing", "/SUBSCRIPTIONS/sub1/resourcegroups/rg", "sub1"),
+		Entry("no subscription", "/v1/hello", ""),
+		Entry("subscriptions collection", "/subscriptions", ""),
+	)
+})
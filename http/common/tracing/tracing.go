@@ -0,0 +1,245 @@
+package tracing
+
+// This package creates OpenTelemetry spans for HTTP servers and HTTP clients (direct and Azure SDK).
+// Tracing is optional: every entry point takes a caller-supplied trace.TracerProvider, so a service
+// can plug in its exporter of choice and tests can use the in-memory span exporter.
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/aks-middleware/http/common"
+	"github.com/Azure/aks-middleware/http/common/logging"
+	"github.com/Azure/aks-middleware/http/common/metrics"
+	azcorePolicy "github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+)
+
+// InstrumentationName is the name of the tracer used by this module.
+const InstrumentationName = "github.com/Azure/aks-middleware"
+
+// Span attribute keys for the ids and ARM resource information the middlewares already log.
+const (
+	RequestIDAttributeKey          = attribute.Key("aks.request_id")
+	CorrelationIDAttributeKey      = attribute.Key("aks.correlation_id")
+	OperationIDAttributeKey        = attribute.Key("aks.operation_id")
+	ARMClientRequestIDAttributeKey = attribute.Key("aks.arm_client_request_id")
+	SubscriptionIDAttributeKey     = attribute.Key("aks.subscription_id")
+	ResourceGroupAttributeKey      = attribute.Key("aks.resource_group")
+)
+
+// Propagator is the propagator used to inject and extract the trace context (W3C traceparent/tracestate).
+var Propagator propagation.TextMapPropagator = propagation.TraceContext{}
+
+// Tracer returns the module's tracer from tp.
+func Tracer(tp trace.TracerProvider) trace.Tracer {
+	return tp.Tracer(InstrumentationName)
+}
+
+// NewTracingMiddleware returns a mux middleware that extracts the trace context from the request headers
+// and starts a server span for every request. Like the metrics labels, the spans are named after the ARM
+// operation, or the mux route template of the other URLs so that their IDs don't end up in span names,
+// see metrics.RouteMethod.
+func NewTracingMiddleware(tp trace.TracerProvider) mux.MiddlewareFunc {
+	tracer := Tracer(tp)
+	return func(next http.Handler) http.Handler {
+		return &tracingMiddleware{
+			next:   next,
+			tracer: tracer,
+		}
+	}
+}
+
+var _ http.Handler = &tracingMiddleware{}
+
+type tracingMiddleware struct {
+	next   http.Handler
+	tracer trace.Tracer
+}
+
+func (t *tracingMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := Propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	attrs := []attribute.KeyValue{
+		attribute.String("http.request.method", r.Method),
+		attribute.String("url.path", r.URL.Path),
+	}
+	if route := mux.CurrentRoute(r); route != nil {
+		if tmpl, err := route.GetPathTemplate(); err == nil {
+			attrs = append(attrs, attribute.String("http.route", tmpl))
+		}
+	}
+	attrs = append(attrs, headerAttributes(r.Header)...)
+	attrs = append(attrs, metadataAttributes(ctx)...)
+	vars := mux.Vars(r)
+	attrs = appendNonEmpty(attrs, SubscriptionIDAttributeKey, vars[common.SubscriptionIDKey])
+	attrs = appendNonEmpty(attrs, ResourceGroupAttributeKey, vars[common.ResourceGroupKey])
+
+	ctx, span := t.tracer.Start(ctx, metrics.RouteMethod(r),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attrs...),
+	)
+	defer span.End()
+
+	customWriter := logging.NewResponseWriter(w)
+	t.next.ServeHTTP(customWriter, r.WithContext(ctx))
+
+	statusCode := customWriter.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	span.SetAttributes(attribute.Int("http.response.status_code", statusCode))
+	if statusCode >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(statusCode))
+	}
+}
+
+// RoundTripper creates a client span for each outbound request and injects the trace context into its headers.
+type RoundTripper struct {
+	Proxied        http.RoundTripper
+	TracerProvider trace.TracerProvider
+}
+
+// NewTracingRoundTripper wraps proxied with a tracing RoundTripper.
+func NewTracingRoundTripper(proxied http.RoundTripper, tp trace.TracerProvider) *RoundTripper {
+	return &RoundTripper{
+		Proxied:        proxied,
+		TracerProvider: tp,
+	}
+}
+
+func (t *RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := startClientSpan(req.Context(), Tracer(t.TracerProvider), req)
+	defer span.End()
+
+	// RoundTrippers must not modify the caller's request.
+	req = req.Clone(ctx)
+	Propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := t.Proxied.RoundTrip(req)
+	endClientSpan(span, resp, err)
+	return resp, err
+}
+
+// Policy is an Azure SDK per-call policy that creates a client span for each outbound request.
+type Policy struct {
+	tracer trace.Tracer
+}
+
+// NewTracingPolicy returns an Azure SDK policy using the tracer from tp.
+func NewTracingPolicy(tp trace.TracerProvider) *Policy {
+	return &Policy{tracer: Tracer(tp)}
+}
+
+func (p *Policy) Do(req *azcorePolicy.Request) (*http.Response, error) {
+	raw := req.Raw()
+	ctx, span := startClientSpan(raw.Context(), p.tracer, raw)
+	defer span.End()
+
+	Propagator.Inject(ctx, propagation.HeaderCarrier(raw.Header))
+	req = req.WithContext(ctx)
+
+	resp, err := req.Next()
+	endClientSpan(span, resp, err)
+	return resp, err
+}
+
+func (p *Policy) Clone() azcorePolicy.Policy {
+	return &Policy{tracer: p.tracer}
+}
+
+func startClientSpan(ctx context.Context, tracer trace.Tracer, req *http.Request) (context.Context, trace.Span) {
+	reqURL := logging.TrimURL(*req.URL)
+	attrs := []attribute.KeyValue{
+		attribute.String("http.request.method", req.Method),
+		attribute.String("server.address", req.URL.Hostname()),
+		attribute.String("url.full", reqURL),
+	}
+	attrs = append(attrs, headerAttributes(req.Header)...)
+	attrs = append(attrs, metadataAttributes(ctx)...)
+	attrs = append(attrs, resourceAttributes(req.URL)...)
+
+	return tracer.Start(ctx, logging.GetMethodInfo(req.Method, reqURL),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+func endClientSpan(span trace.Span, resp *http.Response, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return
+	}
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+}
+
+// headerAttributes returns the span attributes for the ARM id headers present in header.
+func headerAttributes(header http.Header) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	attrs = appendNonEmpty(attrs, CorrelationIDAttributeKey, header.Get(common.RequestCorrelationIDHeader))
+	attrs = appendNonEmpty(attrs, OperationIDAttributeKey, header.Get(common.RequestAcsOperationIDHeader))
+	attrs = appendNonEmpty(attrs, ARMClientRequestIDAttributeKey, header.Get(common.RequestARMClientRequestIDHeader))
+	attrs = appendNonEmpty(attrs, RequestIDAttributeKey, header.Get(common.RequestIDMetadataHeader))
+	return attrs
+}
+
+// MetadataAttributes returns the span attributes for the ids that the requestid middlewares put in the metadata.
+// It is shared with the gRPC tracing interceptors.
+func MetadataAttributes(md metadata.MD) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	for _, m := range []struct {
+		key     string
+		attrKey attribute.Key
+	}{
+		{common.RequestIDMetadataHeader, RequestIDAttributeKey},
+		{common.RequestIDLogKey, RequestIDAttributeKey},
+		{common.CorrelationIDKey, CorrelationIDAttributeKey},
+		{common.OperationIDKey, OperationIDAttributeKey},
+		{common.ARMClientRequestIDKey, ARMClientRequestIDAttributeKey},
+	} {
+		if vals := md.Get(m.key); len(vals) > 0 {
+			attrs = appendNonEmpty(attrs, m.attrKey, vals[0])
+		}
+	}
+	return attrs
+}
+
+func metadataAttributes(ctx context.Context) []attribute.KeyValue {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil
+	}
+	return MetadataAttributes(md)
+}
+
+// resourceAttributes returns the subscription and resource group of an ARM URL.
+func resourceAttributes(u *url.URL) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i := 0; i+1 < len(segments); i++ {
+		switch strings.ToLower(segments[i]) {
+		case "subscriptions":
+			attrs = appendNonEmpty(attrs, SubscriptionIDAttributeKey, segments[i+1])
+		case "resourcegroups":
+			attrs = appendNonEmpty(attrs, ResourceGroupAttributeKey, segments[i+1])
+		}
+	}
+	return attrs
+}
+
+func appendNonEmpty(attrs []attribute.KeyValue, key attribute.Key, value string) []attribute.KeyValue {
+	if value == "" {
+		return attrs
+	}
+	return append(attrs, key.String(value))
+}
@@ -0,0 +1,137 @@
+package tracing_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/Azure/aks-middleware/http/common"
+	"github.com/Azure/aks-middleware/http/common/tracing"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/gorilla/mux"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var _ = Describe("HTTP tracing", func() {
+	var (
+		exporter *tracetest.InMemoryExporter
+		tp       *sdktrace.TracerProvider
+		router   *mux.Router
+		srv      *httptest.Server
+	)
+
+	BeforeEach(func() {
+		exporter = tracetest.NewInMemoryExporter()
+		tp = sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+		router = mux.NewRouter()
+		router.Use(tracing.NewTracingMiddleware(tp))
+		router.HandleFunc("/subscriptions/{subscriptionID}/resourceGroups/{resourceGroup}/providers/{resourceProvider}/{resourceType}/{resourceName}",
+			func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodDelete {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			})
+		srv = httptest.NewServer(router)
+	})
+
+	AfterEach(func() {
+		srv.Close()
+	})
+
+	It("should create a server span named after the ARM operation", func() {
+		req := httptest.NewRequest(http.MethodGet, "/subscriptions/sub1/resourceGroups/rg1/providers/Microsoft.Test/resourceType1/name1?api-version=2021-12-01", nil)
+		req.Header.Set(common.RequestCorrelationIDHeader, "corr-id")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		spans := exporter.GetSpans()
+		Expect(spans).To(HaveLen(1))
+		Expect(spans[0].Name).To(Equal("GET resourcetype1 - READ"))
+		Expect(spans[0].SpanKind).To(Equal(trace.SpanKindServer))
+
+		attrs := attributeMap(spans[0].Attributes)
+		Expect(attrs).To(HaveKeyWithValue(tracing.SubscriptionIDAttributeKey, "sub1"))
+		Expect(attrs).To(HaveKeyWithValue(tracing.ResourceGroupAttributeKey, "rg1"))
+		Expect(attrs).To(HaveKeyWithValue(tracing.CorrelationIDAttributeKey, "corr-id"))
+		Expect(attrs).To(HaveKeyWithValue(attribute.Key("http.response.status_code"), "200"))
+	})
+
+	It("should name the server spans of the other URLs after their route template", func() {
+		router.HandleFunc("/operations/{operationID}", func(w http.ResponseWriter, r *http.Request) {})
+		req := httptest.NewRequest(http.MethodGet, "/operations/op1", nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+
+		spans := exporter.GetSpans()
+		Expect(spans).To(HaveLen(1))
+		Expect(spans[0].Name).To(Equal("GET /operations/{operationID}"))
+	})
+
+	It("should mark server errors on the span", func() {
+		req := httptest.NewRequest(http.MethodDelete, "/subscriptions/sub1/resourceGroups/rg1/providers/Microsoft.Test/resourceType1/name1", nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+
+		spans := exporter.GetSpans()
+		Expect(spans).To(HaveLen(1))
+		Expect(spans[0].Status.Code).To(Equal(otelcodes.Error))
+	})
+
+	It("should propagate the client span to the server", func() {
+		client := &http.Client{Transport: tracing.NewTracingRoundTripper(http.DefaultTransport, tp)}
+		resp, err := client.Get(srv.URL + "/subscriptions/sub2/resourceGroups/rg2/providers/Microsoft.Test/resourceType1/name1?api-version=2021-12-01")
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+
+		spans := exporter.GetSpans()
+		Expect(spans).To(HaveLen(2))
+		serverSpan, clientSpan := spans[0], spans[1]
+		Expect(serverSpan.SpanKind).To(Equal(trace.SpanKindServer))
+		Expect(clientSpan.SpanKind).To(Equal(trace.SpanKindClient))
+		Expect(serverSpan.Parent.SpanID()).To(Equal(clientSpan.SpanContext.SpanID()))
+		Expect(serverSpan.SpanContext.TraceID()).To(Equal(clientSpan.SpanContext.TraceID()))
+
+		attrs := attributeMap(clientSpan.Attributes)
+		Expect(attrs).To(HaveKeyWithValue(tracing.SubscriptionIDAttributeKey, "sub2"))
+		Expect(attrs).To(HaveKeyWithValue(tracing.ResourceGroupAttributeKey, "rg2"))
+	})
+
+	It("should trace Azure SDK requests through the policy", func() {
+		clientOptions := new(policy.ClientOptions)
+		clientOptions.PerCallPolicies = append(clientOptions.PerCallPolicies, tracing.NewTracingPolicy(tp))
+		clientOptions.Retry.MaxRetries = -1
+		pipeline := runtime.NewPipeline("", "", runtime.PipelineOptions{}, clientOptions)
+		req, err := runtime.NewRequest(context.Background(), http.MethodDelete, srv.URL+"/subscriptions/sub3/resourceGroups/rg3/providers/Microsoft.Test/resourceType1/name1")
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = pipeline.Do(req)
+		Expect(err).NotTo(HaveOccurred())
+
+		var clientSpan sdktrace.ReadOnlySpan
+		for _, span := range exporter.GetSpans().Snapshots() {
+			if span.SpanKind() == trace.SpanKindClient {
+				clientSpan = span
+			}
+		}
+		Expect(clientSpan).NotTo(BeNil())
+		Expect(clientSpan.Name()).To(Equal("DELETE resourcetype1"))
+		Expect(clientSpan.Status().Code).To(Equal(otelcodes.Error))
+		Expect(attributeMap(clientSpan.Attributes())).To(HaveKeyWithValue(tracing.SubscriptionIDAttributeKey, "sub3"))
+	})
+})
+
+func attributeMap(kvs []attribute.KeyValue) map[attribute.Key]string {
+	m := make(map[attribute.Key]string, len(kvs))
+	for _, kv := range kvs {
+		m[kv.Key] = kv.Value.Emit()
+	}
+	return m
+}
@@ -0,0 +1,403 @@
+package asyncoperation
+
+// This package turns long running ARM requests into asynchronous operations. Start answers the request with
+// the Azure-AsyncOperation and Location headers, built from the OperationID and Region of the operationrequest
+// BaseOperationRequest, and the handlers implement the operationStatuses and operationResults GET contract.
+// Details can be found here:
+// https://github.com/Azure/azure-resource-manager-rpc/blob/master/v1.0/async-api-reference.md
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/aks-middleware/http/common"
+	"github.com/Azure/aks-middleware/http/common/armerror"
+	"github.com/Azure/aks-middleware/http/server/operationrequest"
+	"github.com/gorilla/mux"
+)
+
+const (
+	AsyncOperationHeader = "Azure-AsyncOperation"
+	LocationHeader       = "Location"
+	RetryAfterHeader     = "Retry-After"
+
+	// Route variables of the operationStatuses and operationResults routes.
+	LocationKey    = "location"
+	OperationIDKey = "operationID"
+)
+
+// DefaultRetryAfter is the polling interval advertised by default.
+const DefaultRetryAfter = 10 * time.Second
+
+// DefaultTTL is how long MemoryStore keeps the completed operations by default.
+const DefaultTTL = 24 * time.Hour
+
+// Status is the status of an operation. The statuses other than InProgress are terminal.
+type Status string
+
+const (
+	StatusInProgress Status = "InProgress"
+	StatusSucceeded  Status = "Succeeded"
+	StatusFailed     Status = "Failed"
+	StatusCanceled   Status = "Canceled"
+)
+
+// Terminal returns whether s is the final status of an operation.
+func (s Status) Terminal() bool {
+	return s == StatusSucceeded || s == StatusFailed || s == StatusCanceled
+}
+
+// OperationStatus is the body of the operationStatuses responses.
+type OperationStatus struct {
+	ID              string          `json:"id"`
+	Name            string          `json:"name"`
+	Status          Status          `json:"status"`
+	StartTime       *time.Time      `json:"startTime,omitempty"`
+	EndTime         *time.Time      `json:"endTime,omitempty"`
+	PercentComplete *float64        `json:"percentComplete,omitempty"`
+	Error           *armerror.Error `json:"error,omitempty"`
+}
+
+// Operation is an operation in a Store.
+type Operation struct {
+	Status OperationStatus
+	// SubscriptionID is the subscription of the request that started the operation.
+	SubscriptionID string
+	// Result is the body of the operationResults response of a succeeded operation.
+	Result json.RawMessage
+	// ErrorStatusCode is the status code of the operationResults response of a failed operation.
+	ErrorStatusCode int
+}
+
+// ErrNotFound is returned by the stores for unknown operations.
+var ErrNotFound = errors.New("operation not found")
+
+// Store holds the operations by operation id.
+type Store interface {
+	// Get returns the operation of id, or ErrNotFound.
+	Get(ctx context.Context, id string) (*Operation, error)
+	// Put creates or replaces the operation of op.Status.Name.
+	Put(ctx context.Context, op *Operation) error
+}
+
+// MemoryStore is an in-memory Store. The operations with a terminal status expire TTL after their last write
+// and are removed periodically; the operations in progress are kept.
+type MemoryStore struct {
+	// TTL defaults to DefaultTTL.
+	TTL time.Duration
+
+	now func() time.Time
+
+	mu         sync.Mutex
+	operations map[string]*memoryOperation
+	lastPrune  time.Time
+}
+
+type memoryOperation struct {
+	Operation
+	// expires is zero for the operations in progress.
+	expires time.Time
+}
+
+// pruneInterval is how often MemoryStore removes its expired operations.
+const pruneInterval = time.Minute
+
+// NewMemoryStore returns an empty MemoryStore keeping the completed operations for DefaultTTL.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		TTL:        DefaultTTL,
+		now:        time.Now,
+		operations: map[string]*memoryOperation{},
+	}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, id string) (*Operation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	op, ok := s.operations[strings.ToLower(id)]
+	if !ok || op.expired(s.now()) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	stored := op.Operation
+	return &stored, nil
+}
+
+func (s *MemoryStore) Put(ctx context.Context, op *Operation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if now.Sub(s.lastPrune) > pruneInterval {
+		s.prune(now)
+		s.lastPrune = now
+	}
+	stored := &memoryOperation{Operation: *op}
+	if op.Status.Status.Terminal() {
+		ttl := s.TTL
+		if ttl <= 0 {
+			ttl = DefaultTTL
+		}
+		stored.expires = now.Add(ttl)
+	}
+	s.operations[strings.ToLower(op.Status.Name)] = stored
+	return nil
+}
+
+// prune must be called with s.mu held.
+func (s *MemoryStore) prune(now time.Time) {
+	for id, op := range s.operations {
+		if op.expired(now) {
+			delete(s.operations, id)
+		}
+	}
+}
+
+func (op *memoryOperation) expired(now time.Time) bool {
+	return !op.expires.IsZero() && !now.Before(op.expires)
+}
+
+// Options configure Operations.
+type Options struct {
+	// ResourceProvider is the namespace of the operation URLs, e.g. "Microsoft.ContainerService". Required.
+	ResourceProvider string
+	// Store defaults to NewMemoryStore().
+	Store Store
+	// RetryAfter is the polling interval of the Retry-After header. Defaults to DefaultRetryAfter.
+	RetryAfter time.Duration
+}
+
+// Operations starts asynchronous operations and serves their status.
+type Operations struct {
+	opts Options
+	now  func() time.Time
+}
+
+// New returns Operations with opts.
+func New(opts Options) *Operations {
+	if opts.Store == nil {
+		opts.Store = NewMemoryStore()
+	}
+	if opts.RetryAfter <= 0 {
+		opts.RetryAfter = DefaultRetryAfter
+	}
+	return &Operations{
+		opts: opts,
+		now:  time.Now,
+	}
+}
+
+// Start records the operation of the request as InProgress and answers it with statusCode, usually 201 for
+// PUT and 202 for DELETE and POST, the Azure-AsyncOperation, Location and Retry-After headers, and body as
+// JSON when it isn't nil. The operation id is the OperationID of the operationrequest middleware, which must
+// be registered before. The returned id is given to the Operations methods updating the operation.
+func (o *Operations) Start(w http.ResponseWriter, r *http.Request, statusCode int, body any) (string, error) {
+	opReq := operationrequest.OperationRequestFromContext(r.Context())
+	if opReq == nil {
+		return "", errors.New("asyncoperation: no operation request in the context")
+	}
+	startTime := o.now().UTC()
+	op := &Operation{
+		Status: OperationStatus{
+			ID:        o.statusPath(opReq.SubscriptionID, opReq.Region, opReq.OperationID),
+			Name:      opReq.OperationID,
+			Status:    StatusInProgress,
+			StartTime: &startTime,
+		},
+		SubscriptionID: opReq.SubscriptionID,
+	}
+	if err := o.opts.Store.Put(r.Context(), op); err != nil {
+		return "", err
+	}
+
+	query := url.Values{common.APIVersionKey: {opReq.APIVersion}}.Encode()
+	base := baseURL(r)
+	w.Header().Set(AsyncOperationHeader, base+op.Status.ID+"?"+query)
+	w.Header().Set(LocationHeader, base+o.resultPath(opReq.SubscriptionID, opReq.Region, opReq.OperationID)+"?"+query)
+	w.Header().Set(RetryAfterHeader, strconv.Itoa(common.RetryAfterSeconds(o.opts.RetryAfter)))
+	if body == nil {
+		w.WriteHeader(statusCode)
+		return opReq.OperationID, nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	return opReq.OperationID, json.NewEncoder(w).Encode(body)
+}
+
+// SetPercentComplete updates the progress of the operation of id.
+func (o *Operations) SetPercentComplete(ctx context.Context, id string, percent float64) error {
+	return o.update(ctx, id, func(op *Operation) {
+		op.Status.PercentComplete = &percent
+	})
+}
+
+// Succeed completes the operation of id. result, when not nil, is the JSON body of the operationResults
+// responses.
+func (o *Operations) Succeed(ctx context.Context, id string, result any) error {
+	var raw json.RawMessage
+	if result != nil {
+		var err error
+		if raw, err = json.Marshal(result); err != nil {
+			return err
+		}
+	}
+	return o.complete(ctx, id, StatusSucceeded, func(op *Operation) {
+		op.Result = raw
+		complete := float64(100)
+		op.Status.PercentComplete = &complete
+	})
+}
+
+// Fail completes the operation of id with e. statusCode is the status code of the operationResults responses.
+func (o *Operations) Fail(ctx context.Context, id string, statusCode int, e armerror.Error) error {
+	return o.complete(ctx, id, StatusFailed, func(op *Operation) {
+		op.Status.Error = &e
+		op.ErrorStatusCode = statusCode
+	})
+}
+
+// Cancel completes the operation of id as canceled.
+func (o *Operations) Cancel(ctx context.Context, id string) error {
+	return o.complete(ctx, id, StatusCanceled, func(op *Operation) {
+		op.Status.Error = &armerror.Error{Code: "OperationCanceled", Message: "The operation was canceled."}
+		op.ErrorStatusCode = http.StatusConflict
+	})
+}
+
+func (o *Operations) complete(ctx context.Context, id string, status Status, f func(op *Operation)) error {
+	return o.update(ctx, id, func(op *Operation) {
+		endTime := o.now().UTC()
+		op.Status.Status = status
+		op.Status.EndTime = &endTime
+		f(op)
+	})
+}
+
+// update isn't atomic: the operations are expected to be updated by the single worker running them.
+func (o *Operations) update(ctx context.Context, id string, f func(op *Operation)) error {
+	op, err := o.opts.Store.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if op.Status.Status.Terminal() {
+		return fmt.Errorf("asyncoperation: operation %s is already %s", id, op.Status.Status)
+	}
+	f(op)
+	return o.opts.Store.Put(ctx, op)
+}
+
+// RegisterRoutes registers the operationStatuses and operationResults GET handlers on router.
+func (o *Operations) RegisterRoutes(router *mux.Router) {
+	router.Handle(o.statusPath("{"+common.SubscriptionIDKey+"}", "{"+LocationKey+"}", "{"+OperationIDKey+"}"),
+		o.StatusHandler()).Methods(http.MethodGet)
+	router.Handle(o.resultPath("{"+common.SubscriptionIDKey+"}", "{"+LocationKey+"}", "{"+OperationIDKey+"}"),
+		o.ResultHandler()).Methods(http.MethodGet)
+}
+
+// StatusHandler returns the operationStatuses handler. It reads the common.SubscriptionIDKey and
+// OperationIDKey route variables, see RegisterRoutes.
+func (o *Operations) StatusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op, ok := o.get(w, r)
+		if !ok {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if !op.Status.Status.Terminal() {
+			w.Header().Set(RetryAfterHeader, strconv.Itoa(common.RetryAfterSeconds(o.opts.RetryAfter)))
+		}
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(op.Status)
+	})
+}
+
+// ResultHandler returns the operationResults handler. It answers 202 with Location and Retry-After while the
+// operation is in progress, then 200 with the result of a succeeded operation (204 without result), or the
+// error of a failed or canceled operation, or an InternalServerError when the store has none.
+func (o *Operations) ResultHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op, ok := o.get(w, r)
+		if !ok {
+			return
+		}
+		switch op.Status.Status {
+		case StatusSucceeded:
+			if len(op.Result) == 0 {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write(op.Result)
+		case StatusFailed, StatusCanceled:
+			statusCode := op.ErrorStatusCode
+			if statusCode == 0 {
+				statusCode = http.StatusInternalServerError
+			}
+			e := armerror.Error{
+				Code:    "InternalServerError",
+				Message: fmt.Sprintf("The operation '%s' %s without error details.", op.Status.Name, strings.ToLower(string(op.Status.Status))),
+			}
+			if op.Status.Error != nil {
+				e = *op.Status.Error
+			}
+			armerror.Write(w, statusCode, e)
+		default:
+			w.Header().Set(LocationHeader, baseURL(r)+r.URL.RequestURI())
+			w.Header().Set(RetryAfterHeader, strconv.Itoa(common.RetryAfterSeconds(o.opts.RetryAfter)))
+			w.WriteHeader(http.StatusAccepted)
+		}
+	})
+}
+
+// get returns the operation of the request, writing a 404 when there is none in the request subscription.
+func (o *Operations) get(w http.ResponseWriter, r *http.Request) (*Operation, bool) {
+	vars := mux.Vars(r)
+	op, err := o.opts.Store.Get(r.Context(), vars[OperationIDKey])
+	if err == nil && !strings.EqualFold(op.SubscriptionID, vars[common.SubscriptionIDKey]) {
+		err = ErrNotFound
+	}
+	if errors.Is(err, ErrNotFound) {
+		armerror.Write(w, http.StatusNotFound, armerror.Error{
+			Code:    "NotFound",
+			Message: fmt.Sprintf("The operation '%s' could not be found.", vars[OperationIDKey]),
+		})
+		return nil, false
+	}
+	if err != nil {
+		armerror.Write(w, http.StatusInternalServerError, armerror.Error{
+			Code:    "InternalServerError",
+			Message: "The operation status could not be retrieved.",
+		})
+		return nil, false
+	}
+	return op, true
+}
+
+func (o *Operations) statusPath(subscriptionID, location, id string) string {
+	return o.operationPath(subscriptionID, location, "operationStatuses", id)
+}
+
+func (o *Operations) resultPath(subscriptionID, location, id string) string {
+	return o.operationPath(subscriptionID, location, "operationResults", id)
+}
+
+func (o *Operations) operationPath(subscriptionID, location, collection, id string) string {
+	return fmt.Sprintf("/subscriptions/%s/providers/%s/locations/%s/%s/%s",
+		subscriptionID, o.opts.ResourceProvider, location, collection, id)
+}
+
+// baseURL returns the scheme and host the client used, taken from the Referer header that ARM sets to
+// the original request URL, or the https URL of the request host.
+func baseURL(r *http.Request) string {
+	if referer, err := url.Parse(r.Header.Get("Referer")); err == nil && referer.Host != "" {
+		return referer.Scheme + "://" + referer.Host
+	}
+	return "https://" + r.Host
+}
@@ -0,0 +1,13 @@
+package asyncoperation
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestAsyncOperation(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "AsyncOperation Suite")
+}
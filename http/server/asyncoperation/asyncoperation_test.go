@@ -0,0 +1,220 @@
+package asyncoperation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/Azure/aks-middleware/http/common"
+	"github.com/Azure/aks-middleware/http/common/armerror"
+	"github.com/Azure/aks-middleware/http/server/operationrequest"
+	"github.com/gorilla/mux"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Operations", func() {
+	const (
+		operationID = "5d3e1f5c-8a57-4c3e-9d2a-2f4c3b9b6c11"
+		statusURL   = "https://management.azure.com/subscriptions/sub1/providers/Microsoft.Test/locations/eastus/operationStatuses/" + operationID + "?api-version=2024-01-01"
+		resultURL   = "https://management.azure.com/subscriptions/sub1/providers/Microsoft.Test/locations/eastus/operationResults/" + operationID + "?api-version=2024-01-01"
+	)
+
+	var (
+		ops    *Operations
+		router *mux.Router
+		now    time.Time
+		ctx    context.Context
+	)
+
+	BeforeEach(func() {
+		now = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		ops = New(Options{ResourceProvider: "Microsoft.Test"})
+		ops.now = func() time.Time { return now }
+		ctx = context.Background()
+
+		router = mux.NewRouter()
+		ops.RegisterRoutes(router)
+		resources := router.PathPrefix("/subscriptions").Subrouter()
+		resources.Use(operationrequest.NewOperationRequest("eastus", operationrequest.OperationRequestOptions{}))
+		routePattern := fmt.Sprintf("/{%s}/resourceGroups/{%s}/providers/{%s}/{%s}/{%s}",
+			common.SubscriptionIDKey, common.ResourceGroupKey, common.ResourceProviderKey, common.ResourceTypeKey, common.ResourceNameKey)
+		resources.HandleFunc(routePattern, func(w http.ResponseWriter, r *http.Request) {
+			_, err := ops.Start(w, r, http.StatusCreated, map[string]string{"provisioningState": "Creating"})
+			Expect(err).ToNot(HaveOccurred())
+		}).Methods(http.MethodPut)
+		resources.HandleFunc(routePattern, func(w http.ResponseWriter, r *http.Request) {
+			_, err := ops.Start(w, r, http.StatusAccepted, nil)
+			Expect(err).ToNot(HaveOccurred())
+		}).Methods(http.MethodDelete)
+	})
+
+	serve := func(method, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set(common.RequestAcsOperationIDHeader, operationID)
+		req.Header.Set("Referer", "https://management.azure.com"+target)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	start := func(method string) *httptest.ResponseRecorder {
+		return serve(method, "/subscriptions/sub1/resourceGroups/rg/providers/Microsoft.Test/widgets/w1?api-version=2024-01-01")
+	}
+
+	getStatus := func(subscription string) (*httptest.ResponseRecorder, OperationStatus) {
+		w := serve(http.MethodGet, "/subscriptions/"+subscription+"/providers/Microsoft.Test/locations/eastus/operationStatuses/"+operationID+"?api-version=2024-01-01")
+		var status OperationStatus
+		if w.Code == http.StatusOK {
+			Expect(json.Unmarshal(w.Body.Bytes(), &status)).To(Succeed())
+		}
+		return w, status
+	}
+
+	getResult := func() *httptest.ResponseRecorder {
+		return serve(http.MethodGet, "/subscriptions/sub1/providers/Microsoft.Test/locations/eastus/operationResults/"+operationID+"?api-version=2024-01-01")
+	}
+
+	It("should answer the request with the async operation headers", func() {
+		w := start(http.MethodPut)
+		Expect(w.Code).To(Equal(http.StatusCreated))
+		Expect(w.Header().Get(AsyncOperationHeader)).To(Equal(statusURL))
+		Expect(w.Header().Get(LocationHeader)).To(Equal(resultURL))
+		Expect(w.Header().Get(RetryAfterHeader)).To(Equal("10"))
+		Expect(w.Body.String()).To(MatchJSON(`{"provisioningState":"Creating"}`))
+
+		w = start(http.MethodDelete)
+		Expect(w.Code).To(Equal(http.StatusAccepted))
+		Expect(w.Header().Get(LocationHeader)).To(Equal(resultURL))
+		Expect(w.Body.Len()).To(BeZero())
+	})
+
+	It("should serve the status of the operation", func() {
+		start(http.MethodPut)
+		w, status := getStatus("sub1")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Header().Get(RetryAfterHeader)).To(Equal("10"))
+		Expect(w.Body.String()).To(MatchJSON(`{
+			"id": "/subscriptions/sub1/providers/Microsoft.Test/locations/eastus/operationStatuses/` + operationID + `",
+			"name": "` + operationID + `",
+			"status": "InProgress",
+			"startTime": "2024-01-02T03:04:05Z"
+		}`))
+
+		Expect(ops.SetPercentComplete(ctx, operationID, 50)).To(Succeed())
+		_, status = getStatus("sub1")
+		Expect(*status.PercentComplete).To(Equal(float64(50)))
+
+		now = now.Add(time.Minute)
+		Expect(ops.Succeed(ctx, operationID, nil)).To(Succeed())
+		w, status = getStatus("sub1")
+		Expect(w.Header().Get(RetryAfterHeader)).To(BeEmpty())
+		Expect(status.Status).To(Equal(StatusSucceeded))
+		Expect(*status.EndTime).To(Equal(now))
+		Expect(*status.PercentComplete).To(Equal(float64(100)))
+	})
+
+	It("should serve the error of a failed operation", func() {
+		start(http.MethodPut)
+		Expect(ops.Fail(ctx, operationID, http.StatusBadRequest, armerror.Error{Code: "InvalidParameter", Message: "bad"})).To(Succeed())
+		_, status := getStatus("sub1")
+		Expect(status.Status).To(Equal(StatusFailed))
+		Expect(status.Error).To(Equal(&armerror.Error{Code: "InvalidParameter", Message: "bad"}))
+
+		w := getResult()
+		Expect(w.Code).To(Equal(http.StatusBadRequest))
+		Expect(w.Body.String()).To(ContainSubstring(`"code":"InvalidParameter"`))
+
+		Expect(ops.Succeed(ctx, operationID, nil)).To(MatchError(ContainSubstring("already Failed")))
+	})
+
+	It("should serve the result of the operation", func() {
+		start(http.MethodPut)
+		w := getResult()
+		Expect(w.Code).To(Equal(http.StatusAccepted))
+		Expect(w.Header().Get(LocationHeader)).To(Equal(resultURL))
+		Expect(w.Header().Get(RetryAfterHeader)).To(Equal("10"))
+
+		Expect(ops.Succeed(ctx, operationID, map[string]string{"provisioningState": "Succeeded"})).To(Succeed())
+		w = getResult()
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Body.String()).To(MatchJSON(`{"provisioningState":"Succeeded"}`))
+	})
+
+	It("should serve no content for operations without result", func() {
+		start(http.MethodDelete)
+		Expect(ops.Succeed(ctx, operationID, nil)).To(Succeed())
+		Expect(getResult().Code).To(Equal(http.StatusNoContent))
+	})
+
+	It("should serve canceled operations as conflicts", func() {
+		start(http.MethodDelete)
+		Expect(ops.Cancel(ctx, operationID)).To(Succeed())
+		_, status := getStatus("sub1")
+		Expect(status.Status).To(Equal(StatusCanceled))
+		Expect(getResult().Code).To(Equal(http.StatusConflict))
+	})
+
+	It("should serve an internal error for failed operations stored without error", func() {
+		Expect(ops.opts.Store.Put(ctx, &Operation{
+			Status:         OperationStatus{Name: operationID, Status: StatusFailed},
+			SubscriptionID: "sub1",
+		})).To(Succeed())
+		w := getResult()
+		Expect(w.Code).To(Equal(http.StatusInternalServerError))
+		Expect(w.Body.String()).To(ContainSubstring(`"code":"InternalServerError"`))
+	})
+
+	It("should expire the completed operations of the memory store", func() {
+		store := ops.opts.Store.(*MemoryStore)
+		store.now = func() time.Time { return now }
+		start(http.MethodPut)
+		now = now.Add(2 * DefaultTTL)
+		w, _ := getStatus("sub1")
+		Expect(w.Code).To(Equal(http.StatusOK))
+
+		Expect(ops.Succeed(ctx, operationID, nil)).To(Succeed())
+		now = now.Add(DefaultTTL - time.Second)
+		w, _ = getStatus("sub1")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		now = now.Add(time.Second)
+		w, _ = getStatus("sub1")
+		Expect(w.Code).To(Equal(http.StatusNotFound))
+
+		Expect(store.Put(ctx, &Operation{Status: OperationStatus{Name: "other"}})).To(Succeed())
+		Expect(store.operations).ToNot(HaveKey(operationID))
+	})
+
+	It("should not find the operations of other subscriptions or unknown operations", func() {
+		w, _ := getStatus("sub1")
+		Expect(w.Code).To(Equal(http.StatusNotFound))
+
+		start(http.MethodPut)
+		w, _ = getStatus("sub2")
+		Expect(w.Code).To(Equal(http.StatusNotFound))
+		Expect(w.Body.String()).To(ContainSubstring(`"code":"NotFound"`))
+		Expect(ops.SetPercentComplete(ctx, "unknown", 10)).To(MatchError(ErrNotFound))
+	})
+
+	It("should fail without operation request", func() {
+		_, err := ops.Start(httptest.NewRecorder(), httptest.NewRequest(http.MethodPut, "/", nil), http.StatusCreated, nil)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should use the request host without Referer", func() {
+		req := httptest.NewRequest(http.MethodPut, "https://rp.example.com/", nil)
+		req = req.WithContext(operationrequest.OperationRequestWithContext(req.Context(), &operationrequest.BaseOperationRequest{
+			SubscriptionID: "sub1",
+			Region:         "eastus",
+			OperationID:    operationID,
+			APIVersion:     "2024-01-01",
+		}))
+		w := httptest.NewRecorder()
+		_, err := ops.Start(w, req, http.StatusAccepted, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(w.Header().Get(AsyncOperationHeader)).To(HavePrefix("https://rp.example.com/subscriptions/sub1/providers/Microsoft.Test/locations/eastus/operationStatuses/"))
+	})
+})
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Azure/aks-middleware/http/common/armerror"
+	"github.com/Azure/aks-middleware/http/common/auth"
+	"github.com/Azure/aks-middleware/http/server/contextlogger"
+	"github.com/Azure/aks-middleware/http/server/logging"
+	"github.com/gorilla/mux"
+)
+
+// NewAuth returns a middleware rejecting requests without a valid bearer token in their Authorization header
+// with a 401 and an ARM AuthenticationFailed error. The principal of the token is put in the request context,
+// see auth.PrincipalFromContext, and added to the context logger and to the request log. Register it after
+// the contextlogger and logging middlewares.
+func NewAuth(validator *auth.Validator) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return &authMiddleware{
+			next:      next,
+			validator: validator,
+		}
+	}
+}
+
+var _ http.Handler = &authMiddleware{}
+
+type authMiddleware struct {
+	next      http.Handler
+	validator *auth.Validator
+}
+
+func (a *authMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.BearerToken(r.Header.Get("Authorization"))
+	if err != nil {
+		unauthorized(w, err)
+		return
+	}
+	principal, err := a.validator.Validate(r.Context(), token)
+	if err != nil {
+		unauthorized(w, err)
+		return
+	}
+	attrs := principal.LogAttrs()
+	logging.AddFields(r.Context(), attrs...)
+	ctx := contextlogger.WithLogger(r.Context(), contextlogger.GetLogger(r.Context()).With(attrs...))
+	a.next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(ctx, principal)))
+}
+
+func unauthorized(w http.ResponseWriter, err error) {
+	challenge := `Bearer error="invalid_token"`
+	if errors.Is(err, auth.ErrMissingToken) {
+		challenge = "Bearer"
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	armerror.Write(w, http.StatusUnauthorized, armerror.Error{
+		Code:    "AuthenticationFailed",
+		Message: "Authentication failed: " + err.Error() + ".",
+	})
+}
@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	"github.com/Azure/aks-middleware/http/common/armerror"
+	"github.com/Azure/aks-middleware/http/common/auth"
+	"github.com/Azure/aks-middleware/http/server/contextlogger"
+	"github.com/Azure/aks-middleware/http/server/logging"
+	testauth "github.com/Azure/aks-middleware/test/auth"
+	"github.com/gorilla/mux"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Auth middleware", func() {
+	var (
+		key       *testauth.Key
+		router    *mux.Router
+		apiOutput *bytes.Buffer
+		ctxOutput *bytes.Buffer
+		principal *auth.Principal
+	)
+
+	BeforeEach(func() {
+		key = testauth.NewECKey("ec-key")
+		jwksPath := filepath.Join(GinkgoT().TempDir(), "jwks.json")
+		Expect(os.WriteFile(jwksPath, testauth.JWKS(key), 0o600)).To(Succeed())
+		validator, err := auth.NewValidator(auth.Options{
+			Issuers:   []string{testauth.Issuer},
+			Audiences: []string{testauth.Audience},
+			Keys:      auth.NewJWKSFromFile(jwksPath, auth.JWKSOptions{}),
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		apiOutput = new(bytes.Buffer)
+		ctxOutput = new(bytes.Buffer)
+		principal = nil
+		router = mux.NewRouter()
+		router.Use(contextlogger.New(*slog.New(slog.NewJSONHandler(ctxOutput, nil)), nil))
+		router.Use(logging.NewLogging(slog.New(slog.NewJSONHandler(apiOutput, nil))))
+		router.Use(NewAuth(validator))
+		router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			principal, _ = auth.PrincipalFromContext(r.Context())
+			contextlogger.GetLogger(r.Context()).Info("handled")
+			w.WriteHeader(http.StatusOK)
+		})
+	})
+
+	serve := func(authorization string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	expectUnauthorized := func(w *httptest.ResponseRecorder, challenge string) {
+		Expect(w.Code).To(Equal(http.StatusUnauthorized))
+		Expect(w.Header().Get("WWW-Authenticate")).To(Equal(challenge))
+		var body armerror.Response
+		Expect(json.Unmarshal(w.Body.Bytes(), &body)).To(Succeed())
+		Expect(body.Error.Code).To(Equal("AuthenticationFailed"))
+		Expect(principal).To(BeNil())
+	}
+
+	It("should reject requests without a token", func() {
+		expectUnauthorized(serve(""), "Bearer")
+		expectUnauthorized(serve("Basic abc"), "Bearer")
+	})
+
+	It("should reject requests with an invalid token", func() {
+		claims := testauth.Claims()
+		claims["iss"] = "https://evil.com/"
+		expectUnauthorized(serve("Bearer "+key.Sign(claims)), `Bearer error="invalid_token"`)
+		expectUnauthorized(serve("Bearer "+testauth.NewECKey("ec-key").Sign(testauth.Claims())), `Bearer error="invalid_token"`)
+	})
+
+	It("should put the principal in the context and the logs", func() {
+		w := serve("Bearer " + key.Sign(testauth.Claims()))
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(principal).ToNot(BeNil())
+		Expect(principal.ObjectID).To(Equal("test-oid"))
+		Expect(principal.AppID).To(Equal("test-appid"))
+
+		for _, output := range []string{apiOutput.String(), ctxOutput.String()} {
+			Expect(output).To(ContainSubstring(`"oid":"test-oid"`))
+			Expect(output).To(ContainSubstring(`"tid":"test-tid"`))
+			Expect(output).To(ContainSubstring(`"appid":"test-appid"`))
+		}
+		Expect(ctxOutput.String()).To(ContainSubstring(`"msg":"handled"`))
+	})
+})
@@ -0,0 +1,61 @@
+package authz
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Azure/aks-middleware/http/common/armerror"
+	"github.com/Azure/aks-middleware/http/common/authz"
+	"github.com/Azure/aks-middleware/http/server/contextlogger"
+	"github.com/gorilla/mux"
+)
+
+// NewAuthz returns a middleware rejecting the requests denied by policy with a 403 and an ARM
+// AuthorizationFailed error. The policy rules are looked up by mux route name, unnamed routes are identified
+// by their HTTP method and path template instead, e.g. "GET /healthz". The deny decisions are logged to
+// the CtxLog with their rule and given to the otelaudit middleware. Register it after the auth middleware,
+// which puts the principal in the context.
+func NewAuthz(policy *authz.Policy) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return &authzMiddleware{
+			next:   next,
+			policy: policy,
+		}
+	}
+}
+
+var _ http.Handler = &authzMiddleware{}
+
+type authzMiddleware struct {
+	next   http.Handler
+	policy *authz.Policy
+}
+
+func (a *authzMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	method := routeMethod(r)
+	decision := a.policy.Authorize(r.Context(), method)
+	if !decision.Allowed {
+		contextlogger.GetLogger(r.Context()).Warn(authz.DeniedLogMessage, decision.LogAttrs()...)
+		armerror.Write(w, http.StatusForbidden, armerror.Error{
+			Code:    "AuthorizationFailed",
+			Message: fmt.Sprintf("The client does not have authorization to perform action '%s'.", method),
+		})
+		return
+	}
+	a.next.ServeHTTP(w, r)
+}
+
+// routeMethod returns the route name of r, or its HTTP method and path template when the route has no name.
+func routeMethod(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return authz.AnyMethod
+	}
+	if name := route.GetName(); name != "" {
+		return name
+	}
+	if tmpl, err := route.GetPathTemplate(); err == nil {
+		return r.Method + " " + tmpl
+	}
+	return authz.AnyMethod
+}
@@ -0,0 +1,83 @@
+package authz
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/Azure/aks-middleware/http/common/armerror"
+	"github.com/Azure/aks-middleware/http/common/auth"
+	"github.com/Azure/aks-middleware/http/common/authz"
+	"github.com/Azure/aks-middleware/http/server/contextlogger"
+	"github.com/gorilla/mux"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Authz middleware", func() {
+	var (
+		router    *mux.Router
+		ctxOutput *bytes.Buffer
+		principal *auth.Principal
+	)
+
+	BeforeEach(func() {
+		policy, err := authz.ParsePolicy([]byte(`{
+			"methods": {
+				"getWidget": [{"name": "readers", "roles": ["Reader"]}],
+				"GET /healthz": [{"name": "anyone"}]
+			}
+		}`))
+		Expect(err).ToNot(HaveOccurred())
+
+		ctxOutput = new(bytes.Buffer)
+		principal = &auth.Principal{ObjectID: "test-oid", Roles: []string{"Reader"}}
+		router = mux.NewRouter()
+		router.Use(contextlogger.New(*slog.New(slog.NewJSONHandler(ctxOutput, nil)), nil))
+		// Stands for the auth middleware.
+		router.Use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+			})
+		})
+		router.Use(NewAuthz(policy))
+		ok := func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}
+		router.HandleFunc("/widgets/{name}", ok).Methods(http.MethodGet).Name("getWidget")
+		router.HandleFunc("/widgets/{name}", ok).Methods(http.MethodPut).Name("putWidget")
+		router.HandleFunc("/healthz", ok).Methods(http.MethodGet)
+	})
+
+	serve := func(method, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		return w
+	}
+
+	It("should allow the requests matching a rule of their route name", func() {
+		Expect(serve(http.MethodGet, "/widgets/w1").Code).To(Equal(http.StatusOK))
+		Expect(ctxOutput.String()).ToNot(ContainSubstring(authz.DeniedLogMessage))
+	})
+
+	It("should reject the other requests with an ARM error and log the decision", func() {
+		w := serve(http.MethodPut, "/widgets/w1")
+		Expect(w.Code).To(Equal(http.StatusForbidden))
+		var body armerror.Response
+		Expect(json.Unmarshal(w.Body.Bytes(), &body)).To(Succeed())
+		Expect(body.Error.Code).To(Equal("AuthorizationFailed"))
+		Expect(body.Error.Message).To(ContainSubstring("putWidget"))
+		Expect(ctxOutput.String()).To(ContainSubstring(`"msg":"` + authz.DeniedLogMessage + `"`))
+		Expect(ctxOutput.String()).To(ContainSubstring(`"method":"putWidget","authz_rule":"implicit-deny"`))
+
+		principal = &auth.Principal{ObjectID: "test-oid", Roles: []string{"Writer"}}
+		Expect(serve(http.MethodGet, "/widgets/w1").Code).To(Equal(http.StatusForbidden))
+	})
+
+	It("should identify the unnamed routes by their path template", func() {
+		principal = nil
+		Expect(serve(http.MethodGet, "/healthz").Code).To(Equal(http.StatusOK))
+	})
+})
@@ -0,0 +1,75 @@
+package bulkhead
+
+import (
+	"net/http"
+
+	"github.com/Azure/aks-middleware/http/common/armerror"
+	"github.com/Azure/aks-middleware/http/common/bulkhead"
+	"github.com/Azure/aks-middleware/http/server/logging"
+	"github.com/Azure/aks-middleware/http/server/operationrequest"
+	"github.com/gorilla/mux"
+)
+
+// ClassFunc returns the bulkhead class of a request.
+type ClassFunc func(r *http.Request) string
+
+// OperationClass classifies requests as bulkhead.ClassRead, ClassList, ClassWrite or ClassDelete.
+func OperationClass(r *http.Request) string {
+	return bulkhead.OperationClass(r.Method, r.URL.Path)
+}
+
+// RouteNameClass classifies requests by the route name of the operationrequest BaseOperationRequest,
+// or of the current mux route when the operationrequest middleware isn't registered before.
+func RouteNameClass(r *http.Request) string {
+	if op := operationrequest.OperationRequestFromContext(r.Context()); op != nil && op.RouteName != "" {
+		return op.RouteName
+	}
+	if route := mux.CurrentRoute(r); route != nil {
+		return route.GetName()
+	}
+	return ""
+}
+
+// NewBulkhead returns a middleware running requests in the pool of their class in b. classify defaults
+// to OperationClass. Requests rejected because the pool and its queue are full, or that waited too long,
+// get a 503 with Retry-After and an ARM ServerBusy error. The class and the time spent in the queue are
+// added to the request log when NewLogging is registered before. It panics when b is nil.
+func NewBulkhead(b *bulkhead.Bulkhead, classify ClassFunc) mux.MiddlewareFunc {
+	if b == nil {
+		panic("bulkhead: NewBulkhead requires a Bulkhead")
+	}
+	if classify == nil {
+		classify = OperationClass
+	}
+	return func(next http.Handler) http.Handler {
+		return &bulkheadMiddleware{
+			next:     next,
+			bulkhead: b,
+			classify: classify,
+		}
+	}
+}
+
+var _ http.Handler = &bulkheadMiddleware{}
+
+type bulkheadMiddleware struct {
+	next     http.Handler
+	bulkhead *bulkhead.Bulkhead
+	classify ClassFunc
+}
+
+func (b *bulkheadMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	class := b.classify(r)
+	release, queued, err := b.bulkhead.Acquire(r.Context(), class)
+	logging.AddFields(r.Context(), bulkhead.ClassLogKey, class, bulkhead.QueuedLogKey, queued.Milliseconds())
+	if err != nil {
+		w.Header().Set("Retry-After", "1")
+		armerror.Write(w, http.StatusServiceUnavailable, armerror.Error{
+			Code:    "ServerBusy",
+			Message: "Too many concurrent " + class + " operations: " + err.Error() + ". Please try again later.",
+		})
+		return
+	}
+	defer release()
+	b.next.ServeHTTP(w, r)
+}
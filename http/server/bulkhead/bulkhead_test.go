@@ -0,0 +1,73 @@
+package bulkhead
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/Azure/aks-middleware/http/common/bulkhead"
+	"github.com/Azure/aks-middleware/http/server/logging"
+	"github.com/gorilla/mux"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Bulkhead middleware", func() {
+	var (
+		router *mux.Router
+		logBuf *bytes.Buffer
+	)
+
+	const path = "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.ContainerService/managedClusters/mc"
+
+	BeforeEach(func() {
+		logBuf = &bytes.Buffer{}
+		router = mux.NewRouter()
+		router.Use(logging.NewLogging(slog.New(slog.NewJSONHandler(logBuf, nil))))
+		router.Use(NewBulkhead(bulkhead.New(map[string]bulkhead.PoolConfig{
+			bulkhead.ClassWrite: {MaxConcurrent: 1},
+		}, bulkhead.PoolConfig{MaxConcurrent: 10}), nil))
+	})
+
+	It("should reject writes over their pool while reads are served", func() {
+		var nested []int
+		router.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPut {
+				return
+			}
+			// Requests arriving while this write is in flight.
+			for _, method := range []string{http.MethodPut, http.MethodGet} {
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+				nested = append(nested, rec.Code)
+			}
+		})
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPut, path, nil))
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(nested).To(Equal([]int{http.StatusServiceUnavailable, http.StatusOK}))
+		Expect(logBuf.String()).To(ContainSubstring(`"bulkhead":"write"`))
+		Expect(logBuf.String()).To(ContainSubstring(`"bulkhead":"read"`))
+		Expect(logBuf.String()).To(ContainSubstring(`"queued_ms":0`))
+	})
+
+	It("should require a Bulkhead", func() {
+		Expect(func() { NewBulkhead(nil, nil) }).To(PanicWith(ContainSubstring("Bulkhead")))
+	})
+
+	It("should classify requests by route name", func() {
+		router.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {}).Name("GetManagedCluster")
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		var class string
+		router.Use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				class = RouteNameClass(r)
+				next.ServeHTTP(w, r)
+			})
+		})
+		router.ServeHTTP(httptest.NewRecorder(), req)
+		Expect(class).To(Equal("GetManagedCluster"))
+	})
+})
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"os"
 
+	"github.com/Azure/aks-middleware/http/common/scrub"
 	opreq "github.com/Azure/aks-middleware/http/server/operationrequest"
 	"github.com/gorilla/mux"
 	"google.golang.org/grpc/metadata"
@@ -49,7 +50,7 @@ func New(logger log.Logger, extractFunction ExtractFunction) mux.MiddlewareFunc
 	return func(next http.Handler) http.Handler {
 		return &contextLogMiddleware{
 			next:            next,
-			logger:          logger,
+			logger:          *scrub.Logger(&logger),
 			extractFunction: extractFunction,
 		}
 	}
@@ -99,6 +100,9 @@ func BuildAttributes(ctx context.Context, r *http.Request, extractFunc func(ctx
 		}
 	}
 
+	headers = scrub.Default().Headers(headers)
+	logAttrs = scrub.Default().Map(logAttrs)
+
 	attrBytes, errMarshalAttrs := json.Marshal(logAttrs)
 	templogger := log.New(log.NewJSONHandler(os.Stdout, nil)).With("source", ctxLogSource)
 	if errMarshalAttrs != nil {
@@ -114,7 +114,7 @@ var _ = Describe("OperationRequest and ContextLogger Integration", func() {
 		url := server.URL + "/subscriptions/sub123/resourceGroups/rg123/providers/Microsoft.Test/resourceType1/resourceName/default?api-version=2021-12-01"
 		req, err := http.NewRequest(http.MethodPost, url, strings.NewReader("payload-data"))
 		Expect(err).NotTo(HaveOccurred())
-		req.Header.Set(common.RequestCorrelationIDHeader, "corr-test")
+		req.Header.Set(common.RequestCorrelationIDHeader, "00000000-0000-0000-0000-00000000c0a1")
 		req.Header.Set(common.RequestAcceptLanguageHeader, "en-us")
 		req.Header.Set("X-Custom-Extra", "extraValue")
 
@@ -134,7 +134,7 @@ var _ = Describe("OperationRequest and ContextLogger Integration", func() {
 		Expect(logInfo["ResourceGroup"]).To(Equal("rg123"))
 		Expect(logInfo["ResourceName"]).To(Equal("resourceName"))
 		Expect(logInfo["APIVersion"]).To(Equal("2021-12-01"))
-		Expect(logInfo["CorrelationID"]).To(Equal("corr-test"))
+		Expect(logInfo["CorrelationID"]).To(Equal("00000000-0000-0000-0000-00000000c0a1"))
 		Expect(logInfo["AcceptedLanguage"]).To(Equal("en-us"))
 		Expect(logInfo["MyCustomHeader"]).To(Equal("extraValue"))
 		Expect(outStr).To(ContainSubstring("integrated log message"))
@@ -0,0 +1,49 @@
+package deadline
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/Azure/aks-middleware/http/common/deadline"
+	"github.com/Azure/aks-middleware/http/common/logging"
+	serverlogging "github.com/Azure/aks-middleware/http/server/logging"
+	"github.com/gorilla/mux"
+)
+
+// NewDeadline returns a middleware applying the timeout of each route to the request context. Routes are
+// matched by their logging.GetMethodInfo operation, e.g. "PUT resourcetype". The timeout is capped by the
+// deadline.BudgetHeader sent by the caller. The request log of the requests that run out of time has
+// whether the budget ran out locally or in a dependency. Register it after NewLogging so that they are
+// added to the request log through logging.AddFields.
+func NewDeadline(timeouts *deadline.Timeouts) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return &deadlineMiddleware{
+			next:     next,
+			timeouts: timeouts,
+		}
+	}
+}
+
+var _ http.Handler = &deadlineMiddleware{}
+
+type deadlineMiddleware struct {
+	next     http.Handler
+	timeouts *deadline.Timeouts
+}
+
+func (d *deadlineMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := d.timeouts.WithTimeout(r.Context(), logging.GetMethodInfo(r.Method, r.URL.Path))
+	defer cancel()
+	if budget, ok := deadline.BudgetFromHeader(r.Header); ok {
+		var budgetCancel context.CancelFunc
+		ctx, budgetCancel = context.WithTimeout(ctx, budget)
+		defer budgetCancel()
+	}
+	r = r.WithContext(ctx)
+	d.next.ServeHTTP(w, r)
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		serverlogging.AddFields(ctx, deadline.LogAttrs(ctx)...)
+	}
+}
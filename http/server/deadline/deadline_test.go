@@ -0,0 +1,87 @@
+package deadline
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/Azure/aks-middleware/http/client/direct/restlogger"
+	"github.com/Azure/aks-middleware/http/common/deadline"
+	"github.com/Azure/aks-middleware/http/server/logging"
+	"github.com/gorilla/mux"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Deadline middleware", func() {
+	var (
+		router    *mux.Router
+		logBuf    *bytes.Buffer
+		remaining time.Duration
+	)
+
+	BeforeEach(func() {
+		logBuf = &bytes.Buffer{}
+		router = mux.NewRouter()
+		router.Use(logging.NewLogging(slog.New(slog.NewJSONHandler(logBuf, nil))))
+		router.Use(NewDeadline(&deadline.Timeouts{
+			Default: time.Minute,
+			Methods: map[string]time.Duration{"GET /slow": 50 * time.Millisecond},
+		}))
+		router.HandleFunc("/fast", func(w http.ResponseWriter, r *http.Request) {
+			remaining, _ = deadline.Remaining(r.Context())
+		})
+		router.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+			w.WriteHeader(http.StatusGatewayTimeout)
+		})
+	})
+
+	It("should apply the default timeout", func() {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fast", nil))
+		Expect(remaining).To(BeNumerically("~", time.Minute, time.Second))
+		Expect(logBuf.String()).ToNot(ContainSubstring("deadline_exceeded"))
+	})
+
+	It("should cap the timeout with the budget sent by the caller", func() {
+		req := httptest.NewRequest(http.MethodGet, "/fast", nil)
+		req.Header.Set(deadline.BudgetHeader, "500")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+		Expect(remaining).To(BeNumerically("<=", 500*time.Millisecond))
+	})
+
+	It("should apply the route timeout and add a local expiry to the request log", func() {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+		Expect(w.Code).To(Equal(http.StatusGatewayTimeout))
+		Expect(logBuf.String()).ToNot(ContainSubstring(`"msg":"deadline exceeded"`))
+		Expect(logBuf.String()).To(MatchRegexp(`"msg":"finished call"[^\n]*"deadline_exceeded":"local"`))
+	})
+
+	It("should add a dependency expiry to the request log", func() {
+		dependency := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		}))
+		defer dependency.Close()
+		client := &http.Client{Transport: &restlogger.LoggingRoundTripper{
+			Proxied: http.DefaultTransport,
+			Logger:  slog.New(slog.NewJSONHandler(&bytes.Buffer{}, nil)),
+			Budget:  &deadline.Budget{SafetyMargin: 10 * time.Millisecond},
+		}}
+		router.HandleFunc("/call", func(w http.ResponseWriter, r *http.Request) {
+			req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, dependency.URL+"/dependency", nil)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = client.Do(req)
+			Expect(err).To(HaveOccurred())
+			<-r.Context().Done()
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/call", nil)
+		req.Header.Set(deadline.BudgetHeader, "100")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+		Expect(logBuf.String()).To(ContainSubstring(`"deadline_exceeded":"dependency"`))
+		Expect(logBuf.String()).To(ContainSubstring(`"deadline_dependency":"GET http://`))
+	})
+})
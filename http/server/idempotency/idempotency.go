@@ -0,0 +1,186 @@
+package idempotency
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/Azure/aks-middleware/http/common"
+	"github.com/Azure/aks-middleware/http/common/armerror"
+	"github.com/Azure/aks-middleware/http/common/idempotency"
+	"github.com/Azure/aks-middleware/http/common/scrub"
+	"github.com/Azure/aks-middleware/http/server/logging"
+	"github.com/Azure/aks-middleware/http/server/operationrequest"
+	"github.com/gorilla/mux"
+)
+
+// Options configure the idempotency middleware.
+type Options struct {
+	// Idempotency defaults to idempotency.New().
+	Idempotency *idempotency.Idempotency
+	// Logger logs store errors. Requests are executed when the store fails. Defaults to slog.Default().
+	Logger *slog.Logger
+	// MaxBodyBytes is the maximum size of the request bodies, which are read to fingerprint the requests, and
+	// of the stored responses. Defaults to operationrequest.DefaultMaxBodyBytes.
+	MaxBodyBytes int64
+}
+
+// NewIdempotency returns a middleware replaying the responses of the PUT, PATCH, POST and DELETE requests
+// retried with the same x-ms-client-request-id. The requests are keyed by client request id, subscription
+// (see common.SubscriptionID) and route, the method and path of the request. Duplicates of a completed
+// request get its stored status, headers and body, and duplicates of a request in flight get a 409. Requests
+// reusing the key of a completed request with a different query or body get a 400, like the gRPC interceptor,
+// and bodies larger than MaxBodyBytes a 413. The responses of retriable failures (429 and 5xx) and those
+// larger than MaxBodyBytes aren't stored, so that the retries are executed.
+func NewIdempotency(opts Options) mux.MiddlewareFunc {
+	if opts.Idempotency == nil {
+		opts.Idempotency = idempotency.New()
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	opts.Logger = scrub.Logger(opts.Logger)
+	if opts.MaxBodyBytes <= 0 {
+		opts.MaxBodyBytes = operationrequest.DefaultMaxBodyBytes
+	}
+	return func(next http.Handler) http.Handler {
+		return &idempotencyMiddleware{
+			next: next,
+			opts: opts,
+		}
+	}
+}
+
+var _ http.Handler = &idempotencyMiddleware{}
+
+type idempotencyMiddleware struct {
+	next http.Handler
+	opts Options
+}
+
+func (m *idempotencyMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	clientRequestID := r.Header.Get(common.RequestARMClientRequestIDHeader)
+	key := idempotency.Key(clientRequestID, common.SubscriptionID(r), r.Method+" "+r.URL.Path)
+	if key == "" || !isWrite(r.Method) {
+		m.next.ServeHTTP(w, r)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, m.opts.MaxBodyBytes))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		armerror.Write(w, http.StatusRequestEntityTooLarge, armerror.Error{
+			Code:    "RequestEntityTooLarge",
+			Message: fmt.Sprintf("The request content exceeds the limit of %d bytes.", m.opts.MaxBodyBytes),
+		})
+		return
+	}
+	if err != nil {
+		armerror.Write(w, http.StatusBadRequest, armerror.Error{
+			Code:    "InvalidRequestContent",
+			Message: "The request content can't be read.",
+		})
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	fingerprint := idempotency.Fingerprint([]byte(r.URL.RawQuery), body)
+
+	stored, err := m.opts.Idempotency.Begin(r.Context(), key, fingerprint)
+	switch {
+	case errors.Is(err, idempotency.ErrInFlight):
+		armerror.Write(w, http.StatusConflict, armerror.Error{
+			Code:    "Conflict",
+			Message: fmt.Sprintf("A request with %s '%s' is already in progress. Please retry after it completes.", common.RequestARMClientRequestIDHeader, clientRequestID),
+		})
+		return
+	case errors.Is(err, idempotency.ErrFingerprintMismatch):
+		armerror.Write(w, http.StatusBadRequest, armerror.Error{
+			Code:    idempotency.CodeClientRequestIDReused,
+			Message: fmt.Sprintf("A different request with %s '%s' was completed. Please use a new %s.", common.RequestARMClientRequestIDHeader, clientRequestID, common.RequestARMClientRequestIDHeader),
+		})
+		return
+	case err != nil:
+		m.opts.Logger.WarnContext(r.Context(), "idempotency store failed, executing request", "error", err)
+		m.next.ServeHTTP(w, r)
+		return
+	case stored != nil:
+		logging.AddFields(r.Context(), idempotency.ReplayLogKey, true)
+		replay(w, stored)
+		return
+	}
+
+	recorder := &responseRecorder{ResponseWriter: w, limit: m.opts.MaxBodyBytes}
+	var resp *idempotency.Response
+	defer func() {
+		// resp stays nil when the handler panics, which cancels the key.
+		if err := m.opts.Idempotency.End(r.Context(), key, fingerprint, resp); err != nil {
+			m.opts.Logger.WarnContext(r.Context(), "idempotency store failed, response not stored", "error", err)
+		}
+	}()
+	m.next.ServeHTTP(recorder, r)
+	statusCode := recorder.statusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	if !idempotency.Retriable(statusCode) && !recorder.overflow {
+		resp = &idempotency.Response{
+			StatusCode: statusCode,
+			Header:     recorder.header,
+			Body:       recorder.body.Bytes(),
+		}
+		if resp.Header == nil {
+			resp.Header = w.Header().Clone()
+		}
+	}
+}
+
+func replay(w http.ResponseWriter, resp *idempotency.Response) {
+	for name, values := range resp.Header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(resp.StatusCode)
+	_, _ = w.Write(resp.Body)
+}
+
+func isWrite(method string) bool {
+	switch method {
+	case http.MethodPut, http.MethodPatch, http.MethodPost, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// responseRecorder keeps a copy of the response written to ResponseWriter, unless its body exceeds limit.
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	header     http.Header
+	body       bytes.Buffer
+	limit      int64
+	overflow   bool
+}
+
+func (r *responseRecorder) WriteHeader(code int) {
+	if r.statusCode == 0 {
+		r.statusCode = code
+		r.header = r.ResponseWriter.Header().Clone()
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.statusCode == 0 {
+		r.WriteHeader(http.StatusOK)
+	}
+	if !r.overflow && int64(r.body.Len()+len(b)) > r.limit {
+		r.overflow = true
+		r.body = bytes.Buffer{}
+	}
+	if !r.overflow {
+		r.body.Write(b)
+	}
+	return r.ResponseWriter.Write(b)
+}
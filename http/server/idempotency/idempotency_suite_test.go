@@ -0,0 +1,13 @@
+package idempotency
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestIdempotency(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Idempotency Suite")
+}
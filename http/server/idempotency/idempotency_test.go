@@ -0,0 +1,153 @@
+package idempotency
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/Azure/aks-middleware/http/common"
+	"github.com/Azure/aks-middleware/http/server/logging"
+	"github.com/gorilla/mux"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Idempotency middleware", func() {
+	var (
+		router  *mux.Router
+		logBuf  *bytes.Buffer
+		calls   atomic.Int32
+		status  int
+		release chan struct{}
+		body    string
+	)
+
+	const route = "/subscriptions/{" + common.SubscriptionIDKey + "}/resourceGroups/{" + common.ResourceGroupKey + "}"
+
+	BeforeEach(func() {
+		logBuf = new(bytes.Buffer)
+		calls.Store(0)
+		status = http.StatusCreated
+		release = nil
+		body = `{"location":"eastus"}`
+		router = mux.NewRouter()
+		router.Use(logging.NewLogging(slog.New(slog.NewJSONHandler(logBuf, nil))))
+		router.Use(NewIdempotency(Options{}))
+		router.HandleFunc(route, func(w http.ResponseWriter, r *http.Request) {
+			Expect(io.ReadAll(r.Body)).To(BeEquivalentTo(body))
+			n := calls.Add(1)
+			if release != nil {
+				<-release
+			}
+			w.Header().Set("x-call", strconv.Itoa(int(n)))
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(`{"name":"rg"}`))
+		})
+	})
+
+	serve := func(method, subscription, clientRequestID string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, "/subscriptions/"+subscription+"/resourceGroups/rg", strings.NewReader(body))
+		if clientRequestID != "" {
+			req.Header.Set(common.RequestARMClientRequestIDHeader, clientRequestID)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	It("should replay the response of a completed request", func() {
+		first := serve(http.MethodPut, "sub1", "id-1")
+		Expect(first.Code).To(Equal(http.StatusCreated))
+
+		second := serve(http.MethodPut, "sub1", "id-1")
+		Expect(second.Code).To(Equal(http.StatusCreated))
+		Expect(second.Header().Get("x-call")).To(Equal("1"))
+		Expect(second.Body.String()).To(Equal(`{"name":"rg"}`))
+		Expect(calls.Load()).To(BeEquivalentTo(1))
+		Expect(logBuf.String()).To(ContainSubstring(`"idempotent_replay":true`))
+	})
+
+	It("should return a 400 to a different request reusing the key of a completed request", func() {
+		Expect(serve(http.MethodPut, "sub1", "id-1").Code).To(Equal(http.StatusCreated))
+
+		body = `{"location":"westus"}`
+		reused := serve(http.MethodPut, "sub1", "id-1")
+		Expect(reused.Code).To(Equal(http.StatusBadRequest))
+		Expect(reused.Body.String()).To(ContainSubstring(`"code":"ClientRequestIdReused"`))
+		Expect(calls.Load()).To(BeEquivalentTo(1))
+	})
+
+	It("should return a 413 to bodies larger than MaxBodyBytes", func() {
+		router = mux.NewRouter()
+		router.Use(NewIdempotency(Options{MaxBodyBytes: 8}))
+		router.HandleFunc(route, func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+		})
+
+		w := serve(http.MethodPut, "sub1", "id-1")
+		Expect(w.Code).To(Equal(http.StatusRequestEntityTooLarge))
+		Expect(w.Body.String()).To(ContainSubstring(`"code":"RequestEntityTooLarge"`))
+		Expect(calls.Load()).To(BeEquivalentTo(0))
+	})
+
+	It("should not store responses larger than MaxBodyBytes", func() {
+		router = mux.NewRouter()
+		router.Use(NewIdempotency(Options{MaxBodyBytes: int64(len(body))}))
+		router.HandleFunc(route, func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			_, _ = w.Write([]byte(strings.Repeat("x", len(body)+1)))
+		})
+
+		Expect(serve(http.MethodPut, "sub1", "id-1").Body.Len()).To(Equal(len(body) + 1))
+		Expect(serve(http.MethodPut, "sub1", "id-1").Body.Len()).To(Equal(len(body) + 1))
+		Expect(calls.Load()).To(BeEquivalentTo(2))
+	})
+
+	It("should execute the requests with another key", func() {
+		serve(http.MethodPut, "sub1", "id-1")
+		serve(http.MethodPut, "sub1", "id-2")
+		serve(http.MethodPut, "sub2", "id-1")
+		serve(http.MethodPost, "sub1", "id-1")
+		serve(http.MethodPut, "sub1", "")
+		serve(http.MethodPut, "sub1", "")
+		Expect(calls.Load()).To(BeEquivalentTo(6))
+	})
+
+	It("should not replay the reads", func() {
+		serve(http.MethodGet, "sub1", "id-1")
+		serve(http.MethodGet, "sub1", "id-1")
+		Expect(calls.Load()).To(BeEquivalentTo(2))
+	})
+
+	It("should execute the retries of retriable failures", func() {
+		status = http.StatusServiceUnavailable
+		Expect(serve(http.MethodPut, "sub1", "id-1").Code).To(Equal(http.StatusServiceUnavailable))
+		status = http.StatusOK
+		Expect(serve(http.MethodPut, "sub1", "id-1").Code).To(Equal(http.StatusOK))
+		Expect(calls.Load()).To(BeEquivalentTo(2))
+	})
+
+	It("should return a 409 while the first attempt is in flight", func() {
+		release = make(chan struct{})
+		done := make(chan *httptest.ResponseRecorder)
+		go func() {
+			defer GinkgoRecover()
+			done <- serve(http.MethodPut, "sub1", "id-1")
+		}()
+		Eventually(calls.Load).Should(BeEquivalentTo(1))
+
+		conflict := serve(http.MethodPut, "sub1", "id-1")
+		Expect(conflict.Code).To(Equal(http.StatusConflict))
+		Expect(conflict.Body.String()).To(ContainSubstring(`"code":"Conflict"`))
+
+		close(release)
+		Expect((<-done).Code).To(Equal(http.StatusCreated))
+		Expect(serve(http.MethodPut, "sub1", "id-1").Code).To(Equal(http.StatusCreated))
+		Expect(calls.Load()).To(BeEquivalentTo(1))
+	})
+})
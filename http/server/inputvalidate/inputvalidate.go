@@ -0,0 +1,229 @@
+package inputvalidate
+
+// This package validates the requests of mux routes against an OpenAPI 2 or 3 spec, e.g. the api.swagger.json
+// generated for the gRPC gateway, before their handler runs. The path, query and header parameters and the
+// JSON body are checked, and invalid requests get a 400 with an ARM error.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/Azure/aks-middleware/http/common"
+	"github.com/Azure/aks-middleware/http/common/armerror"
+	"github.com/Azure/aks-middleware/http/server/logging"
+	"github.com/getkin/kin-openapi/openapi2"
+	"github.com/getkin/kin-openapi/openapi2conv"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/gorilla/mux"
+)
+
+// RequestLogKey is the log key of the validation errors in the request log.
+const RequestLogKey = "validation_errors"
+
+// ARM error codes of the validation errors.
+const (
+	CodeInvalidParameter           = "InvalidParameter"
+	CodeInvalidRequestContent      = "InvalidRequestContent"
+	CodeMissingAPIVersionParameter = "MissingApiVersionParameter"
+)
+
+// LoadSpec parses an OpenAPI 2 (swagger) JSON spec or an OpenAPI 3 JSON or YAML spec. OpenAPI 2 specs are
+// converted to OpenAPI 3.
+// The servers of the spec are removed so that the routes match whatever host the service is reached at.
+func LoadSpec(data []byte) (*openapi3.T, error) {
+	var version struct {
+		Swagger string `json:"swagger"`
+	}
+	loader := openapi3.NewLoader()
+	var doc *openapi3.T
+	var err error
+	if json.Unmarshal(data, &version) == nil && version.Swagger != "" {
+		var doc2 openapi2.T
+		if err := json.Unmarshal(data, &doc2); err != nil {
+			return nil, fmt.Errorf("parsing OpenAPI 2 spec: %w", err)
+		}
+		if doc, err = openapi2conv.ToV3(&doc2); err != nil {
+			return nil, fmt.Errorf("converting OpenAPI 2 spec: %w", err)
+		}
+		if doc.OpenAPI == "" {
+			doc.OpenAPI = "3.0.3"
+		}
+	} else {
+		if doc, err = loader.LoadFromData(data); err != nil {
+			return nil, fmt.Errorf("parsing OpenAPI 3 spec: %w", err)
+		}
+	}
+	doc.Servers = nil
+	if err = doc.Validate(loader.Context); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI spec: %w", err)
+	}
+	return doc, nil
+}
+
+// LoadSpecFile parses the OpenAPI spec of the file at path, see LoadSpec.
+func LoadSpecFile(path string) (*openapi3.T, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return LoadSpec(data)
+}
+
+// NewInputValidate returns a middleware validating the requests against the operations of doc. Requests
+// without an operation in doc aren't validated. The paths are matched case-sensitively, unlike ARM, so
+// the requests whose path differs in case from the spec, e.g. "/resourcegroups/", aren't validated either
+// and their handler has to tolerate invalid input. Invalid requests get a 400 with an ARM error whose details
+// list every error, with the parameter name or the JSON pointer of the invalid body property as target.
+// The errors are also added to the request log when the logging middleware is registered before.
+func NewInputValidate(doc *openapi3.T) (mux.MiddlewareFunc, error) {
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, err
+	}
+	return func(next http.Handler) http.Handler {
+		return &inputValidateMiddleware{
+			next:   next,
+			router: router,
+		}
+	}, nil
+}
+
+var _ http.Handler = &inputValidateMiddleware{}
+
+type inputValidateMiddleware struct {
+	next   http.Handler
+	router routers.Router
+}
+
+func (m *inputValidateMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	route, pathParams, err := m.router.FindRoute(r)
+	if err != nil {
+		m.next.ServeHTTP(w, r)
+		return
+	}
+	err = openapi3filter.ValidateRequest(r.Context(), &openapi3filter.RequestValidationInput{
+		Request:    r,
+		PathParams: pathParams,
+		Route:      route,
+		Options: &openapi3filter.Options{
+			MultiError:         true,
+			AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+		},
+	})
+	if err == nil {
+		m.next.ServeHTTP(w, r)
+		return
+	}
+	details := Errors(err)
+	logging.AddFields(r.Context(), RequestLogKey, details)
+	armerror.Write(w, http.StatusBadRequest, armError(details))
+}
+
+// armError returns the error of the response, the only detail or a summary of all the details.
+func armError(details []armerror.Error) armerror.Error {
+	if len(details) == 0 {
+		return armerror.Error{
+			Code:    CodeInvalidRequestContent,
+			Message: "The request content is invalid.",
+		}
+	}
+	if len(details) == 1 {
+		return details[0]
+	}
+	return armerror.Error{
+		Code:    details[0].Code,
+		Message: fmt.Sprintf("The request has %d validation errors, see details.", len(details)),
+		Details: details,
+	}
+}
+
+// Errors returns the ARM errors of an openapi3filter.ValidateRequest error.
+func Errors(err error) []armerror.Error {
+	if multi, ok := err.(openapi3.MultiError); ok {
+		var details []armerror.Error
+		for _, e := range multi {
+			details = append(details, Errors(e)...)
+		}
+		return details
+	}
+	var requestErr *openapi3filter.RequestError
+	if !errors.As(err, &requestErr) {
+		return []armerror.Error{{Code: CodeInvalidRequestContent, Message: err.Error()}}
+	}
+	schemaErrs := schemaErrors(requestErr.Err)
+
+	if p := requestErr.Parameter; p != nil {
+		if p.In == openapi3.ParameterInQuery && p.Name == common.APIVersionKey && errors.Is(requestErr.Err, openapi3filter.ErrInvalidRequired) {
+			return []armerror.Error{{
+				Code:    CodeMissingAPIVersionParameter,
+				Message: "The api-version query parameter (?api-version=) is required for all requests.",
+				Target:  p.Name,
+			}}
+		}
+		reasons := make([]string, 0, len(schemaErrs))
+		for _, e := range schemaErrs {
+			reasons = append(reasons, e.Reason)
+		}
+		if len(reasons) == 0 && requestErr.Err != nil {
+			reasons = append(reasons, requestErr.Err.Error())
+		}
+		return []armerror.Error{{
+			Code:    CodeInvalidParameter,
+			Message: fmt.Sprintf("The %s parameter '%s' is invalid: %s.", p.In, p.Name, strings.Join(reasons, "; ")),
+			Target:  p.Name,
+		}}
+	}
+
+	if len(schemaErrs) == 0 {
+		message := requestErr.Reason
+		if requestErr.Err != nil {
+			message = requestErr.Err.Error()
+		}
+		return []armerror.Error{{
+			Code:    CodeInvalidRequestContent,
+			Message: fmt.Sprintf("The request content is invalid: %s.", message),
+		}}
+	}
+	details := make([]armerror.Error, 0, len(schemaErrs))
+	for _, e := range schemaErrs {
+		details = append(details, armerror.Error{
+			Code:    CodeInvalidRequestContent,
+			Message: fmt.Sprintf("The request content is invalid: %s.", e.Reason),
+			Target:  jsonPointer(e.JSONPointer()),
+		})
+	}
+	return details
+}
+
+// schemaErrors returns the schema errors of err, several of them with the MultiError option.
+func schemaErrors(err error) []*openapi3.SchemaError {
+	switch e := err.(type) {
+	case openapi3.MultiError:
+		var errs []*openapi3.SchemaError
+		for _, inner := range e {
+			errs = append(errs, schemaErrors(inner)...)
+		}
+		return errs
+	case *openapi3.SchemaError:
+		return []*openapi3.SchemaError{e}
+	default:
+		return nil
+	}
+}
+
+// jsonPointer returns the RFC 6901 JSON pointer of path, e.g. "/address/zipcode".
+func jsonPointer(path []string) string {
+	var b strings.Builder
+	for _, token := range path {
+		b.WriteString("/")
+		b.WriteString(strings.NewReplacer("~", "~0", "/", "~1").Replace(token))
+	}
+	return b.String()
+}
@@ -0,0 +1,13 @@
+package inputvalidate
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestInputValidate(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "InputValidate Suite")
+}
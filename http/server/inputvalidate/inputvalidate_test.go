@@ -0,0 +1,224 @@
+package inputvalidate
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/Azure/aks-middleware/http/common/armerror"
+	"github.com/Azure/aks-middleware/http/server/logging"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gorilla/mux"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const openAPI3Spec = `
+openapi: 3.0.0
+info:
+  title: test
+  version: "1"
+servers:
+  - url: https://management.azure.com
+paths:
+  /subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}:
+    put:
+      parameters:
+        - name: subscriptionId
+          in: path
+          required: true
+          schema:
+            type: string
+            format: uuid
+            pattern: "^[0-9a-fA-F-]{36}$"
+        - name: resourceGroupName
+          in: path
+          required: true
+          schema:
+            type: string
+            maxLength: 10
+        - name: api-version
+          in: query
+          required: true
+          schema:
+            type: string
+            enum: ["2024-01-01"]
+        - name: x-ms-client-request-id
+          in: header
+          schema:
+            type: string
+            maxLength: 36
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              type: object
+              required: [location]
+              properties:
+                location:
+                  type: string
+                tags:
+                  type: object
+                  additionalProperties:
+                    type: string
+      responses:
+        "200":
+          description: OK
+`
+
+const subscriptionID = "00000000-0000-0000-0000-000000000001"
+
+var _ = Describe("InputValidate middleware", func() {
+	var (
+		router *mux.Router
+		logBuf *bytes.Buffer
+		body   []byte
+		called bool
+	)
+
+	newRouter := func(doc *openapi3.T) {
+		mw, err := NewInputValidate(doc)
+		Expect(err).NotTo(HaveOccurred())
+
+		logBuf = new(bytes.Buffer)
+		router = mux.NewRouter()
+		router.Use(logging.NewLogging(slog.New(slog.NewJSONHandler(logBuf, nil))))
+		router.Use(mw)
+		handler := func(w http.ResponseWriter, r *http.Request) {
+			called = true
+			body, _ = io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusOK)
+		}
+		router.HandleFunc("/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}", handler)
+		router.HandleFunc("/v1/hello", handler)
+		router.HandleFunc("/unknown", handler)
+	}
+
+	serve := func(method, target, reqBody string, header http.Header) *httptest.ResponseRecorder {
+		called = false
+		body = nil
+		req := httptest.NewRequest(method, target, strings.NewReader(reqBody))
+		req.Header.Set("Content-Type", "application/json")
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	armError := func(w *httptest.ResponseRecorder) armerror.Error {
+		var resp armerror.Response
+		Expect(json.Unmarshal(w.Body.Bytes(), &resp)).To(Succeed())
+		return resp.Error
+	}
+
+	Context("with an OpenAPI 3 spec", func() {
+		const target = "/subscriptions/" + subscriptionID + "/resourceGroups/rg?api-version=2024-01-01"
+
+		BeforeEach(func() {
+			doc, err := LoadSpec([]byte(openAPI3Spec))
+			Expect(err).NotTo(HaveOccurred())
+			newRouter(doc)
+		})
+
+		It("should pass valid requests with their body to the handler", func() {
+			w := serve(http.MethodPut, target, `{"location":"eastus"}`, nil)
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(called).To(BeTrue())
+			Expect(string(body)).To(Equal(`{"location":"eastus"}`))
+		})
+
+		It("should reject a missing api-version", func() {
+			w := serve(http.MethodPut, "/subscriptions/"+subscriptionID+"/resourceGroups/rg", `{"location":"eastus"}`, nil)
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+			Expect(called).To(BeFalse())
+			e := armError(w)
+			Expect(e.Code).To(Equal(CodeMissingAPIVersionParameter))
+			Expect(e.Target).To(Equal("api-version"))
+		})
+
+		It("should reject an unsupported api-version", func() {
+			w := serve(http.MethodPut, "/subscriptions/"+subscriptionID+"/resourceGroups/rg?api-version=2020-01-01", `{"location":"eastus"}`, nil)
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+			e := armError(w)
+			Expect(e.Code).To(Equal(CodeInvalidParameter))
+			Expect(e.Target).To(Equal("api-version"))
+		})
+
+		It("should reject invalid path parameters and headers", func() {
+			header := http.Header{"X-Ms-Client-Request-Id": {strings.Repeat("a", 40)}}
+			w := serve(http.MethodPut, "/subscriptions/"+subscriptionID+"/resourceGroups/too-long-name?api-version=2024-01-01", `{"location":"eastus"}`, header)
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+			e := armError(w)
+			Expect(e.Details).To(HaveLen(2))
+			Expect(e.Details).To(ContainElements(
+				HaveField("Target", "resourceGroupName"),
+				HaveField("Target", "x-ms-client-request-id"),
+			))
+			Expect(logBuf.String()).To(ContainSubstring(`"` + RequestLogKey + `"`))
+		})
+
+		It("should reject invalid bodies with JSON pointer targets", func() {
+			w := serve(http.MethodPut, target, `{"tags":{"env":1}}`, nil)
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+			e := armError(w)
+			Expect(e.Code).To(Equal(CodeInvalidRequestContent))
+			Expect(e.Details).To(ContainElements(
+				HaveField("Target", "/location"),
+				HaveField("Target", "/tags/env"),
+			))
+		})
+
+		It("should reject a missing body", func() {
+			w := serve(http.MethodPut, target, "", nil)
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+			Expect(armError(w).Code).To(Equal(CodeInvalidRequestContent))
+		})
+
+		It("should pass the routes missing from the spec", func() {
+			w := serve(http.MethodGet, "/unknown", "", nil)
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(called).To(BeTrue())
+		})
+	})
+
+	Context("with the OpenAPI 2 spec of the gateway", func() {
+		BeforeEach(func() {
+			doc, err := LoadSpecFile("../../../test/api/v1/api.swagger.json")
+			Expect(err).NotTo(HaveOccurred())
+			newRouter(doc)
+		})
+
+		It("should validate the body", func() {
+			w := serve(http.MethodPost, "/v1/hello", `{"name":"test","age":30,"address":{"zipcode":12345}}`, nil)
+			Expect(w.Code).To(Equal(http.StatusOK))
+
+			w = serve(http.MethodPost, "/v1/hello", `{"name":1,"address":{"zipcode":"12345"}}`, nil)
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+			Expect(called).To(BeFalse())
+			Expect(armError(w).Details).To(ContainElements(
+				HaveField("Target", "/name"),
+				HaveField("Target", "/address/zipcode"),
+			))
+		})
+	})
+
+	It("should reject invalid specs", func() {
+		_, err := LoadSpec([]byte(`{"openapi":"3.0.0","paths":{"/a":{"get":{}}}}`))
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("armError", func() {
+	It("should return a generic error without details", func() {
+		e := armError(Errors(openapi3.MultiError{}))
+		Expect(e.Code).To(Equal(CodeInvalidRequestContent))
+		Expect(e.Details).To(BeEmpty())
+	})
+})
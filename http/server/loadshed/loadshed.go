@@ -0,0 +1,59 @@
+package loadshed
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/Azure/aks-middleware/http/common"
+	"github.com/Azure/aks-middleware/http/common/armerror"
+	"github.com/Azure/aks-middleware/http/common/loadshed"
+	"github.com/Azure/aks-middleware/http/common/scrub"
+	"github.com/Azure/aks-middleware/http/server/logging"
+	"github.com/gorilla/mux"
+)
+
+// NewLoadShedding returns a middleware rejecting requests with a 503, Retry-After and an ARM
+// ServerBusy error when the concurrency limit of limiter is reached. Rejected requests are logged to
+// logger as an ApiRequestLog "finished call" with shed=true. Register it first, before NewLogging,
+// so that requests are shed before any work is done for them.
+func NewLoadShedding(logger *slog.Logger, limiter *loadshed.Limiter) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return &loadSheddingMiddleware{
+			next:    next,
+			logger:  scrub.Logger(logger),
+			limiter: limiter,
+		}
+	}
+}
+
+var _ http.Handler = &loadSheddingMiddleware{}
+
+type loadSheddingMiddleware struct {
+	next    http.Handler
+	logger  *slog.Logger
+	limiter *loadshed.Limiter
+}
+
+func (l *loadSheddingMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	release := l.limiter.Acquire()
+	if release != nil {
+		defer release()
+		l.next.ServeHTTP(w, r)
+		return
+	}
+
+	seconds := common.RetryAfterSeconds(l.limiter.RetryAfter())
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	armerror.Write(w, http.StatusServiceUnavailable, armerror.Error{
+		Code:    "ServerBusy",
+		Message: "The server is overloaded. Please try again after " + strconv.Itoa(seconds) + " seconds.",
+	})
+	ctx := r.Context()
+	l.logger.ErrorContext(ctx, "finished call", logging.BuildAttributes(ctx, r,
+		"code", http.StatusServiceUnavailable,
+		"time_ms", 0,
+		"error", "server overloaded",
+		loadshed.ShedLogKey, true,
+	)...)
+}
@@ -0,0 +1,13 @@
+package loadshed
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestLoadShed(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "LoadShed Suite")
+}
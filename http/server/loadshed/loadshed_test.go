@@ -0,0 +1,39 @@
+package loadshed
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/Azure/aks-middleware/http/common/loadshed"
+	"github.com/gorilla/mux"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Load shedding middleware", func() {
+	It("should reject requests over the limit with a 503 and log them as shed", func() {
+		logBuf := &bytes.Buffer{}
+		limiter := loadshed.NewLimiter(loadshed.Options{InitialLimit: 1, MaxLimit: 1})
+		router := mux.NewRouter()
+		router.Use(NewLoadShedding(slog.New(slog.NewJSONHandler(logBuf, nil)), limiter))
+
+		var shedResponse *httptest.ResponseRecorder
+		router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			// A request arriving while this one is in flight is shed.
+			shedResponse = httptest.NewRecorder()
+			router.ServeHTTP(shedResponse, httptest.NewRequest(http.MethodGet, "/", nil))
+		})
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(shedResponse.Code).To(Equal(http.StatusServiceUnavailable))
+		Expect(shedResponse.Header().Get("Retry-After")).To(Equal("1"))
+		Expect(shedResponse.Body.String()).To(ContainSubstring(`"code":"ServerBusy"`))
+		Expect(logBuf.String()).To(ContainSubstring(`"msg":"finished call"`))
+		Expect(logBuf.String()).To(ContainSubstring(`"shed":true`))
+		Expect(limiter.InFlight()).To(BeZero())
+	})
+})
@@ -6,9 +6,12 @@ import (
 	log "log/slog"
 	"net/http"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/Azure/aks-middleware/http/common/logging"
+	"github.com/Azure/aks-middleware/http/common/sampling"
+	"github.com/Azure/aks-middleware/http/common/scrub"
 	"github.com/gorilla/mux"
 	"google.golang.org/grpc/metadata"
 )
@@ -22,11 +25,20 @@ const (
 
 // more info about http handler here: https://pkg.go.dev/net/http#Handler
 func NewLogging(logger *log.Logger) mux.MiddlewareFunc {
+	return NewLoggingWithSampling(logger, nil)
+}
+
+// NewLoggingWithSampling is like NewLogging, but the RequestStart, RequestEnd and finished call records of
+// successful requests are sampled with policy. A nil policy logs every request. The sampling is decided
+// when the request starts, so the failed or slow requests that weren't sampled are logged without their
+// RequestStart record.
+func NewLoggingWithSampling(logger *log.Logger, policy *sampling.Policy) mux.MiddlewareFunc {
 	return func(next http.Handler) http.Handler {
 		return &loggingMiddleware{
-			next:   next,
-			now:    time.Now,
-			logger: logger,
+			next:     next,
+			now:      time.Now,
+			logger:   scrub.Logger(logger),
+			sampling: policy,
 		}
 	}
 }
@@ -35,23 +47,59 @@ func NewLogging(logger *log.Logger) mux.MiddlewareFunc {
 var _ http.Handler = &loggingMiddleware{}
 
 type loggingMiddleware struct {
-	next   http.Handler
-	now    func() time.Time
-	logger *log.Logger
+	next     http.Handler
+	now      func() time.Time
+	logger   *log.Logger
+	sampling *sampling.Policy
 }
 type RequestLogData struct {
 	Code     int
 	Duration time.Duration
 	Error    string
+	// SampleRate is logged as sample_rate when set.
+	SampleRate float64
+	// Fields are the attributes added with AddFields.
+	Fields []any
+}
+
+type requestFieldsKey struct{}
+
+// requestFields holds the attributes added to the request log by the inner middlewares.
+type requestFields struct {
+	mu    sync.Mutex
+	attrs []any
+}
+
+func (f *requestFields) get() []any {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.attrs
+}
+
+// AddFields adds attrs to the RequestEnd and finished call records of the request of ctx,
+// like logging.AddFields does for the gRPC interceptors. It is a no-op for requests that
+// don't go through the logging middleware.
+func AddFields(ctx context.Context, attrs ...any) {
+	if f, ok := ctx.Value(requestFieldsKey{}).(*requestFields); ok {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.attrs = append(f.attrs, attrs...)
+	}
 }
 
 func (l *loggingMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	customWriter := logging.NewResponseWriter(w)
 
 	startTime := l.now()
-	ctx := r.Context()
-
-	l.LogRequestStart(ctx, r, "RequestStart")
+	fields := &requestFields{}
+	ctx := context.WithValue(r.Context(), requestFieldsKey{}, fields)
+	r = r.WithContext(ctx)
+
+	method := logging.GetMethodInfo(r.Method, r.URL.Path)
+	sampled, rate := l.sampling.Sample(method, false, 0)
+	if sampled {
+		l.LogRequestStart(ctx, r, "RequestStart")
+	}
 	l.next.ServeHTTP(customWriter, r)
 	endTime := l.now()
 
@@ -62,6 +110,20 @@ func (l *loggingMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		Code:     customWriter.StatusCode,
 		Duration: latency,
 		Error:    errorMsg,
+		Fields:   fields.get(),
+	}
+	if l.sampling != nil {
+		statusCode := data.Code
+		if statusCode == 0 {
+			// The handler wrote nothing, net/http answers 200.
+			statusCode = http.StatusOK
+		}
+		if l.sampling.Always(sampling.FailedStatus(statusCode), latency) {
+			rate = 1
+		} else if !sampled {
+			return
+		}
+		data.SampleRate = rate
 	}
 	// TODO (tomabraham): move RequestStart and RequestEnd to a different interceptor
 	// ApiRequestLog should only get "finished call" logs
@@ -80,7 +142,7 @@ func BuildAttributes(ctx context.Context, r *http.Request, extra ...interface{})
 		"component", "server",
 		"method", logging.GetMethodInfo(r.Method, r.URL.Path),
 		"service", r.Host,
-		"url", r.URL.String(),
+		"url", scrub.Default().String(r.URL.String()),
 	}
 
 	headers := make(map[string]string)
@@ -92,6 +154,8 @@ func BuildAttributes(ctx context.Context, r *http.Request, extra ...interface{})
 		}
 	}
 
+	headers = scrub.Default().Headers(headers)
+
 	templogger := log.New(log.NewJSONHandler(os.Stdout, nil)).With("source", apiRequestLogSource)
 	headerBytes, err := json.Marshal(headers)
 	headersStr := string(headerBytes)
@@ -116,6 +180,10 @@ func (l *loggingMiddleware) LogRequestStart(ctx context.Context, r *http.Request
 
 func (l *loggingMiddleware) LogRequestEnd(ctx context.Context, r *http.Request, msg string, data RequestLogData) {
 	attributes := BuildAttributes(ctx, r, "code", data.Code, "time_ms", data.Duration.Milliseconds(), "error", data.Error)
+	attributes = append(attributes, data.Fields...)
+	if data.SampleRate > 0 {
+		attributes = append(attributes, sampling.SampleRateLogKey, data.SampleRate)
+	}
 	if data.Code >= http.StatusBadRequest {
 		l.logger.ErrorContext(ctx, msg, attributes...)
 	} else {
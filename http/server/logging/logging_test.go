@@ -9,6 +9,7 @@ import (
 	"strings"
 		"encoding/json"
 
+	"github.com/Azure/aks-middleware/http/common/sampling"
 	"github.com/Azure/aks-middleware/http/server/requestid"
 	"github.com/gorilla/mux"
 	. "github.com/onsi/ginkgo/v2"
@@ -105,6 +106,63 @@ var _ = Describe("Httpmw", func() {
 			Expect(buf.String()).To(ContainSubstring("test error"))
 			Expect(w.Result().StatusCode).To(Equal(http.StatusBadRequest))
 		})
+
+		It("should add the fields of the inner middlewares to the finished call", func() {
+			router.HandleFunc("/fields", func(w http.ResponseWriter, r *http.Request) {
+				AddFields(r.Context(), "queued_ms", 5)
+			})
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/fields", nil))
+
+			Expect(buf.String()).To(ContainSubstring(`"queued_ms":5`))
+		})
+	})
+
+	Describe("LoggingMiddleware with sampling", func() {
+		BeforeEach(func() {
+			router = mux.NewRouter()
+			router.Use(NewLoggingWithSampling(slogLogger, &sampling.Policy{
+				DefaultRate: 1e-12,
+				MethodRates: map[string]float64{"GET /": 0.5},
+			}))
+			router.HandleFunc("/", func(w http.ResponseWriter, _ *http.Request) {})
+			router.HandleFunc("/sampled", func(w http.ResponseWriter, _ *http.Request) {})
+			router.HandleFunc("/error", func(w http.ResponseWriter, _ *http.Request) {
+				http.Error(w, "test error", http.StatusInternalServerError)
+			})
+		})
+
+		It("should drop every record of sampled out successful requests", func() {
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/sampled", nil))
+
+			Expect(buf.String()).ToNot(ContainSubstring("RequestStart"))
+			Expect(buf.String()).ToNot(ContainSubstring("RequestEnd"))
+			Expect(buf.String()).ToNot(ContainSubstring("finished call"))
+		})
+
+		It("should log the sample rate", func() {
+			for i := 0; i < 100 && !strings.Contains(buf.String(), "finished call"); i++ {
+				router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+			}
+
+			Expect(buf.String()).To(ContainSubstring(`"sample_rate":0.5`))
+		})
+
+		It("should always log failed requests", func() {
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/error", nil))
+
+			Expect(buf.String()).To(ContainSubstring("finished call"))
+			Expect(buf.String()).To(ContainSubstring(`"sample_rate":1`))
+		})
+
+		It("should always log the requests that aren't successful, like the client logs", func() {
+			router.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, "/", http.StatusFound)
+			})
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/redirect", nil))
+
+			Expect(buf.String()).To(ContainSubstring("finished call"))
+			Expect(buf.String()).To(ContainSubstring(`"code":302`))
+		})
 	})
 })
 
@@ -0,0 +1,180 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate"
+	"buf.build/go/protovalidate"
+	grpccommon "github.com/Azure/aks-middleware/grpc/common"
+	"github.com/Azure/aks-middleware/http/common"
+	"github.com/Azure/aks-middleware/http/common/armerror"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Response headers set by the ARM error handler.
+const (
+	// ErrorCodeHeader is the ARM error code of the response.
+	ErrorCodeHeader = "x-ms-error-code"
+	// FailureCauseHeader tells whether the error comes from the gateway or from the gRPC service.
+	FailureCauseHeader = "x-ms-failure-cause"
+	// RequestIDHeader is the request ID, also added to the error as AdditionalInfo of type RequestIDInfoType.
+	RequestIDHeader = "x-ms-request-id"
+)
+
+// FailureCauseHeader values.
+const (
+	FailureCauseGateway = "gateway"
+	FailureCauseService = "service"
+)
+
+// RequestIDInfoType is the AdditionalInfo type holding the request ID.
+const RequestIDInfoType = "RequestId"
+
+// NewARMErrorHandler returns a gateway error handler writing the errors in the ARM error response contract
+// instead of the default gateway JSON. Use it with runtime.WithErrorHandler next to NewMetadataMiddleware,
+// with the same metadataToHeader map so that the mapped server metadata is also sent on errors.
+//
+// The HTTP status is the runtime.HTTPStatusFromCode of the gRPC code. The ARM error code is the ErrorInfo reason
+// of the status when there is one, converted from UPPER_SNAKE_CASE, or armerror.CodeFromGRPC otherwise. The
+// ErrorInfo of the grpc/common ErrorInfoDomain, internal to this module, are ignored.
+// BadRequest and protovalidate violations become details with the field as target. A RetryInfo delay or a
+// retry-after trailer is sent as Retry-After.
+func NewARMErrorHandler(metadataToHeader map[string]string) runtime.ErrorHandlerFunc {
+	return func(ctx context.Context, mux *runtime.ServeMux, marshaler runtime.Marshaler, w http.ResponseWriter,
+		r *http.Request, err error) {
+		cause := FailureCauseService
+		statusCode := 0
+		var customStatus *runtime.HTTPStatusError
+		if errors.As(err, &customStatus) {
+			// Routing errors of the gateway.
+			cause = FailureCauseGateway
+			statusCode = customStatus.HTTPStatus
+			err = customStatus.Err
+		}
+		s := status.Convert(err)
+		if statusCode == 0 {
+			statusCode = runtime.HTTPStatusFromCode(s.Code())
+		}
+		md, _ := runtime.ServerMetadataFromContext(ctx)
+		if md.HeaderMD == nil && md.TrailerMD == nil {
+			// Only the responses of the gRPC service have header or trailer metadata, the errors without any
+			// failed in the gateway, e.g. when the request body can't be unmarshaled.
+			cause = FailureCauseGateway
+		}
+
+		for key, vals := range md.HeaderMD {
+			if header, ok := matchOutgoingHeader(metadataToHeader, key); ok && header != "" {
+				for _, val := range vals {
+					w.Header().Add(header, val)
+				}
+			}
+		}
+		armErr := ARMError(s)
+		if requestID := requestID(md, r); requestID != "" {
+			w.Header().Set(RequestIDHeader, requestID)
+			armErr.AdditionalInfo = append(armErr.AdditionalInfo, armerror.AdditionalInfo{
+				Type: RequestIDInfoType,
+				Info: map[string]string{"requestId": requestID},
+			})
+		}
+		if retryAfter, ok := retryAfter(s, md); ok {
+			w.Header().Set("Retry-After", strconv.Itoa(common.RetryAfterSeconds(retryAfter)))
+		}
+		w.Header().Set(ErrorCodeHeader, armErr.Code)
+		w.Header().Set(FailureCauseHeader, cause)
+		w.Header().Del("Trailer")
+		w.Header().Del("Transfer-Encoding")
+		armerror.Write(w, statusCode, armErr)
+	}
+}
+
+// ARMError converts s to an ARM error, see NewARMErrorHandler.
+func ARMError(s *status.Status) armerror.Error {
+	armErr := armerror.Error{
+		Code:    armerror.CodeFromGRPC(s.Code()),
+		Message: s.Message(),
+	}
+	for _, detail := range s.Details() {
+		switch detail := detail.(type) {
+		case *errdetails.ErrorInfo:
+			// The ErrorInfo of this module, e.g. grpccommon.NonRetriableReason, are internal markers.
+			if detail.GetReason() != "" && detail.GetDomain() != grpccommon.ErrorInfoDomain {
+				armErr.Code = armCode(detail.GetReason())
+			}
+		case *errdetails.BadRequest:
+			for _, violation := range detail.GetFieldViolations() {
+				code := armerror.CodeFromGRPC(codes.InvalidArgument)
+				if violation.GetReason() != "" {
+					code = armCode(violation.GetReason())
+				}
+				armErr.Details = append(armErr.Details, armerror.Error{
+					Code:    code,
+					Message: violation.GetDescription(),
+					Target:  violation.GetField(),
+				})
+			}
+		case *validate.Violations:
+			for _, violation := range detail.GetViolations() {
+				armErr.Details = append(armErr.Details, armerror.Error{
+					Code:    armerror.CodeFromGRPC(codes.InvalidArgument),
+					Message: violation.GetMessage(),
+					Target:  protovalidate.FieldPathString(violation.GetField()),
+				})
+			}
+		}
+	}
+	return armErr
+}
+
+// armCode converts an UPPER_SNAKE_CASE ErrorInfo reason, e.g. "QUOTA_EXCEEDED", to an ARM code, e.g. "QuotaExceeded".
+// Other reasons are returned unchanged.
+func armCode(reason string) string {
+	if reason != strings.ToUpper(reason) {
+		return reason
+	}
+	var code strings.Builder
+	for _, word := range strings.Split(strings.ToLower(reason), "_") {
+		if word == "" {
+			continue
+		}
+		code.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	return code.String()
+}
+
+// requestID returns the request ID sent by the server, or the one of the request.
+func requestID(md runtime.ServerMetadata, r *http.Request) string {
+	for _, source := range [][]string{
+		md.HeaderMD.Get(common.RequestIDMetadataHeader),
+		md.TrailerMD.Get(common.RequestIDMetadataHeader),
+		r.Header.Values(common.RequestIDMetadataHeader),
+	} {
+		if len(source) > 0 && source[0] != "" {
+			return source[0]
+		}
+	}
+	return ""
+}
+
+// retryAfter returns the RetryInfo delay of s or the retry-after trailer of md.
+func retryAfter(s *status.Status, md runtime.ServerMetadata) (time.Duration, bool) {
+	for _, detail := range s.Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok && info.GetRetryDelay() != nil {
+			return info.GetRetryDelay().AsDuration(), true
+		}
+	}
+	if vals := md.TrailerMD.Get(common.RetryAfterKey); len(vals) > 0 {
+		if seconds, err := strconv.Atoi(vals[0]); err == nil {
+			return time.Duration(seconds) * time.Second, true
+		}
+	}
+	return 0, false
+}
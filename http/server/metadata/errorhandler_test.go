@@ -0,0 +1,171 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"buf.build/go/protovalidate"
+	grpccommon "github.com/Azure/aks-middleware/grpc/common"
+	"github.com/Azure/aks-middleware/grpc/server/requestid"
+	"github.com/Azure/aks-middleware/http/common/armerror"
+	pb "github.com/Azure/aks-middleware/test/api/v1"
+	testServer "github.com/Azure/aks-middleware/test/server"
+	protovalidate_middleware "github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/protovalidate"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func decodeARMError(body *httptest.ResponseRecorder) armerror.Error {
+	var resp armerror.Response
+	Expect(json.Unmarshal(body.Body.Bytes(), &resp)).To(Succeed())
+	return resp.Error
+}
+
+var _ = Describe("ARM error handler", func() {
+	var (
+		handler runtime.ErrorHandlerFunc
+		rec     *httptest.ResponseRecorder
+		req     *http.Request
+	)
+
+	BeforeEach(func() {
+		handler = NewARMErrorHandler(map[string]string{"operationid": "x-ms-acs-operation-id"})
+		rec = httptest.NewRecorder()
+		req = httptest.NewRequest(http.MethodPost, "/v1/hello", nil)
+	})
+
+	It("should map the status code and use the ErrorInfo reason as ARM code", func() {
+		st, err := status.New(codes.FailedPrecondition, "quota exceeded").WithDetails(&errdetails.ErrorInfo{Reason: "QUOTA_EXCEEDED"})
+		Expect(err).ToNot(HaveOccurred())
+		ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{
+			HeaderMD: metadata.Pairs("operationid", "op-id", "x-request-id", "req-id", "secret", "value"),
+		})
+
+		handler(ctx, runtime.NewServeMux(), &runtime.JSONPb{}, rec, req, st.Err())
+		Expect(rec.Code).To(Equal(http.StatusBadRequest))
+		Expect(rec.Header().Get("Content-Type")).To(Equal("application/json"))
+		Expect(rec.Header().Get(ErrorCodeHeader)).To(Equal("QuotaExceeded"))
+		Expect(rec.Header().Get(FailureCauseHeader)).To(Equal(FailureCauseService))
+		Expect(rec.Header().Get(RequestIDHeader)).To(Equal("req-id"))
+		Expect(rec.Header().Get("x-ms-acs-operation-id")).To(Equal("op-id"))
+		Expect(rec.Header()).ToNot(HaveKey("Secret"))
+
+		armErr := decodeARMError(rec)
+		Expect(armErr.Code).To(Equal("QuotaExceeded"))
+		Expect(armErr.Message).To(Equal("quota exceeded"))
+		Expect(armErr.AdditionalInfo).To(Equal([]armerror.AdditionalInfo{{
+			Type: RequestIDInfoType,
+			Info: map[string]any{"requestId": "req-id"},
+		}}))
+	})
+
+	It("should turn BadRequest violations into details and RetryInfo into Retry-After", func() {
+		st, err := status.New(codes.InvalidArgument, "invalid request").WithDetails(
+			&errdetails.BadRequest{FieldViolations: []*errdetails.BadRequest_FieldViolation{
+				{Field: "properties.count", Description: "must be positive"},
+				{Field: "name", Description: "is too long", Reason: "NameTooLong"},
+			}},
+			&errdetails.RetryInfo{RetryDelay: durationpb.New(1500 * time.Millisecond)},
+		)
+		Expect(err).ToNot(HaveOccurred())
+
+		handler(context.Background(), runtime.NewServeMux(), &runtime.JSONPb{}, rec, req, st.Err())
+		Expect(rec.Code).To(Equal(http.StatusBadRequest))
+		Expect(rec.Header().Get("Retry-After")).To(Equal("2"))
+		Expect(rec.Header().Get(FailureCauseHeader)).To(Equal(FailureCauseGateway))
+		armErr := decodeARMError(rec)
+		Expect(armErr.Code).To(Equal("InvalidParameter"))
+		Expect(armErr.Details).To(Equal([]armerror.Error{
+			{Code: "InvalidParameter", Message: "must be positive", Target: "properties.count"},
+			{Code: "NameTooLong", Message: "is too long", Target: "name"},
+		}))
+	})
+
+	It("should ignore the internal ErrorInfo of the module", func() {
+		err := grpccommon.NonRetriableError(codes.Unavailable, "circuit breaker is open")
+		handler(context.Background(), runtime.NewServeMux(), &runtime.JSONPb{}, rec, req, err)
+		Expect(rec.Code).To(Equal(http.StatusServiceUnavailable))
+		Expect(rec.Header().Get(ErrorCodeHeader)).To(Equal("ServiceUnavailable"))
+		Expect(decodeARMError(rec).Code).To(Equal("ServiceUnavailable"))
+	})
+
+	It("should keep the status of gateway routing errors", func() {
+		err := &runtime.HTTPStatusError{HTTPStatus: http.StatusMethodNotAllowed, Err: status.Error(codes.Unimplemented, "Method Not Allowed")}
+		handler(context.Background(), runtime.NewServeMux(), &runtime.JSONPb{}, rec, req, err)
+		Expect(rec.Code).To(Equal(http.StatusMethodNotAllowed))
+		Expect(rec.Header().Get(FailureCauseHeader)).To(Equal(FailureCauseGateway))
+		Expect(decodeARMError(rec).Code).To(Equal("NotImplemented"))
+	})
+
+	It("should map errors that are not statuses to InternalServerError", func() {
+		handler(context.Background(), runtime.NewServeMux(), &runtime.JSONPb{}, rec, req, errors.New("boom"))
+		Expect(rec.Code).To(Equal(http.StatusInternalServerError))
+		Expect(decodeARMError(rec).Code).To(Equal("InternalServerError"))
+	})
+
+	serveGateway := func(body string) {
+		validator, err := protovalidate.New()
+		Expect(err).ToNot(HaveOccurred())
+		grpcServer := grpc.NewServer(grpc.ChainUnaryInterceptor(
+			requestid.UnaryServerInterceptor(),
+			protovalidate_middleware.UnaryServerInterceptor(validator),
+		))
+		pb.RegisterMyGreeterServer(grpcServer, &testServer.TestServer{})
+		lis, err := net.Listen("tcp", "localhost:0")
+		Expect(err).ToNot(HaveOccurred())
+		go func() {
+			_ = grpcServer.Serve(lis)
+		}()
+		defer grpcServer.Stop()
+
+		metadataToHeader := map[string]string{}
+		gwMux := runtime.NewServeMux(append(NewMetadataMiddleware(nil, metadataToHeader),
+			runtime.WithErrorHandler(NewARMErrorHandler(metadataToHeader)))...)
+		Expect(pb.RegisterMyGreeterHandlerFromEndpoint(context.Background(), gwMux, lis.Addr().String(), []grpc.DialOption{
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+		})).To(Succeed())
+
+		gwMux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/hello", strings.NewReader(body)))
+	}
+
+	It("should return protovalidate violations of gateway requests as details", func() {
+		serveGateway(`{"name":"Test","age":30,"email":"invalid"}`)
+		Expect(rec.Code).To(Equal(http.StatusBadRequest))
+		Expect(rec.Header().Get(FailureCauseHeader)).To(Equal(FailureCauseService))
+		armErr := decodeARMError(rec)
+		Expect(armErr.Code).To(Equal("InvalidParameter"))
+		Expect(armErr.Details).To(HaveLen(1))
+		Expect(armErr.Details[0].Target).To(Equal("email"))
+	})
+
+	It("should return the request ID generated by the server", func() {
+		serveGateway(`{"name":"Test","age":30,"email":"invalid"}`)
+		requestID := rec.Header().Get(RequestIDHeader)
+		Expect(requestID).ToNot(BeEmpty())
+		Expect(decodeARMError(rec).AdditionalInfo).To(Equal([]armerror.AdditionalInfo{{
+			Type: RequestIDInfoType,
+			Info: map[string]any{"requestId": requestID},
+		}}))
+	})
+
+	It("should blame the gateway for request bodies that can't be unmarshaled", func() {
+		serveGateway(`{"name":`)
+		Expect(rec.Code).To(Equal(http.StatusBadRequest))
+		Expect(rec.Header().Get(FailureCauseHeader)).To(Equal(FailureCauseGateway))
+		Expect(decodeARMError(rec).Code).To(Equal("InvalidParameter"))
+	})
+})
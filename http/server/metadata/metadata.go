@@ -4,11 +4,14 @@ import (
 	"context"
 	"net/http"
 
+	"github.com/Azure/aks-middleware/http/common"
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"google.golang.org/grpc/metadata"
 )
 
 // Helper function to extract HTTP headers and put them into metadata.
+// The subscription of ARM paths is also forwarded as common.SubscriptionIDKey, the default key of the
+// rate limiting and idempotency interceptors.
 func extractMetadata(headerToMetadata map[string]string, req *http.Request) metadata.MD {
 	md := metadata.Pairs()
 	for headerName, metadataKey := range headerToMetadata {
@@ -16,6 +19,11 @@ func extractMetadata(headerToMetadata map[string]string, req *http.Request) meta
 			md.Append(metadataKey, value)
 		}
 	}
+	if len(md.Get(common.SubscriptionIDKey)) == 0 {
+		if subscriptionID := common.SubscriptionID(req); subscriptionID != "" {
+			md.Append(common.SubscriptionIDKey, subscriptionID)
+		}
+	}
 	return md
 }
 
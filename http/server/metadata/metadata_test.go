@@ -3,6 +3,7 @@ package metadata
 import (
 	"net/http"
 
+	"github.com/Azure/aks-middleware/http/common"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 )
@@ -36,6 +37,14 @@ var _ = Describe("Metadata", func() {
 			md := extractMetadata(headerToMetadata, req)
 			Expect(md).NotTo(HaveKey("irrelevant-header"))
 		})
+
+		It("should forward the subscription of ARM paths", func() {
+			req, err = http.NewRequest("PUT", "http://example.com/subscriptions/sub1/resourceGroups/rg", nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			md := extractMetadata(headerToMetadata, req)
+			Expect(md.Get(common.SubscriptionIDKey)).To(Equal([]string{"sub1"}))
+		})
 	})
 
 	Describe("matchOutgoingHeader", func() {
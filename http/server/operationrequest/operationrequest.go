@@ -2,11 +2,13 @@ package operationrequest
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/Azure/aks-middleware/http/common"
+	"github.com/Azure/aks-middleware/http/common/armerror"
 	"github.com/gorilla/mux"
 )
 
@@ -26,24 +28,83 @@ func NewOperationRequest(region string, opts OperationRequestOptions) mux.Middle
     }
 }
 
+// NewOperationRequestFor is NewOperationRequest also decoding the JSON body into T, see
+// OperationRequestForFromContext. Requests with a body that can't be decoded get a 400.
+func NewOperationRequestFor[T any](region string, opts OperationRequestOptions) mux.MiddlewareFunc {
+    return func(next http.Handler) http.Handler {
+        return &operationRequestMiddleware{
+            next:   next,
+            region: region,
+            opts:   opts,
+            withPayload: func(ctx context.Context, opReq *BaseOperationRequest) (context.Context, error) {
+                typed, err := DecodeOperationRequest[T](opReq, opts)
+                if err != nil {
+                    return nil, err
+                }
+                return OperationRequestForWithContext(ctx, typed), nil
+            },
+        }
+    }
+}
+
 type operationRequestMiddleware struct {
     next   http.Handler
     region string
     opts   OperationRequestOptions
+    // withPayload adds the typed operation request to the context, if any.
+    withPayload func(ctx context.Context, opReq *BaseOperationRequest) (context.Context, error)
 }
 
 func (op *operationRequestMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
     opReq, err := NewBaseOperationRequest(r, op.region, op.opts)
     if err != nil {
-        http.Error(w, fmt.Errorf("failed to create operation request: %w", err).Error(), http.StatusInternalServerError)
+        op.writeError(w, err)
         return
     }
 
     ctx := r.Context()
     ctx = OperationRequestWithContext(ctx, opReq)
+    if op.withPayload != nil {
+        if ctx, err = op.withPayload(ctx, opReq); err != nil {
+            armerror.Write(w, http.StatusBadRequest, armerror.Error{
+                Code:    "InvalidRequestContent",
+                Message: fmt.Sprintf("The request content was invalid and could not be deserialized: %s.", err),
+            })
+            return
+        }
+    }
     ctx, cancel := context.WithTimeout(ctx, ARMTimeout)
     defer cancel()
     enrichedReq := r.WithContext(ctx)
     enrichedReq.Header.Set(common.RequestAcsOperationIDHeader, opReq.OperationID)
     op.next.ServeHTTP(w, enrichedReq)
 }
+
+// writeError answers the requests NewBaseOperationRequest failed for with an ARM error.
+func (op *operationRequestMiddleware) writeError(w http.ResponseWriter, err error) {
+    var headerErr *HeaderError
+    switch {
+    case errors.Is(err, ErrMissingAPIVersion):
+        armerror.Write(w, http.StatusBadRequest, armerror.Error{
+            Code:    CodeMissingAPIVersionParameter,
+            Message: "The api-version query parameter (?api-version=) is required for all requests.",
+            Target:  common.APIVersionKey,
+        })
+    case errors.As(err, &headerErr):
+        armerror.Write(w, http.StatusBadRequest, armerror.Error{
+            Code:    CodeInvalidRequestHeader,
+            Message: fmt.Sprintf("The request header '%s' is invalid: %s.", headerErr.Header, headerErr.Reason),
+            Target:  headerErr.Header,
+        })
+    case errors.Is(err, ErrBodyTooLarge):
+        armerror.Write(w, http.StatusRequestEntityTooLarge, armerror.Error{
+            Code:    "RequestEntityTooLarge",
+            Message: fmt.Sprintf("The request content exceeds the limit of %d bytes.", maxBodyBytes(op.opts.MaxBodyBytes)),
+        })
+    default:
+        armerror.Write(w, http.StatusInternalServerError, armerror.Error{
+            Code:    "InternalServerError",
+            Message: fmt.Errorf("failed to create operation request: %w", err).Error(),
+        })
+    }
+}
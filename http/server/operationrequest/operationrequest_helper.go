@@ -1,7 +1,9 @@
 package operationrequest
 
 import (
+    "bytes"
     "context"
+    "encoding/json"
     "errors"
     "fmt"
     "io"
@@ -41,14 +43,30 @@ type OperationRequestCustomizerFunc func(extras map[string]interface{}, headers
 
 type OperationRequestOptions struct {
     Customizer OperationRequestCustomizerFunc
+    // RequiredHeaders are the headers the requests must have by mux route name, see ValidateRequest.
+    // The AnyRoute headers are required on every route.
+    RequiredHeaders map[string][]string
+    // MaxBodyBytes is the maximum size of the request body. Defaults to DefaultMaxBodyBytes.
+    MaxBodyBytes int64
+    // DisallowUnknownFields rejects the bodies with fields unknown to the type of OperationRequestFor.
+    DisallowUnknownFields bool
 }
 
+// DefaultMaxBodyBytes is the default maximum size of the request body, the request size limit of ARM.
+const DefaultMaxBodyBytes = 4 << 20
+
+// ErrBodyTooLarge is returned when the request body exceeds OperationRequestOptions.MaxBodyBytes.
+var ErrBodyTooLarge = errors.New("request body too large")
+
+// ErrInvalidBody is returned when the request body can't be decoded into the type of OperationRequestFor.
+var ErrInvalidBody = errors.New("invalid request body")
+
 // NewBaseOperationRequest constructs the BaseOperationRequest.
-// It extracts data from the HTTP request in the following order:
+// The request is first checked with ValidateRequest, then the data is extracted from it in the following order:
 //  1. URL and Query: Extract api-version and target URI.
 //  2. Headers: Extract correlation ID, accepted language, and operation ID.
 //  3. Route Variables: Extract subscription ID, resource group, resource provider/type, and resource name.
-//  4. Method & Body: Capture the HTTP method and read the request body.
+//  4. Method & Body: Capture the HTTP method and read the request body, which is restored for the handlers.
 //  5. Route Name: Optionally capture the route name from mux.CurrentRoute.
 //  6. Customization: Allow further customization of extras.
 func NewBaseOperationRequest(req *http.Request, region string, opts OperationRequestOptions) (*BaseOperationRequest, error) {
@@ -60,13 +78,12 @@ func NewBaseOperationRequest(req *http.Request, region string, opts OperationReq
         Extras:  extras,
     }
 
+    // the api-version is a required parameter for the operation
+    if err := ValidateRequest(req, opts); err != nil {
+        return nil, err
+    }
     query := req.URL.Query()
     op.APIVersion = query.Get(common.APIVersionKey)
-    // if the api-version is not present in the URL, return an error
-    // this is a required parameter for the operation
-    if op.APIVersion == "" {
-        return nil, errors.New("no api-version in URI's parameters")
-    }
     op.TargetURI = req.URL.String()
     vars := mux.Vars(req)
     op.SubscriptionID = vars[common.SubscriptionIDKey]
@@ -74,9 +91,9 @@ func NewBaseOperationRequest(req *http.Request, region string, opts OperationReq
     op.ResourceType = vars[common.ResourceProviderKey] + "/" + vars[common.ResourceTypeKey]
     op.ResourceName = vars[common.ResourceNameKey]
     op.Region = region
-    body, err := io.ReadAll(req.Body)
+    body, err := readBody(req, opts.MaxBodyBytes)
     if err != nil {
-        return nil, fmt.Errorf("failed to read HTTP body: %w", err)
+        return nil, err
     }
     op.Body = body
     op.HttpMethod = req.Method
@@ -90,7 +107,12 @@ func NewBaseOperationRequest(req *http.Request, region string, opts OperationReq
     if opID := headers.Get(common.RequestAcsOperationIDHeader); opID == "" {
         op.OperationID = uuid.Must(uuid.NewV4()).String()
     } else {
-        op.OperationID = uuid.Must(uuid.FromString(opID)).String()
+        // the header was validated by ValidateRequest
+        id, err := uuid.FromString(opID)
+        if err != nil {
+            return nil, &HeaderError{Header: common.RequestAcsOperationIDHeader, Reason: err.Error()}
+        }
+        op.OperationID = id.String()
     }
 
     if opts.Customizer != nil {
@@ -102,6 +124,34 @@ func NewBaseOperationRequest(req *http.Request, region string, opts OperationReq
     return op, nil
 }
 
+// readBody reads the body of req, up to maxBytes, and replaces it with a reader of the read bytes.
+func readBody(req *http.Request, maxBytes int64) ([]byte, error) {
+    if req.Body == nil {
+        return nil, nil
+    }
+    maxBytes = maxBodyBytes(maxBytes)
+    if req.ContentLength > maxBytes {
+        return nil, fmt.Errorf("%w: %d bytes exceeds the limit of %d bytes", ErrBodyTooLarge, req.ContentLength, maxBytes)
+    }
+    body, err := io.ReadAll(io.LimitReader(req.Body, maxBytes+1))
+    if err != nil {
+        return nil, fmt.Errorf("failed to read HTTP body: %w", err)
+    }
+    if int64(len(body)) > maxBytes {
+        return nil, fmt.Errorf("%w: exceeds the limit of %d bytes", ErrBodyTooLarge, maxBytes)
+    }
+    _ = req.Body.Close()
+    req.Body = io.NopCloser(bytes.NewReader(body))
+    return body, nil
+}
+
+func maxBodyBytes(maxBytes int64) int64 {
+    if maxBytes <= 0 {
+        return DefaultMaxBodyBytes
+    }
+    return maxBytes
+}
+
 type contextKey struct{}
 
 func OperationRequestWithContext(ctx context.Context, op *BaseOperationRequest) context.Context {
@@ -115,6 +165,47 @@ func OperationRequestFromContext(ctx context.Context) *BaseOperationRequest {
     return nil
 }
 
+// OperationRequestFor is a BaseOperationRequest with the body decoded into T.
+type OperationRequestFor[T any] struct {
+    *BaseOperationRequest
+    // Payload is the decoded body, the zero value of T when the body is empty.
+    Payload T
+}
+
+// DecodeOperationRequest decodes the JSON body of op into T. The errors wrap ErrInvalidBody.
+func DecodeOperationRequest[T any](op *BaseOperationRequest, opts OperationRequestOptions) (*OperationRequestFor[T], error) {
+    typed := &OperationRequestFor[T]{BaseOperationRequest: op}
+    if len(bytes.TrimSpace(op.Body)) == 0 {
+        return typed, nil
+    }
+    dec := json.NewDecoder(bytes.NewReader(op.Body))
+    if opts.DisallowUnknownFields {
+        dec.DisallowUnknownFields()
+    }
+    if err := dec.Decode(&typed.Payload); err != nil {
+        return nil, fmt.Errorf("%w: %v", ErrInvalidBody, err)
+    }
+    if dec.More() {
+        return nil, fmt.Errorf("%w: unexpected data after the JSON value", ErrInvalidBody)
+    }
+    return typed, nil
+}
+
+type typedContextKey[T any] struct{}
+
+func OperationRequestForWithContext[T any](ctx context.Context, op *OperationRequestFor[T]) context.Context {
+    return context.WithValue(ctx, typedContextKey[T]{}, op)
+}
+
+// OperationRequestForFromContext returns the OperationRequestFor[T] of the NewOperationRequestFor middleware,
+// or nil. OperationRequestFromContext returns its BaseOperationRequest.
+func OperationRequestForFromContext[T any](ctx context.Context) *OperationRequestFor[T] {
+    if op, ok := ctx.Value(typedContextKey[T]{}).(*OperationRequestFor[T]); ok {
+        return op
+    }
+    return nil
+}
+
 func FlattenOperationRequest(op *BaseOperationRequest) map[string]interface{} {
     result := make(map[string]interface{})
     val := reflect.ValueOf(op).Elem()
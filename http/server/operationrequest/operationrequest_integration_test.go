@@ -78,7 +78,7 @@ var _ = Describe("OperationRequest Context Examination Integration", func() {
         payload := "integration test payload"
         req, err := http.NewRequest(http.MethodPost, server.URL+validOpURL, strings.NewReader(payload))
         Expect(err).NotTo(HaveOccurred())
-        req.Header.Set(common.RequestCorrelationIDHeader, "corr-test-context")
+        req.Header.Set(common.RequestCorrelationIDHeader, "00000000-0000-0000-0000-00000000c0c1")
         req.Header.Set(common.RequestAcceptLanguageHeader, "EN-GB")
         // Do not provide an OperationID header so that one is auto-generated.
         req.Header.Set("X-Custom-Extra", "customValue")
@@ -100,7 +100,7 @@ var _ = Describe("OperationRequest Context Examination Integration", func() {
         Expect(op.APIVersion).To(Equal("2021-12-01"))
         Expect(op.SubscriptionID).To(Equal("sub3"))
         Expect(op.ResourceGroup).To(Equal("rg3"))
-        Expect(op.CorrelationID).To(Equal("corr-test-context"))
+        Expect(op.CorrelationID).To(Equal("00000000-0000-0000-0000-00000000c0c1"))
         Expect(op.HttpMethod).To(Equal(http.MethodPost))
         Expect(op.TargetURI).To(ContainSubstring("api-version=2021-12-01"))
         Expect(op.OperationID).NotTo(BeEmpty())
@@ -116,7 +116,7 @@ var _ = Describe("OperationRequest Context Examination Integration", func() {
         errorURL := "/subscriptions/sub3/resourceGroups/rg3/providers/Microsoft.Test/resourceType1/resourceName1/default"
         req, err := http.NewRequest(http.MethodPost, server.URL+errorURL, strings.NewReader(payload))
         Expect(err).NotTo(HaveOccurred())
-        req.Header.Set(common.RequestCorrelationIDHeader, "corr-error")
+        req.Header.Set(common.RequestCorrelationIDHeader, "00000000-0000-0000-0000-00000000c0e1")
         req.Header.Set(common.RequestAcceptLanguageHeader, "EN-GB")
         req.Header.Set("X-Custom-Extra", "customValue")
 
@@ -124,11 +124,28 @@ var _ = Describe("OperationRequest Context Examination Integration", func() {
         Expect(err).NotTo(HaveOccurred())
         defer resp.Body.Close()
 
-        Expect(resp.StatusCode).To(Equal(http.StatusInternalServerError))
+        Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
 
         data, err := io.ReadAll(resp.Body)
         Expect(err).NotTo(HaveOccurred())
-        Expect(string(data)).To(ContainSubstring("no api-version in URI's parameters"))
+        Expect(string(data)).To(ContainSubstring(`"code":"MissingApiVersionParameter"`))
+    })
+
+    It("should return an ARM error for a malformed operation id instead of panicking", func() {
+        req, err := http.NewRequest(http.MethodPost, server.URL+validOpURL, strings.NewReader("payload"))
+        Expect(err).NotTo(HaveOccurred())
+        req.Header.Set(common.RequestAcsOperationIDHeader, "not-a-guid")
+
+        resp, err := http.DefaultClient.Do(req)
+        Expect(err).NotTo(HaveOccurred())
+        defer resp.Body.Close()
+
+        Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+
+        data, err := io.ReadAll(resp.Body)
+        Expect(err).NotTo(HaveOccurred())
+        Expect(string(data)).To(ContainSubstring(`"code":"InvalidRequestHeader"`))
+        Expect(string(data)).To(ContainSubstring(`"target":"` + common.RequestAcsOperationIDHeader + `"`))
     })
 
     It("should allow a non-operation endpoint to work normally", func() {
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -56,7 +57,7 @@ var _ = Describe("OperationRequest Tests", func() {
 		It("should correctly build a BaseOperationRequest", func() {
 			payload := "test payload"
 			req = httptest.NewRequest(http.MethodPost, validURL, strings.NewReader(payload))
-			req.Header.Set(common.RequestCorrelationIDHeader, "corr-test")
+			req.Header.Set(common.RequestCorrelationIDHeader, "00000000-0000-0000-0000-00000000c0a1")
 			req.Header.Set(common.RequestAcceptLanguageHeader, "EN-GB")
 
 			routeMatch := &mux.RouteMatch{}
@@ -69,7 +70,7 @@ var _ = Describe("OperationRequest Tests", func() {
 			Expect(op.APIVersion).To(Equal("2021-12-01-preview"))
 			Expect(op.SubscriptionID).To(Equal("sub3"))
 			Expect(op.ResourceGroup).To(Equal("rg3"))
-			Expect(op.CorrelationID).To(Equal("corr-test"))
+			Expect(op.CorrelationID).To(Equal("00000000-0000-0000-0000-00000000c0a1"))
 			Expect(op.HttpMethod).To(Equal(http.MethodPost))
 			Expect(op.TargetURI).To(ContainSubstring("api-version=2021-12-01-Preview"))
 			Expect(strings.ToLower(op.AcceptedLanguage)).To(Equal("en-gb"))
@@ -80,7 +81,7 @@ var _ = Describe("OperationRequest Tests", func() {
 		It("should use the provided operation id if specified", func() {
 			req = httptest.NewRequest(http.MethodGet, validURL, nil)
 			providedOpID := uuid.Must(uuid.NewV4()).String()
-			req.Header.Set(common.RequestCorrelationIDHeader, "corr-test")
+			req.Header.Set(common.RequestCorrelationIDHeader, "00000000-0000-0000-0000-00000000c0a1")
 			req.Header.Set(common.RequestAcsOperationIDHeader, providedOpID)
 			req.Header.Set(common.RequestAcceptLanguageHeader, "EN-US")
 
@@ -97,7 +98,7 @@ var _ = Describe("OperationRequest Tests", func() {
 		Context("when using a customizer", func() {
 			It("should apply customization to grab extra info from the request header and keep other vars intact", func() {
 				req = httptest.NewRequest(http.MethodPost, validURL, strings.NewReader("payload"))
-				req.Header.Set(common.RequestCorrelationIDHeader, "corr-custom")
+				req.Header.Set(common.RequestCorrelationIDHeader, "00000000-0000-0000-0000-00000000c0b1")
 				req.Header.Set(common.RequestAcceptLanguageHeader, "fr-FR")
 				// Custom information to be extracted
 				req.Header.Set("X-My-Custom-Header", "header-value")
@@ -157,6 +158,190 @@ var _ = Describe("OperationRequest Tests", func() {
 		})
 	})
 
+	Describe("ValidateRequest", func() {
+		newRequest := func(header map[string]string) *http.Request {
+			r := httptest.NewRequest(http.MethodGet, validURL, nil)
+			for k, v := range header {
+				r.Header.Set(k, v)
+			}
+			return r
+		}
+
+		It("should accept valid ARM headers", func() {
+			req = newRequest(map[string]string{
+				common.RequestAcsOperationIDHeader:     uuid.Must(uuid.NewV4()).String(),
+				common.RequestCorrelationIDHeader:      uuid.Must(uuid.NewV4()).String(),
+				common.RequestARMClientRequestIDHeader: uuid.Must(uuid.NewV4()).String(),
+				common.RequestAcceptLanguageHeader:     "fr-CH, fr;q=0.9, en;q=0.8, *;q=0.5",
+			})
+			Expect(ValidateRequest(req, defaultOpts)).To(Succeed())
+		})
+
+		It("should reject a missing api-version", func() {
+			req = httptest.NewRequest(http.MethodGet, "/subscriptions/sub1/resourceGroups/rg1", nil)
+			Expect(ValidateRequest(req, defaultOpts)).To(MatchError(ErrMissingAPIVersion))
+		})
+
+		It("should reject headers that aren't GUIDs", func() {
+			for _, header := range GUIDHeaders {
+				req = newRequest(map[string]string{header: "not-a-guid"})
+				var headerErr *HeaderError
+				Expect(errors.As(ValidateRequest(req, defaultOpts), &headerErr)).To(BeTrue(), header)
+				Expect(headerErr.Header).To(Equal(header))
+			}
+		})
+
+		It("should reject an invalid Accept-Language", func() {
+			for _, value := range []string{"en_US", "en;q=2", "english language", "en-"} {
+				req = newRequest(map[string]string{common.RequestAcceptLanguageHeader: value})
+				var headerErr *HeaderError
+				Expect(errors.As(ValidateRequest(req, defaultOpts), &headerErr)).To(BeTrue(), value)
+				Expect(headerErr.Header).To(Equal(common.RequestAcceptLanguageHeader))
+			}
+		})
+
+		It("should reject a missing required header", func() {
+			opts := OperationRequestOptions{RequiredHeaders: map[string][]string{AnyRoute: {common.RequestCorrelationIDHeader}}}
+			req = newRequest(nil)
+			var headerErr *HeaderError
+			Expect(errors.As(ValidateRequest(req, opts), &headerErr)).To(BeTrue())
+			Expect(headerErr.Header).To(Equal(common.RequestCorrelationIDHeader))
+
+			req = newRequest(map[string]string{common.RequestCorrelationIDHeader: uuid.Must(uuid.NewV4()).String()})
+			Expect(ValidateRequest(req, opts)).To(Succeed())
+		})
+
+		It("should answer 400 to the routes missing their required headers", func() {
+			opts := OperationRequestOptions{RequiredHeaders: map[string][]string{"putWidget": {common.RequestARMClientRequestIDHeader}}}
+			router = mux.NewRouter()
+			router.Use(NewOperationRequest("region-test", opts))
+			handler := func(w http.ResponseWriter, r *http.Request) {}
+			router.HandleFunc("/subscriptions/{subscriptionID}/resourceGroups/{resourceGroup}", handler).Methods(http.MethodPut).Name("putWidget")
+			router.HandleFunc("/subscriptions/{subscriptionID}/resourceGroups/{resourceGroup}", handler).Methods(http.MethodGet).Name("getWidget")
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/subscriptions/sub1/resourceGroups/rg1?api-version=2021-12-01", nil))
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+			Expect(w.Body.String()).To(ContainSubstring(`"code":"InvalidRequestHeader"`))
+			Expect(w.Body.String()).To(ContainSubstring(`"target":"` + common.RequestARMClientRequestIDHeader + `"`))
+
+			w = httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/subscriptions/sub1/resourceGroups/rg1?api-version=2021-12-01", nil))
+			Expect(w.Code).To(Equal(http.StatusOK))
+		})
+	})
+
+	Describe("Request body", func() {
+		type widget struct {
+			Location string            `json:"location"`
+			Tags     map[string]string `json:"tags"`
+		}
+
+		newRequest := func(body string) *http.Request {
+			r := httptest.NewRequest(http.MethodPut, validURL, strings.NewReader(body))
+			routeMatch := &mux.RouteMatch{}
+			Expect(router.Match(r, routeMatch)).To(BeTrue())
+			return mux.SetURLVars(r, routeMatch.Vars)
+		}
+
+		It("should restore the body for the handlers", func() {
+			req = newRequest(`{"location":"eastus"}`)
+			op, err := NewBaseOperationRequest(req, "region-test", defaultOpts)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(op.Body)).To(Equal(`{"location":"eastus"}`))
+
+			body, err := io.ReadAll(req.Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(body)).To(Equal(`{"location":"eastus"}`))
+		})
+
+		It("should reject bodies larger than MaxBodyBytes", func() {
+			req = newRequest(`{"location":"eastus"}`)
+			req.ContentLength = -1
+			_, err := NewBaseOperationRequest(req, "region-test", OperationRequestOptions{MaxBodyBytes: 8})
+			Expect(err).To(MatchError(ErrBodyTooLarge))
+		})
+
+		It("should decode the body into the payload", func() {
+			req = newRequest(`{"location":"eastus","tags":{"env":"test"},"extra":1}`)
+			op, err := NewBaseOperationRequest(req, "region-test", defaultOpts)
+			Expect(err).ToNot(HaveOccurred())
+
+			typed, err := DecodeOperationRequest[widget](op, defaultOpts)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(typed.Payload).To(Equal(widget{Location: "eastus", Tags: map[string]string{"env": "test"}}))
+			Expect(typed.SubscriptionID).To(Equal("sub3"))
+
+			_, err = DecodeOperationRequest[widget](op, OperationRequestOptions{DisallowUnknownFields: true})
+			Expect(err).To(MatchError(ErrInvalidBody))
+			Expect(err.Error()).To(ContainSubstring(`unknown field "extra"`))
+		})
+
+		It("should leave the payload empty without body", func() {
+			req = newRequest("")
+			op, err := NewBaseOperationRequest(req, "region-test", defaultOpts)
+			Expect(err).ToNot(HaveOccurred())
+			typed, err := DecodeOperationRequest[widget](op, defaultOpts)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(typed.Payload).To(Equal(widget{}))
+		})
+
+		It("should reject invalid JSON", func() {
+			for _, body := range []string{`{"location":`, `{"location":1}`, `{} {}`} {
+				req = newRequest(body)
+				op, err := NewBaseOperationRequest(req, "region-test", defaultOpts)
+				Expect(err).ToNot(HaveOccurred())
+				_, err = DecodeOperationRequest[widget](op, defaultOpts)
+				Expect(err).To(MatchError(ErrInvalidBody), body)
+			}
+		})
+
+		Context("with the middleware", func() {
+			var (
+				payload *widget
+				body    string
+			)
+
+			serve := func(mw mux.MiddlewareFunc, reqBody string) *httptest.ResponseRecorder {
+				payload = nil
+				body = ""
+				router = mux.NewRouter()
+				router.Use(mw)
+				router.HandleFunc("/subscriptions/{subscriptionID}/resourceGroups/{resourceGroup}/providers/{resourceProvider}/{resourceType}/{resourceName}/default", func(w http.ResponseWriter, r *http.Request) {
+					if typed := OperationRequestForFromContext[widget](r.Context()); typed != nil {
+						payload = &typed.Payload
+						Expect(OperationRequestFromContext(r.Context())).To(BeIdenticalTo(typed.BaseOperationRequest))
+					}
+					data, _ := io.ReadAll(r.Body)
+					body = string(data)
+				})
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, httptest.NewRequest(http.MethodPut, validURL, strings.NewReader(reqBody)))
+				return w
+			}
+
+			It("should add the typed operation request to the context", func() {
+				w := serve(NewOperationRequestFor[widget]("region-test", defaultOpts), `{"location":"eastus"}`)
+				Expect(w.Code).To(Equal(http.StatusOK))
+				Expect(payload).To(Equal(&widget{Location: "eastus"}))
+				Expect(body).To(Equal(`{"location":"eastus"}`))
+			})
+
+			It("should answer 400 to bodies that can't be decoded", func() {
+				w := serve(NewOperationRequestFor[widget]("region-test", OperationRequestOptions{DisallowUnknownFields: true}), `{"name":"w"}`)
+				Expect(w.Code).To(Equal(http.StatusBadRequest))
+				Expect(w.Body.String()).To(ContainSubstring(`"code":"InvalidRequestContent"`))
+				Expect(payload).To(BeNil())
+			})
+
+			It("should answer 413 to bodies larger than MaxBodyBytes", func() {
+				w := serve(NewOperationRequest("region-test", OperationRequestOptions{MaxBodyBytes: 8}), `{"location":"eastus"}`)
+				Expect(w.Code).To(Equal(http.StatusRequestEntityTooLarge))
+				Expect(w.Body.String()).To(ContainSubstring(`"code":"RequestEntityTooLarge"`))
+			})
+		})
+	})
+
 	Describe("Concurrent Access Tests", func() {
 		It("should not have concurrent map writes when processing multiple requests", func() {
 			// Create a customizer that writes to the extras map
@@ -0,0 +1,85 @@
+package operationrequest
+
+import (
+    "errors"
+    "fmt"
+    "net/http"
+    "regexp"
+    "strings"
+
+    "github.com/Azure/aks-middleware/http/common"
+    "github.com/gofrs/uuid"
+    "github.com/gorilla/mux"
+)
+
+// ARM error codes of the invalid operation requests.
+const (
+    CodeInvalidRequestHeader       = "InvalidRequestHeader"
+    CodeMissingAPIVersionParameter = "MissingApiVersionParameter"
+)
+
+// AnyRoute is the OperationRequestOptions.RequiredHeaders key of the headers required on every route.
+const AnyRoute = "*"
+
+// ErrMissingAPIVersion is returned when the request has no api-version query parameter.
+var ErrMissingAPIVersion = errors.New("no api-version in URI's parameters")
+
+// GUIDHeaders are the ARM headers whose value must be a GUID when present.
+var GUIDHeaders = []string{
+    common.RequestAcsOperationIDHeader,
+    common.RequestCorrelationIDHeader,
+    common.RequestARMClientRequestIDHeader,
+}
+
+// HeaderError is returned when a request header is missing or invalid.
+type HeaderError struct {
+    Header string
+    Reason string
+}
+
+func (e *HeaderError) Error() string {
+    return fmt.Sprintf("invalid %s header: %s", e.Header, e.Reason)
+}
+
+// acceptLanguageRange is a language range with an optional weight, see RFC 7231 section 5.3.5.
+var acceptLanguageRange = regexp.MustCompile(`^(\*|[A-Za-z]{1,8}(-[A-Za-z0-9]{1,8})*)(\s*;\s*[qQ]=(0(\.[0-9]{0,3})?|1(\.0{0,3})?))?$`)
+
+// ValidateRequest checks the api-version query parameter and the ARM headers of req: the required headers of
+// the route in opts must be present, the GUIDHeaders must be GUIDs and Accept-Language must be a list of
+// language ranges. It returns ErrMissingAPIVersion or a *HeaderError.
+func ValidateRequest(req *http.Request, opts OperationRequestOptions) error {
+    if req.URL.Query().Get(common.APIVersionKey) == "" {
+        return ErrMissingAPIVersion
+    }
+
+    required := opts.RequiredHeaders[AnyRoute]
+    if currRoute := mux.CurrentRoute(req); currRoute != nil && currRoute.GetName() != "" {
+        required = append(required[:len(required):len(required)], opts.RequiredHeaders[currRoute.GetName()]...)
+    }
+    for _, header := range required {
+        if req.Header.Get(header) == "" {
+            return &HeaderError{Header: header, Reason: "the header is required"}
+        }
+    }
+
+    for _, header := range GUIDHeaders {
+        if value := req.Header.Get(header); value != "" {
+            if _, err := uuid.FromString(value); err != nil {
+                return &HeaderError{Header: header, Reason: fmt.Sprintf("'%s' is not a valid GUID", value)}
+            }
+        }
+    }
+
+    if value := req.Header.Get(common.RequestAcceptLanguageHeader); value != "" {
+        for _, languageRange := range strings.Split(value, ",") {
+            languageRange = strings.TrimSpace(languageRange)
+            if languageRange != "" && !acceptLanguageRange.MatchString(languageRange) {
+                return &HeaderError{
+                    Header: common.RequestAcceptLanguageHeader,
+                    Reason: fmt.Sprintf("'%s' is not a valid language range", languageRange),
+                }
+            }
+        }
+    }
+    return nil
+}
@@ -5,7 +5,10 @@ import (
 
 	"log/slog"
 
+	"github.com/Azure/aks-middleware/http/common/auth"
+	"github.com/Azure/aks-middleware/http/common/authz"
 	"github.com/Azure/aks-middleware/http/common/logging"
+	"github.com/Azure/aks-middleware/http/common/scrub"
 )
 
 // Middleware implements a separate OTEL audit middleware.
@@ -19,7 +22,10 @@ type Middleware struct {
 func (m *Middleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	customWriter := logging.NewResponseWriter(w)
 
-	ctx := r.Context()
+	// The holders receive the principal and the authorization decision of the auth and authz middlewares
+	// when they are registered after this one.
+	ctx := authz.WithDecisionHolder(auth.WithPrincipalHolder(r.Context()))
+	r = r.WithContext(ctx)
 	m.next.ServeHTTP(customWriter, r)
 	errorMsg := customWriter.Buf.String()
 
@@ -32,7 +38,7 @@ func NewOtelAuditLogging(logger *slog.Logger, otelConfig *OtelConfig) func(next
 	return func(next http.Handler) http.Handler {
 		return &Middleware{
 			next:       next,
-			logger:     logger,
+			logger:     scrub.Logger(logger),
 			otelConfig: otelConfig,
 		}
 	}
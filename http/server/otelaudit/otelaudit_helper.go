@@ -10,6 +10,8 @@ import (
 	"strings"
 
 	"github.com/Azure/aks-middleware/http/common"
+	"github.com/Azure/aks-middleware/http/common/auth"
+	"github.com/Azure/aks-middleware/http/common/authz"
 	"github.com/Azure/aks-middleware/http/common/logging"
 	"github.com/gorilla/mux"
 	"github.com/microsoft/go-otel-audit/audit"
@@ -107,6 +109,9 @@ func createOtelAuditEvent(logger *slog.Logger, statusCode int, req *http.Request
 		OperationResult:              getOperationResult(statusCode),
 		OperationResultDescription:   getOperationResultDescription(statusCode, errorMsg),
 	}
+	if decision, ok := authz.DecisionFromContext(req.Context()); ok && !decision.Allowed {
+		record.OperationResultDescription = fmt.Sprintf("operation denied by authorization rule %q, %s", decision.Rule, record.OperationResultDescription)
+	}
 
 	return msgs.Msg{
 		Type:   msgs.ControlPlane,
@@ -132,6 +137,11 @@ func getCallerIdentities(req *http.Request) map[msgs.CallerIdentityType][]msgs.C
 		}
 	}
 
+	if principal, ok := auth.PrincipalFromContext(req.Context()); ok {
+		addPrincipalIdentities(caller, principal)
+		return caller
+	}
+
 	clientAppID := req.Header.Get("x-ms-client-app-id")
 	if clientAppID != "" {
 		caller[msgs.ApplicationID] = []msgs.CallerIdentityEntry{
@@ -165,6 +175,29 @@ func getCallerIdentities(req *http.Request) map[msgs.CallerIdentityType][]msgs.C
 	return caller
 }
 
+// addPrincipalIdentities adds the identities of the principal of a validated token.
+func addPrincipalIdentities(caller map[msgs.CallerIdentityType][]msgs.CallerIdentityEntry, principal *auth.Principal) {
+	for _, identity := range []struct {
+		identityType msgs.CallerIdentityType
+		value        string
+		description  string
+	}{
+		{msgs.ObjectID, principal.ObjectID, "token object ID"},
+		{msgs.ApplicationID, principal.AppID, "token application ID"},
+		{msgs.UPN, principal.UPN, "token principal name"},
+		{msgs.TenantID, principal.TenantID, "token tenant ID"},
+	} {
+		if identity.value != "" {
+			caller[identity.identityType] = []msgs.CallerIdentityEntry{
+				{
+					Identity:    identity.value,
+					Description: identity.description,
+				},
+			}
+		}
+	}
+}
+
 func getOperationCategory(method string, opCategoryMapping map[string]msgs.OperationCategory) msgs.OperationCategory {
 	if opCategoryMapping != nil {
 		if cat, ok := opCategoryMapping[method]; ok {
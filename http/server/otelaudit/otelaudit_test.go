@@ -7,6 +7,8 @@ import (
 	"net/http/httptest"
 
 	"github.com/Azure/aks-middleware/http/common"
+	"github.com/Azure/aks-middleware/http/common/auth"
+	"github.com/Azure/aks-middleware/http/common/authz"
 	"github.com/gorilla/mux"
 	"github.com/microsoft/go-otel-audit/audit"
 	"github.com/microsoft/go-otel-audit/audit/conn"
@@ -74,6 +76,46 @@ var _ = Describe("createOtelAuditEvent", func() {
 		Expect(auditEvent.Record.CallerAgent).To(Equal("TestAgent"))
 		Expect(auditEvent.Record.OperationCategories).To(ConsistOf(msgs.OCOther))
 	})
+
+	It("should use the principal of the validated token instead of the identity headers", func() {
+		reqURL := "https://management.azure.com/subscriptions/sub-123/resourceGroups/rg-name/providers/Microsoft.Storage/storageAccounts/account-name?api-version=version"
+		req := httptest.NewRequest("GET", reqURL, nil)
+		req.RemoteAddr = "127.0.0.1:8080"
+		req.Header.Set("x-ms-client-app-id", "TestClientAppID")
+		req.Header.Set("x-ms-client-principal-name", "header@test.com")
+		// The authentication middleware sets the principal downstream of the audit middleware's holder.
+		ctx := auth.WithPrincipalHolder(req.Context())
+		_ = auth.WithPrincipal(ctx, &auth.Principal{ObjectID: "token-oid", TenantID: "token-tid", AppID: "token-appid"})
+		req = req.WithContext(ctx)
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		Expect(auditErr).To(BeNil())
+
+		identities := auditEvent.Record.CallerIdentities
+		Expect(identities[msgs.ObjectID][0].Identity).To(Equal("token-oid"))
+		Expect(identities[msgs.TenantID][0].Identity).To(Equal("token-tid"))
+		Expect(identities[msgs.ApplicationID][0].Identity).To(Equal("token-appid"))
+		Expect(identities).ToNot(HaveKey(msgs.UPN))
+		Expect(identities[msgs.SubscriptionID][0].Identity).To(Equal("sub-123"))
+	})
+
+	It("should describe the authorization denials with their rule", func() {
+		reqURL := "https://management.azure.com/subscriptions/sub-123/resourceGroups/rg-name/providers/Microsoft.Storage/storageAccounts/account-name?api-version=version"
+		req := httptest.NewRequest("GET", reqURL, nil)
+		req.RemoteAddr = "127.0.0.1:8080"
+		req.Header.Set("x-ms-client-app-id", "TestClientAppID")
+		ctx := authz.WithDecisionHolder(req.Context())
+		policy := &authz.Policy{Methods: map[string][]authz.Rule{
+			authz.AnyMethod: {{Name: "blocked", Effect: authz.Deny}},
+		}}
+		policy.Authorize(ctx, "getStorageAccount")
+		req = req.WithContext(ctx)
+
+		router.ServeHTTP(httptest.NewRecorder(), req)
+		Expect(auditErr).To(BeNil())
+		Expect(auditEvent.Record.OperationResultDescription).To(HavePrefix(`operation denied by authorization rule "blocked"`))
+	})
 })
 
 var _ = Describe("Otel Audit Test", func() {
@@ -0,0 +1,142 @@
+package ratelimit
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/Azure/aks-middleware/http/common"
+	"github.com/Azure/aks-middleware/http/common/armerror"
+	"github.com/Azure/aks-middleware/http/common/ratelimit"
+	"github.com/Azure/aks-middleware/http/common/scrub"
+	"github.com/Azure/aks-middleware/http/server/operationrequest"
+	"github.com/gorilla/mux"
+)
+
+// Throttling scopes of the ARM x-ms-ratelimit-remaining-<scope>-<reads|writes|deletes> headers.
+const (
+	ScopeSubscription = "subscription"
+	ScopeTenant       = "tenant"
+)
+
+// KeyFunc returns the rate limiting key of a request. Requests with an empty key are not limited.
+type KeyFunc func(r *http.Request) string
+
+// SubscriptionKey keys requests by the subscription of the operationrequest BaseOperationRequest,
+// or common.SubscriptionID when the operationrequest middleware isn't registered before.
+func SubscriptionKey(r *http.Request) string {
+	if op := operationrequest.OperationRequestFromContext(r.Context()); op != nil {
+		return op.SubscriptionID
+	}
+	return common.SubscriptionID(r)
+}
+
+// HeaderKey keys requests by the value of header, e.g. common.RequestClientTenantIDHeader
+// or common.RequestClientAppIDHeader.
+func HeaderKey(header string) KeyFunc {
+	return func(r *http.Request) string {
+		return r.Header.Get(header)
+	}
+}
+
+// Options configure the rate limiting middleware.
+type Options struct {
+	// Limiter is required.
+	Limiter *ratelimit.Limiter
+	// Key defaults to SubscriptionKey.
+	Key KeyFunc
+	// Scope names the remaining tokens headers and the error code. Defaults to ScopeSubscription.
+	Scope string
+	// Logger logs store errors. Requests are allowed when the store fails. Defaults to slog.Default().
+	Logger *slog.Logger
+}
+
+// NewRateLimit returns a middleware rate limiting requests per key. Reads, writes and deletes have separate
+// buckets, like the ARM throttling limits. Every response has the ARM x-ms-ratelimit-remaining header of
+// its scope and operation, and rejected requests get a 429 with Retry-After and an ARM error body.
+// It panics when opts has no Limiter.
+func NewRateLimit(opts Options) mux.MiddlewareFunc {
+	if opts.Limiter == nil {
+		panic("ratelimit: Options.Limiter is required")
+	}
+	if opts.Key == nil {
+		opts.Key = SubscriptionKey
+	}
+	if opts.Scope == "" {
+		opts.Scope = ScopeSubscription
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	opts.Logger = scrub.Logger(opts.Logger)
+	return func(next http.Handler) http.Handler {
+		return &rateLimitMiddleware{
+			next: next,
+			opts: opts,
+		}
+	}
+}
+
+var _ http.Handler = &rateLimitMiddleware{}
+
+type rateLimitMiddleware struct {
+	next http.Handler
+	opts Options
+}
+
+func (rl *rateLimitMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := rl.opts.Key(r)
+	if key == "" {
+		rl.next.ServeHTTP(w, r)
+		return
+	}
+	class := operationClass(r.Method)
+	result, err := rl.opts.Limiter.Take(r.Context(), key+"/"+class)
+	if err != nil {
+		rl.opts.Logger.WarnContext(r.Context(), "rate limit store failed, allowing request", "error", err)
+		rl.next.ServeHTTP(w, r)
+		return
+	}
+	w.Header().Set(RemainingHeader(rl.opts.Scope, class), strconv.Itoa(result.Remaining))
+	if !result.Allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(common.RetryAfterSeconds(result.RetryAfter)))
+		armerror.Write(w, http.StatusTooManyRequests, armerror.Error{
+			Code:    ThrottledCode(rl.opts.Scope),
+			Message: fmt.Sprintf("Number of %s requests for %s %s exceeded the limit. Please try again after %d seconds.", class, rl.opts.Scope, key, common.RetryAfterSeconds(result.RetryAfter)),
+		})
+		return
+	}
+	rl.next.ServeHTTP(w, r)
+}
+
+// RemainingHeader returns the ARM remaining tokens header of scope and class,
+// e.g. "x-ms-ratelimit-remaining-subscription-reads".
+func RemainingHeader(scope, class string) string {
+	return "x-ms-ratelimit-remaining-" + scope + "-" + class
+}
+
+// ThrottledCode returns the ARM error code of a throttled request of scope,
+// e.g. "SubscriptionRequestsThrottled".
+func ThrottledCode(scope string) string {
+	switch scope {
+	case ScopeSubscription:
+		return "SubscriptionRequestsThrottled"
+	case ScopeTenant:
+		return "TenantRequestsThrottled"
+	default:
+		return "TooManyRequests"
+	}
+}
+
+// operationClass returns the ARM throttling class of method: reads, writes or deletes.
+func operationClass(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return "reads"
+	case http.MethodDelete:
+		return "deletes"
+	default:
+		return "writes"
+	}
+}
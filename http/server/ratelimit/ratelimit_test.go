@@ -0,0 +1,95 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/Azure/aks-middleware/http/common"
+	"github.com/Azure/aks-middleware/http/common/armerror"
+	"github.com/Azure/aks-middleware/http/common/ratelimit"
+	"github.com/gorilla/mux"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type failingStore struct{}
+
+func (failingStore) Take(ctx context.Context, key string, limit ratelimit.Limit) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("store unavailable")
+}
+
+var _ = Describe("Rate limit middleware", func() {
+	var (
+		router  *mux.Router
+		limiter *ratelimit.Limiter
+	)
+
+	const route = "/subscriptions/{subscriptionID}/resourceGroups/{resourceGroup}/providers/{resourceProvider}/{resourceType}/{resourceName}"
+
+	BeforeEach(func() {
+		limiter = ratelimit.New(ratelimit.Limit{Rate: 0.001, Burst: 1})
+		router = mux.NewRouter()
+		router.Use(NewRateLimit(Options{Limiter: limiter}))
+		router.HandleFunc(route, func(w http.ResponseWriter, r *http.Request) {})
+	})
+
+	serve := func(method, sub string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		url := "/subscriptions/" + sub + "/resourceGroups/rg/providers/Microsoft.ContainerService/managedClusters/mc"
+		router.ServeHTTP(w, httptest.NewRequest(method, url, nil))
+		return w
+	}
+
+	It("should reject requests over the limit with an ARM 429", func() {
+		w := serve(http.MethodGet, "sub1")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Header().Get("x-ms-ratelimit-remaining-subscription-reads")).To(Equal("0"))
+
+		w = serve(http.MethodGet, "sub1")
+		Expect(w.Code).To(Equal(http.StatusTooManyRequests))
+		Expect(w.Header().Get("Retry-After")).ToNot(BeEmpty())
+		var resp armerror.Response
+		Expect(json.Unmarshal(w.Body.Bytes(), &resp)).To(Succeed())
+		Expect(resp.Error.Code).To(Equal("SubscriptionRequestsThrottled"))
+	})
+
+	It("should keep separate buckets per subscription and operation class", func() {
+		Expect(serve(http.MethodGet, "sub1").Code).To(Equal(http.StatusOK))
+		Expect(serve(http.MethodGet, "sub2").Code).To(Equal(http.StatusOK))
+		w := serve(http.MethodPut, "sub1")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Header().Get("x-ms-ratelimit-remaining-subscription-writes")).To(Equal("0"))
+		Expect(serve(http.MethodDelete, "sub1").Code).To(Equal(http.StatusOK))
+	})
+
+	It("should key requests by a header", func() {
+		router = mux.NewRouter()
+		router.Use(NewRateLimit(Options{
+			Limiter: limiter,
+			Key:     HeaderKey(common.RequestClientTenantIDHeader),
+			Scope:   ScopeTenant,
+		}))
+		router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(common.RequestClientTenantIDHeader, "tenant1")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		Expect(w.Code).To(Equal(http.StatusTooManyRequests))
+		Expect(w.Body.String()).To(ContainSubstring("TenantRequestsThrottled"))
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		Expect(w.Code).To(Equal(http.StatusOK))
+	})
+
+	It("should allow requests when the store fails", func() {
+		limiter.Store = failingStore{}
+		Expect(serve(http.MethodGet, "sub1").Code).To(Equal(http.StatusOK))
+		Expect(serve(http.MethodGet, "sub1").Code).To(Equal(http.StatusOK))
+	})
+})
@@ -4,6 +4,8 @@ import (
 	"log/slog"
 	"net/http"
 
+	"github.com/Azure/aks-middleware/http/common/panicreport"
+	"github.com/Azure/aks-middleware/http/common/scrub"
 	"github.com/Azure/aks-middleware/http/server/logging"
 	"github.com/gorilla/mux"
 )
@@ -12,6 +14,13 @@ type PanicHandlerFunc func(logger slog.Logger, w http.ResponseWriter, r *http.Re
 
 func defaultPanicHandler(logger slog.Logger, w http.ResponseWriter, r *http.Request, err interface{}) {
 	attributes := logging.BuildAttributes(r.Context(), r, "error", err)
+	if report, ok := panicreport.FromContext(r.Context()); ok {
+		attributes = append(attributes,
+			"fingerprint", report.Fingerprint,
+			"panic_count", panicreport.Count(report.Fingerprint),
+			"stack", report.Frames,
+		)
+	}
 	logger.ErrorContext(r.Context(), "Panic occurred", attributes...)
 	http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 }
@@ -23,7 +32,7 @@ func NewPanicHandling(logger *slog.Logger, panicHandler PanicHandlerFunc) mux.Mi
 	return func(next http.Handler) http.Handler {
 		return &panicHandlingMiddleware{
 			next:         next,
-			logger:       *logger,
+			logger:       *scrub.Logger(logger),
 			panicHandler: panicHandler,
 		}
 	}
@@ -38,7 +47,10 @@ type panicHandlingMiddleware struct {
 func (p *panicHandlingMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	defer func() {
 		if err := recover(); err != nil {
-			p.panicHandler(p.logger, w, r, err)
+			// The report is carried by the request context so that custom panic handlers can log it.
+			report := panicreport.Capture(err)
+			panicreport.Record(report)
+			p.panicHandler(p.logger, w, r.WithContext(panicreport.WithReport(r.Context(), report)), err)
 		}
 	}()
 	p.next.ServeHTTP(w, r)
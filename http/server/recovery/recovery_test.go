@@ -1,12 +1,14 @@
 package recovery
 
 import (
+	"bytes"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"os"
 
+	"github.com/Azure/aks-middleware/http/common/panicreport"
 	"github.com/gorilla/mux"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -53,6 +55,34 @@ var _ = Describe("Httpmw", func() {
 			Expect(w.Body.String()).To(ContainSubstring("Internal Server Error"))
 			Expect(w.Result().StatusCode).To(Equal(500))
 		})
+
+		It("should log the panic fingerprint and count it", func() {
+			buf := &bytes.Buffer{}
+			logger := slog.New(slog.NewJSONHandler(buf, nil))
+			router.Use(NewPanicHandling(logger, nil))
+			var report panicreport.Report
+			router.Use(func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					defer func() {
+						if err := recover(); err != nil {
+							report = panicreport.Capture(err)
+							panic(err)
+						}
+					}()
+					next.ServeHTTP(w, r)
+				})
+			})
+			router.HandleFunc("/", func(w http.ResponseWriter, _ *http.Request) {
+				panic("fingerprinted")
+			})
+
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+			Expect(report.Fingerprint).ToNot(BeEmpty())
+			Expect(buf.String()).To(ContainSubstring(`"fingerprint":"` + report.Fingerprint + `"`))
+			Expect(buf.String()).To(ContainSubstring("recovery_test.go"))
+			Expect(panicreport.Count(report.Fingerprint)).To(Equal(int64(1)))
+		})
 	})
 })
 
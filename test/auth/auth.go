@@ -0,0 +1,113 @@
+package auth
+
+// This package generates signing keys and tokens for the authentication tests.
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	Issuer   = "https://sts.windows.net/test-tenant/"
+	Audience = "https://management.core.windows.net/"
+)
+
+// Key is a locally generated signing key.
+type Key struct {
+	ID     string
+	signer crypto.Signer
+}
+
+// NewRSAKey generates a RSA key with id.
+func NewRSAKey(id string) *Key {
+	signer, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	return &Key{ID: id, signer: signer}
+}
+
+// NewECKey generates a P-256 key with id.
+func NewECKey(id string) *Key {
+	signer, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	return &Key{ID: id, signer: signer}
+}
+
+// Sign returns a token with claims signed by k.
+func (k *Key) Sign(claims jwt.MapClaims) string {
+	method := jwt.SigningMethod(jwt.SigningMethodRS256)
+	if _, ok := k.signer.(*ecdsa.PrivateKey); ok {
+		method = jwt.SigningMethodES256
+	}
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = k.ID
+	signed, err := token.SignedString(k.signer)
+	if err != nil {
+		panic(err)
+	}
+	return signed
+}
+
+// Claims returns valid claims for the test issuer and audience, expiring in an hour.
+func Claims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":   Issuer,
+		"aud":   Audience,
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"iat":   time.Now().Unix(),
+		"sub":   "test-subject",
+		"oid":   "test-oid",
+		"tid":   "test-tid",
+		"appid": "test-appid",
+		"upn":   "user@test.com",
+	}
+}
+
+// JWKS returns the JSON Web Key Set of the public keys of keys.
+func JWKS(keys ...*Key) []byte {
+	set := struct {
+		Keys []map[string]string `json:"keys"`
+	}{}
+	for _, k := range keys {
+		switch pub := k.signer.Public().(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, map[string]string{
+				"kty": "RSA",
+				"kid": k.ID,
+				"use": "sig",
+				"n":   encode(pub.N),
+				"e":   encode(big.NewInt(int64(pub.E))),
+			})
+		case *ecdsa.PublicKey:
+			set.Keys = append(set.Keys, map[string]string{
+				"kty": "EC",
+				"kid": k.ID,
+				"use": "sig",
+				"crv": pub.Curve.Params().Name,
+				"x":   encode(pub.X),
+				"y":   encode(pub.Y),
+			})
+		}
+	}
+	data, err := json.Marshal(set)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func encode(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
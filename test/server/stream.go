@@ -0,0 +1,87 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/Azure/aks-middleware/grpc/server/ctxlogger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	pb "github.com/Azure/aks-middleware/test/api/v1"
+)
+
+// The test proto only defines unary RPCs. The streaming greeter below is registered by hand
+// with the existing messages so stream interceptors can be exercised end to end.
+
+const (
+	StreamGreeterServiceName = "MyStreamGreeter"
+	SayHelloStreamMethod     = "/" + StreamGreeterServiceName + "/SayHelloStream"
+	// PanicName makes SayHelloStream panic when it is received as a request name.
+	PanicName = "panic"
+)
+
+// StreamGreeterServer is the server API for the hand-written MyStreamGreeter service.
+type StreamGreeterServer interface {
+	SayHelloStream(stream grpc.ServerStream) error
+}
+
+// SayHelloStreamDesc describes the bidirectional SayHelloStream RPC.
+var SayHelloStreamDesc = grpc.StreamDesc{
+	StreamName:    "SayHelloStream",
+	Handler:       sayHelloStreamHandler,
+	ServerStreams: true,
+	ClientStreams: true,
+}
+
+// StreamGreeterServiceDesc is the grpc.ServiceDesc for the MyStreamGreeter service.
+var StreamGreeterServiceDesc = grpc.ServiceDesc{
+	ServiceName: StreamGreeterServiceName,
+	HandlerType: (*StreamGreeterServer)(nil),
+	Streams:     []grpc.StreamDesc{SayHelloStreamDesc},
+}
+
+func sayHelloStreamHandler(srv any, stream grpc.ServerStream) error {
+	return srv.(StreamGreeterServer).SayHelloStream(stream)
+}
+
+// RegisterStreamGreeterServer registers srv on s.
+func RegisterStreamGreeterServer(s grpc.ServiceRegistrar, srv StreamGreeterServer) {
+	s.RegisterService(&StreamGreeterServiceDesc, srv)
+}
+
+// NewSayHelloStream opens a SayHelloStream call on cc.
+func NewSayHelloStream(ctx context.Context, cc grpc.ClientConnInterface, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return cc.NewStream(ctx, &SayHelloStreamDesc, SayHelloStreamMethod, opts...)
+}
+
+// TestStreamServer implements StreamGreeterServer and captures the stream context.
+type TestStreamServer struct {
+	// ReceivedMetadata stores the metadata extracted from the stream context.
+	ReceivedMetadata metadata.MD
+}
+
+// SayHelloStream logs through the context logger and replies with a greeting for every request until the client closes its side.
+func (s *TestStreamServer) SayHelloStream(stream grpc.ServerStream) error {
+	ctx := stream.Context()
+	s.ReceivedMetadata, _ = metadata.FromIncomingContext(ctx)
+	ctxlogger.GetLogger(ctx).Info("stream started")
+
+	for {
+		req := &pb.HelloRequest{}
+		err := stream.RecvMsg(req)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if req.Name == PanicName {
+			panic("stream panic")
+		}
+		if err := stream.SendMsg(&pb.HelloReply{Message: "Hello " + req.Name}); err != nil {
+			return err
+		}
+	}
+}